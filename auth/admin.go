@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"order-matching-engine/utils"
+	"strings"
+)
+
+// RequireAdmin returns middleware that only lets through requests bearing
+// token as "Authorization: Bearer <token>". With an empty token every
+// request is refused.
+func RequireAdmin(token string) func(http.Handler) http.Handler {
+	return requireBearer(token, "Admin API is disabled", "Invalid admin token")
+}
+
+// RequireMetricsToken is RequireAdmin for the Prometheus scrape endpoint,
+// which has a token of its own.
+func RequireMetricsToken(token string) func(http.Handler) http.Handler {
+	return requireBearer(token, "Metrics are disabled", "Invalid metrics token")
+}
+
+// requireBearer refuses requests not bearing token with invalid, or every
+// request with disabled if token is empty.
+func requireBearer(token, disabled, invalid string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				utils.WriteError(w, http.StatusForbidden, disabled)
+				return
+			}
+			presented, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !found || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+				utils.WriteError(w, http.StatusUnauthorized, invalid)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBearerTokens(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	for _, tc := range []struct {
+		name        string
+		middleware  func(http.Handler) http.Handler
+		header      string
+		wantCode    int
+		wantMessage string
+	}{
+		{"admin", RequireAdmin("s3cret"), "Bearer s3cret", http.StatusOK, ""},
+		{"admin wrong token", RequireAdmin("s3cret"), "Bearer other", http.StatusUnauthorized, "Invalid admin token"},
+		{"admin disabled", RequireAdmin(""), "Bearer ", http.StatusForbidden, "Admin API is disabled"},
+		{"metrics", RequireMetricsToken("scrape"), "Bearer scrape", http.StatusOK, ""},
+		{"metrics no token", RequireMetricsToken("scrape"), "", http.StatusUnauthorized, "Invalid metrics token"},
+		{"metrics wrong scheme", RequireMetricsToken("scrape"), "Basic scrape", http.StatusUnauthorized, "Invalid metrics token"},
+		{"metrics disabled", RequireMetricsToken(""), "Bearer scrape", http.StatusForbidden, "Metrics are disabled"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/metrics", nil)
+			if tc.header != "" {
+				r.Header.Set("Authorization", tc.header)
+			}
+			w := httptest.NewRecorder()
+			tc.middleware(ok).ServeHTTP(w, r)
+			if w.Code != tc.wantCode {
+				t.Fatalf("got %d, want %d", w.Code, tc.wantCode)
+			}
+			if tc.wantMessage == "" {
+				return
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body["error"] != tc.wantMessage {
+				t.Fatalf("body %s, want error %q", w.Body, tc.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Package auth authenticates REST API clients. Every request is signed with
+// an API key's secret using HMAC-SHA256 over the method, path, timestamp,
+// nonce and body, and the key's account and scope limit what it may do.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"order-matching-engine/clock"
+	"order-matching-engine/database"
+	"order-matching-engine/models"
+	"sync"
+	"time"
+)
+
+// Store persists API keys. Keys uses the MySQL repository by default.
+type Store interface {
+	SaveAPIKey(ctx context.Context, key *models.APIKey) error
+	GetAPIKey(ctx context.Context, id string) (*models.APIKey, error)
+	ListAPIKeys(ctx context.Context, account string) ([]*models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string, at time.Time) (bool, error)
+}
+
+type databaseStore struct{}
+
+func (databaseStore) SaveAPIKey(ctx context.Context, key *models.APIKey) error {
+	return database.SaveAPIKey(ctx, key)
+}
+
+func (databaseStore) GetAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	return database.GetAPIKey(ctx, id)
+}
+
+func (databaseStore) ListAPIKeys(ctx context.Context, account string) ([]*models.APIKey, error) {
+	return database.ListAPIKeys(ctx, account)
+}
+
+func (databaseStore) RevokeAPIKey(ctx context.Context, id string, at time.Time) (bool, error) {
+	return database.RevokeAPIKey(ctx, id, at)
+}
+
+// Keys creates, looks up and revokes API keys. Active keys are cached after
+// their first use so signed requests do not cost a database query;
+// revocations go through Keys and take effect at once.
+type Keys struct {
+	store Store
+	clock clock.Clock
+
+	mu    sync.RWMutex
+	cache map[string]*models.APIKey
+}
+
+func NewKeys(store Store, clk clock.Clock) *Keys {
+	if store == nil {
+		store = databaseStore{}
+	}
+	return &Keys{store: store, clock: clk, cache: make(map[string]*models.APIKey)}
+}
+
+// Create issues a new key. The returned key is the only copy of the secret
+// handed out.
+func (k *Keys) Create(ctx context.Context, req models.CreateAPIKeyRequest) (*models.APIKey, error) {
+	id, err := randomHex(12)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	key := &models.APIKey{
+		ID:        id,
+		Secret:    secret,
+		Account:   req.Account,
+		Scope:     req.Scope,
+		Label:     req.Label,
+		CreatedAt: k.clock.Now(),
+	}
+	if err := k.store.SaveAPIKey(ctx, key); err != nil {
+		return nil, fmt.Errorf("failed to save API key: %w", err)
+	}
+	return key, nil
+}
+
+// Active returns the key with id if it exists and has not been revoked.
+func (k *Keys) Active(ctx context.Context, id string) (*models.APIKey, error) {
+	k.mu.RLock()
+	key, cached := k.cache[id]
+	k.mu.RUnlock()
+	if cached {
+		return key, nil
+	}
+
+	key, err := k.store.GetAPIKey(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	if key == nil || key.RevokedAt != nil {
+		return nil, nil
+	}
+	k.mu.Lock()
+	k.cache[id] = key
+	k.mu.Unlock()
+	return key, nil
+}
+
+// List returns the keys of account, or every key, without secrets.
+func (k *Keys) List(ctx context.Context, account string) ([]*models.APIKey, error) {
+	return k.store.ListAPIKeys(ctx, account)
+}
+
+// Revoke disables a key. It reports false if there is no active key with id.
+func (k *Keys) Revoke(ctx context.Context, id string) (bool, error) {
+	revoked, err := k.store.RevokeAPIKey(ctx, id, k.clock.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	k.mu.Lock()
+	delete(k.cache, id)
+	k.mu.Unlock()
+	return revoked, nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
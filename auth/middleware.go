@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"order-matching-engine/clock"
+	"order-matching-engine/models"
+	"order-matching-engine/utils"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Request headers carrying the signature
+const (
+	HeaderKey       = "X-API-Key"
+	HeaderTimestamp = "X-API-Timestamp"
+	HeaderNonce     = "X-API-Nonce"
+	HeaderSignature = "X-API-Signature"
+)
+
+const (
+	// Largest request body that is read to verify a signature
+	maxBodyBytes   = 1 << 20
+	maxNonceLength = 64
+)
+
+// Sign returns the hex HMAC-SHA256 signature of a request: the method, the
+// path with its query string, the timestamp (Unix milliseconds) and the
+// nonce, each followed by a newline, then the raw body.
+func Sign(secret, method, requestURI, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	for _, part := range []string{method, requestURI, timestamp, nonce} {
+		mac.Write([]byte(part))
+		mac.Write([]byte{'\n'})
+	}
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Authenticator verifies signed requests. A request is accepted once: its
+// timestamp must be within window of the server clock and its nonce unused
+// by the key within that window.
+type Authenticator struct {
+	keys   *Keys
+	clock  clock.Clock
+	window time.Duration
+	nonces *nonceCache
+}
+
+func NewAuthenticator(keys *Keys, clk clock.Clock, window time.Duration) *Authenticator {
+	return &Authenticator{keys: keys, clock: clk, window: window, nonces: newNonceCache(window)}
+}
+
+// Middleware rejects requests without a valid signature from an active key
+// whose scope covers them, and passes the key on in the request context.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		if len(body) > maxBodyBytes {
+			utils.WriteError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key, err := a.Verify(r.Context(), SignedRequest{
+			KeyID:      r.Header.Get(HeaderKey),
+			Timestamp:  r.Header.Get(HeaderTimestamp),
+			Nonce:      r.Header.Get(HeaderNonce),
+			Signature:  r.Header.Get(HeaderSignature),
+			Method:     r.Method,
+			RequestURI: r.URL.RequestURI(),
+			Body:       body,
+		})
+		var refused *Error
+		if errors.As(err, &refused) {
+			utils.WriteError(w, http.StatusUnauthorized, refused.Message)
+			return
+		}
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, "Failed to authenticate request")
+			return
+		}
+		if !key.Allows(r.Method) {
+			utils.WriteError(w, http.StatusForbidden, "API key scope does not allow this request")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithKey(r.Context(), key)))
+	})
+}
+
+// SignedRequest is what a client sends to authenticate a request.
+type SignedRequest struct {
+	KeyID     string
+	Timestamp string
+	Nonce     string
+	Signature string
+
+	// The signed content
+	Method     string
+	RequestURI string
+	Body       []byte
+}
+
+// Error is returned by Verify when a request is refused.
+type Error struct {
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Verify checks a request's signature, timestamp and nonce and returns the
+// active key it was signed with. Refused requests get an *Error; any other
+// error means the key could not be looked up. Checking the key's scope is
+// left to the caller.
+func (a *Authenticator) Verify(ctx context.Context, req SignedRequest) (*models.APIKey, error) {
+	if req.KeyID == "" || req.Timestamp == "" || req.Nonce == "" || req.Signature == "" {
+		return nil, &Error{"Request must be signed with an API key"}
+	}
+	if len(req.Nonce) > maxNonceLength {
+		return nil, &Error{"Nonce too long"}
+	}
+
+	millis, err := strconv.ParseInt(req.Timestamp, 10, 64)
+	if err != nil {
+		return nil, &Error{"Invalid timestamp"}
+	}
+	now := a.clock.Now()
+	sent := time.UnixMilli(millis)
+	if sent.Before(now.Add(-a.window)) || sent.After(now.Add(a.window)) {
+		return nil, &Error{"Request timestamp outside the allowed window"}
+	}
+
+	key, err := a.keys.Active(ctx, req.KeyID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to look up API key", "key_id", req.KeyID, "error", err)
+		return nil, err
+	}
+	if key == nil {
+		return nil, &Error{"Invalid API key"}
+	}
+
+	expected := Sign(key.Secret, req.Method, req.RequestURI, req.Timestamp, req.Nonce, req.Body)
+	if !hmac.Equal([]byte(expected), []byte(req.Signature)) {
+		return nil, &Error{"Invalid signature"}
+	}
+	// Only remember nonces of authentic requests, so nobody can burn them
+	if !a.nonces.use(key.ID+"\n"+req.Nonce, sent.Add(a.window), now) {
+		return nil, &Error{"Nonce already used"}
+	}
+	return key, nil
+}
+
+type contextKey struct{}
+
+// WithKey returns ctx carrying the API key a request was signed with.
+func WithKey(ctx context.Context, key *models.APIKey) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// KeyFromContext returns the API key a request was signed with, or nil if
+// authentication is disabled.
+func KeyFromContext(ctx context.Context) *models.APIKey {
+	key, _ := ctx.Value(contextKey{}).(*models.APIKey)
+	return key
+}
+
+// ErrAccountNotPermitted refuses a request naming an account other than
+// its API key's.
+var ErrAccountNotPermitted = errors.New("account not permitted for this API key")
+
+// RestrictAccount binds an account field of a request to the account of the
+// API key in ctx: empty fields take the key's account and any other account
+// is refused. Without authentication it changes nothing.
+func RestrictAccount(ctx context.Context, account *string) error {
+	key := KeyFromContext(ctx)
+	if key == nil {
+		return nil
+	}
+	if *account != "" && *account != key.Account {
+		return ErrAccountNotPermitted
+	}
+	*account = key.Account
+	return nil
+}
+
+// OwnsOrder reports whether the API key in ctx may see order.
+func OwnsOrder(ctx context.Context, order *models.Order) bool {
+	key := KeyFromContext(ctx)
+	return key == nil || key.Account == order.Account
+}
+
+// nonceCache remembers nonces until the requests that used them fall
+// outside the timestamp window and could no longer be replayed anyway.
+type nonceCache struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	expires   map[string]time.Time
+	lastSweep time.Time
+}
+
+func newNonceCache(window time.Duration) *nonceCache {
+	return &nonceCache{window: window, expires: make(map[string]time.Time)}
+}
+
+// use records nonce and reports whether it was unused.
+func (c *nonceCache) use(nonce string, expires, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastSweep) > c.window {
+		for n, at := range c.expires {
+			if now.After(at) {
+				delete(c.expires, n)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	if at, seen := c.expires[nonce]; seen && !now.After(at) {
+		return false
+	}
+	c.expires[nonce] = expires
+	return true
+}
@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"order-matching-engine/clock"
+	"order-matching-engine/models"
+)
+
+const testWindow = 30 * time.Second
+
+// memoryStore keeps keys in memory and counts lookups.
+type memoryStore struct {
+	mu      sync.Mutex
+	keys    map[string]*models.APIKey
+	lookups int
+}
+
+func (s *memoryStore) SaveAPIKey(ctx context.Context, key *models.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := *key
+	s.keys[key.ID] = &stored
+	return nil
+}
+
+func (s *memoryStore) GetAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lookups++
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, nil
+	}
+	found := *key
+	return &found, nil
+}
+
+func (s *memoryStore) ListAPIKeys(ctx context.Context, account string) ([]*models.APIKey, error) {
+	return nil, nil
+}
+
+func (s *memoryStore) RevokeAPIKey(ctx context.Context, id string, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[id]
+	if !ok || key.RevokedAt != nil {
+		return false, nil
+	}
+	key.RevokedAt = &at
+	return true, nil
+}
+
+type testAuth struct {
+	*Authenticator
+	store *memoryStore
+	clock *clock.Fake
+}
+
+func newTestAuth(t *testing.T) *testAuth {
+	t.Helper()
+	store := &memoryStore{keys: make(map[string]*models.APIKey)}
+	clk := clock.NewFake(time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC), 0)
+	keys := NewKeys(store, clk)
+	return &testAuth{Authenticator: NewAuthenticator(keys, clk, testWindow), store: store, clock: clk}
+}
+
+func (a *testAuth) createKey(t *testing.T, scope string) *models.APIKey {
+	t.Helper()
+	key, err := a.keys.Create(context.Background(), models.CreateAPIKeyRequest{Account: "acct-1", Scope: scope})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// signed returns a request signed by key at sent with nonce.
+func signed(key *models.APIKey, sent time.Time, nonce, method, requestURI, body string) SignedRequest {
+	timestamp := strconv.FormatInt(sent.UnixMilli(), 10)
+	return SignedRequest{
+		KeyID:      key.ID,
+		Timestamp:  timestamp,
+		Nonce:      nonce,
+		Signature:  Sign(key.Secret, method, requestURI, timestamp, nonce, []byte(body)),
+		Method:     method,
+		RequestURI: requestURI,
+		Body:       []byte(body),
+	}
+}
+
+func expectRefused(t *testing.T, err error, message string) {
+	t.Helper()
+	var refused *Error
+	if !errors.As(err, &refused) || refused.Message != message {
+		t.Fatalf("Verify error = %v, want %q", err, message)
+	}
+}
+
+func TestSignatureCoversRequest(t *testing.T) {
+	a := newTestAuth(t)
+	key := a.createKey(t, models.ScopeTrade)
+	now := a.clock.Now()
+
+	req := signed(key, now, "n0", "POST", "/orders?symbol=AAPL", `{"quantity":10}`)
+	if got, err := a.Verify(context.Background(), req); err != nil || got.ID != key.ID {
+		t.Fatalf("Verify = %v, %v, want key %s", got, err, key.ID)
+	}
+
+	for field, tamper := range map[string]func(*SignedRequest){
+		"method": func(r *SignedRequest) { r.Method = "DELETE" },
+		"path":   func(r *SignedRequest) { r.RequestURI = "/orders/O1?symbol=AAPL" },
+		"query":  func(r *SignedRequest) { r.RequestURI = "/orders?symbol=MSFT" },
+		"timestamp": func(r *SignedRequest) {
+			r.Timestamp = strconv.FormatInt(now.Add(time.Millisecond).UnixMilli(), 10)
+		},
+		"nonce": func(r *SignedRequest) { r.Nonce += "x" },
+		"body":  func(r *SignedRequest) { r.Body = []byte(`{"quantity":1000}`) },
+		"secret": func(r *SignedRequest) {
+			r.Signature = Sign("other", r.Method, r.RequestURI, r.Timestamp, r.Nonce, r.Body)
+		},
+	} {
+		req := signed(key, now, "n-"+field, "POST", "/orders?symbol=AAPL", `{"quantity":10}`)
+		tamper(&req)
+		_, err := a.Verify(context.Background(), req)
+		if err == nil {
+			t.Fatalf("request with changed %s accepted", field)
+		}
+		expectRefused(t, err, "Invalid signature")
+	}
+}
+
+func TestTimestampWindow(t *testing.T) {
+	a := newTestAuth(t)
+	key := a.createKey(t, models.ScopeRead)
+	now := a.clock.Now()
+
+	for _, offset := range []time.Duration{-testWindow, -time.Second, 0, time.Second, testWindow} {
+		req := signed(key, now.Add(offset), "in-"+offset.String(), "GET", "/orders", "")
+		if _, err := a.Verify(context.Background(), req); err != nil {
+			t.Fatalf("timestamp %v from now refused: %v", offset, err)
+		}
+	}
+	for _, offset := range []time.Duration{-testWindow - time.Millisecond, testWindow + time.Millisecond, -time.Hour} {
+		req := signed(key, now.Add(offset), "out-"+offset.String(), "GET", "/orders", "")
+		_, err := a.Verify(context.Background(), req)
+		expectRefused(t, err, "Request timestamp outside the allowed window")
+	}
+
+	req := signed(key, now, "bad", "GET", "/orders", "")
+	req.Timestamp = "yesterday"
+	_, err := a.Verify(context.Background(), req)
+	expectRefused(t, err, "Invalid timestamp")
+}
+
+func TestNonceReplay(t *testing.T) {
+	a := newTestAuth(t)
+	key := a.createKey(t, models.ScopeRead)
+	other := a.createKey(t, models.ScopeRead)
+	now := a.clock.Now()
+
+	req := signed(key, now, "n1", "GET", "/orders", "")
+	if _, err := a.Verify(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	_, err := a.Verify(context.Background(), req)
+	expectRefused(t, err, "Nonce already used")
+	// Even signed afresh for another request
+	_, err = a.Verify(context.Background(), signed(key, now.Add(time.Second), "n1", "GET", "/trades", ""))
+	expectRefused(t, err, "Nonce already used")
+
+	// Nonces are per key
+	if _, err := a.Verify(context.Background(), signed(other, now, "n1", "GET", "/orders", "")); err != nil {
+		t.Fatalf("other key's nonce refused: %v", err)
+	}
+
+	// A badly signed request does not use up the nonce
+	forged := signed(key, now, "n2", "GET", "/orders", "")
+	forged.Signature = Sign("guess", forged.Method, forged.RequestURI, forged.Timestamp, forged.Nonce, forged.Body)
+	_, err = a.Verify(context.Background(), forged)
+	expectRefused(t, err, "Invalid signature")
+	if _, err := a.Verify(context.Background(), signed(key, now, "n2", "GET", "/orders", "")); err != nil {
+		t.Fatalf("nonce of a forged request refused: %v", err)
+	}
+
+	// Once the first request is outside the window it cannot be replayed,
+	// and its nonce may be used again
+	a.clock.Advance(testWindow + time.Second)
+	_, err = a.Verify(context.Background(), req)
+	expectRefused(t, err, "Request timestamp outside the allowed window")
+	if _, err := a.Verify(context.Background(), signed(key, a.clock.Now(), "n1", "GET", "/orders", "")); err != nil {
+		t.Fatalf("expired nonce refused: %v", err)
+	}
+}
+
+func TestRevokeInvalidatesCachedKey(t *testing.T) {
+	a := newTestAuth(t)
+	key := a.createKey(t, models.ScopeRead)
+	now := a.clock.Now()
+
+	for _, nonce := range []string{"n1", "n2", "n3"} {
+		if _, err := a.Verify(context.Background(), signed(key, now, nonce, "GET", "/orders", "")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if a.store.lookups != 1 {
+		t.Fatalf("%d store lookups for three requests, want 1", a.store.lookups)
+	}
+
+	revoked, err := a.keys.Revoke(context.Background(), key.ID)
+	if err != nil || !revoked {
+		t.Fatalf("Revoke = %v, %v", revoked, err)
+	}
+	_, err = a.Verify(context.Background(), signed(key, now, "n4", "GET", "/orders", ""))
+	expectRefused(t, err, "Invalid API key")
+
+	_, err = a.Verify(context.Background(), signed(&models.APIKey{ID: "missing", Secret: "s"}, now, "n5", "GET", "/orders", ""))
+	expectRefused(t, err, "Invalid API key")
+}
+
+func TestMiddleware(t *testing.T) {
+	a := newTestAuth(t)
+	trade := a.createKey(t, models.ScopeTrade)
+	read := a.createKey(t, models.ScopeRead)
+	now := a.clock.Now()
+
+	var gotBody string
+	var gotKey *models.APIKey
+	handler := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody, gotKey = string(b), KeyFromContext(r.Context())
+	}))
+	serve := func(key *models.APIKey, nonce, method, target, body string) int {
+		s := signed(key, now, nonce, method, target, body)
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set(HeaderKey, s.KeyID)
+		r.Header.Set(HeaderTimestamp, s.Timestamp)
+		r.Header.Set(HeaderNonce, s.Nonce)
+		r.Header.Set(HeaderSignature, s.Signature)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := serve(trade, "n1", "POST", "/orders?validate=true", `{"symbol":"AAPL"}`); code != http.StatusOK {
+		t.Fatalf("signed POST got %d", code)
+	}
+	if gotBody != `{"symbol":"AAPL"}` || gotKey == nil || gotKey.ID != trade.ID {
+		t.Fatalf("handler saw body %q and key %+v", gotBody, gotKey)
+	}
+	if code := serve(read, "n2", "POST", "/orders", `{}`); code != http.StatusForbidden {
+		t.Fatalf("POST with a read key got %d, want 403", code)
+	}
+	if code := serve(read, "n3", "GET", "/orders?account=acct-1", ""); code != http.StatusOK {
+		t.Fatalf("GET with a read key got %d", code)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/orders", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("unsigned request got %d, want 401", w.Code)
+	}
+}
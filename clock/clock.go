@@ -0,0 +1,51 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock supplies the current time to the engine, handlers and journal so
+// tests and replays can control it.
+type Clock interface {
+	Now() time.Time
+}
+
+// System reads the wall clock.
+type System struct{}
+
+func (System) Now() time.Time {
+	return time.Now()
+}
+
+// Fake is a deterministic clock. The first reading returns the start time and
+// every later reading advances by step, so each timestamp is unique.
+type Fake struct {
+	current time.Time
+	step    time.Duration
+	started bool
+	mu      sync.Mutex
+}
+
+func NewFake(start time.Time, step time.Duration) *Fake {
+	return &Fake{current: start, step: step}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.started {
+		f.current = f.current.Add(f.step)
+	}
+	f.started = true
+	return f.current
+}
+
+// Advance moves the clock forward by d without counting as a reading.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.current = f.current.Add(d)
+}
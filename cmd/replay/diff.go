@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"order-matching-engine/journal"
+)
+
+// firstDivergence compares two results and returns a description of the
+// first difference, or "" if the results are identical. Trades and errors
+// are compared first, and whichever diverges at the earlier step is
+// reported, since that is where the runs parted; final books, which only
+// show the cumulative effect, come last.
+func firstDivergence(before, after *Result) string {
+	tradeStep, tradeDivergence := recordDivergence("trade", before.Trades, after.Trades, func(t TradeRecord) int { return t.Step })
+	errorStep, errorDivergence := recordDivergence("error", before.Errors, after.Errors, func(e ErrorRecord) int { return e.Step })
+	if tradeDivergence != "" && (errorDivergence == "" || tradeStep <= errorStep) {
+		return tradeDivergence
+	}
+	if errorDivergence != "" {
+		return errorDivergence
+	}
+
+	beforeBooks := booksBySymbol(before.Books)
+	afterBooks := booksBySymbol(after.Books)
+	for _, book := range before.Books {
+		other, exists := afterBooks[book.Symbol]
+		if !exists {
+			return fmt.Sprintf("book %s: only in before", book.Symbol)
+		}
+		if divergence := sideDivergence(book.Symbol, "bid", book.Bids, other.Bids); divergence != "" {
+			return divergence
+		}
+		if divergence := sideDivergence(book.Symbol, "ask", book.Asks, other.Asks); divergence != "" {
+			return divergence
+		}
+	}
+	for _, book := range after.Books {
+		if _, exists := beforeBooks[book.Symbol]; !exists {
+			return fmt.Sprintf("book %s: only in after", book.Symbol)
+		}
+	}
+
+	return ""
+}
+
+// recordDivergence returns the step at which two lists of trades or errors
+// first differ, with a description of the difference. Records differing in
+// their steps diverge at the earlier one, where only one run had a record.
+func recordDivergence[T any](kind string, before, after []T, step func(T) int) (int, string) {
+	for i := 0; i < len(before) || i < len(after); i++ {
+		if i >= len(before) {
+			return step(after[i]), fmt.Sprintf("%s #%d: only in after (step %d): %s", kind, i+1, step(after[i]), toJSON(after[i]))
+		}
+		if i >= len(after) {
+			return step(before[i]), fmt.Sprintf("%s #%d: only in before (step %d): %s", kind, i+1, step(before[i]), toJSON(before[i]))
+		}
+		if b, a := toJSON(before[i]), toJSON(after[i]); b != a {
+			at := min(step(before[i]), step(after[i]))
+			return at, fmt.Sprintf("%s #%d differs (step %d):\n  before: %s\n  after:  %s", kind, i+1, at, b, a)
+		}
+	}
+	return 0, ""
+}
+
+func sideDivergence(symbol, side string, before, after []journal.RestingOrder) string {
+	for i := 0; i < len(before) || i < len(after); i++ {
+		if i >= len(before) {
+			return fmt.Sprintf("book %s %s priority %d: only in after: %s", symbol, side, i+1, toJSON(after[i]))
+		}
+		if i >= len(after) {
+			return fmt.Sprintf("book %s %s priority %d: only in before: %s", symbol, side, i+1, toJSON(before[i]))
+		}
+		if b, a := toJSON(before[i]), toJSON(after[i]); b != a {
+			return fmt.Sprintf("book %s %s priority %d differs:\n  before: %s\n  after:  %s", symbol, side, i+1, b, a)
+		}
+	}
+	return ""
+}
+
+func booksBySymbol(books []journal.BookSnapshot) map[string]journal.BookSnapshot {
+	bySymbol := make(map[string]journal.BookSnapshot, len(books))
+	for _, book := range books {
+		bySymbol[book.Symbol] = book
+	}
+	return bySymbol
+}
+
+func toJSON(v interface{}) string {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return string(encoded)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"order-matching-engine/journal"
+	"order-matching-engine/models"
+)
+
+func trade(step int, id string, quantity int) TradeRecord {
+	return TradeRecord{Step: step, Trade: &models.Trade{ID: id, Symbol: "AAPL", BuyOrderID: "b", SellOrderID: "s", Price: 100, Quantity: quantity}}
+}
+
+func book(orderIDs ...string) []journal.BookSnapshot {
+	snapshot := journal.BookSnapshot{Symbol: "AAPL"}
+	for i, id := range orderIDs {
+		snapshot.Bids = append(snapshot.Bids, journal.RestingOrder{Priority: i + 1, Order: &models.Order{ID: id}})
+	}
+	return []journal.BookSnapshot{snapshot}
+}
+
+func TestFirstDivergenceReportsEarliestStep(t *testing.T) {
+	base := &Result{
+		Trades: []TradeRecord{trade(1, "T1", 10), trade(6, "T2", 5)},
+		Errors: []ErrorRecord{{Step: 4, Error: "order not found"}},
+		Books:  book("b1"),
+	}
+
+	for _, tc := range []struct {
+		name  string
+		after *Result
+		want  string
+	}{
+		{"identical", &Result{Trades: base.Trades, Errors: base.Errors, Books: book("b1")}, ""},
+		{
+			// The second trade differs at step 6, but an error went missing at step 4
+			"error before trade",
+			&Result{Trades: []TradeRecord{trade(1, "T1", 10), trade(6, "T2", 7)}, Books: book("b1")},
+			"error #1: only in before (step 4)",
+		},
+		{
+			"trade before error",
+			&Result{Trades: []TradeRecord{trade(1, "T1", 9), trade(6, "T2", 5)}, Errors: []ErrorRecord{{Step: 4, Error: "other"}}, Books: book("b1")},
+			"trade #1 differs (step 1)",
+		},
+		{
+			// Trade #2 moved from step 6 to step 3, ahead of the error at step 4
+			"moved trade",
+			&Result{Trades: []TradeRecord{trade(1, "T1", 10), trade(3, "T2", 5)}, Errors: []ErrorRecord{{Step: 5, Error: "order not found"}}, Books: book("b1")},
+			"trade #2 differs (step 3)",
+		},
+		{
+			"extra error",
+			&Result{Trades: base.Trades, Errors: []ErrorRecord{{Step: 2, Error: "rejected"}, {Step: 4, Error: "order not found"}}, Books: book("b1")},
+			"error #1 differs (step 2)",
+		},
+		{
+			"books only",
+			&Result{Trades: base.Trades, Errors: base.Errors, Books: book("b2")},
+			"book AAPL bid priority 1 differs",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := firstDivergence(base, tc.after)
+			if tc.want == "" {
+				if got != "" {
+					t.Fatalf("firstDivergence = %q, want none", got)
+				}
+				return
+			}
+			if !strings.HasPrefix(got, tc.want) {
+				t.Fatalf("firstDivergence = %q, want it to start with %q", got, tc.want)
+			}
+		})
+	}
+}
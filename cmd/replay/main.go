@@ -0,0 +1,339 @@
+// Command replay feeds a recorded sequence of orders and cancels through the
+// matching engine with a fake clock and sequential IDs, so the same input
+// always produces byte-identical trades and books. The diff subcommand
+// compares two such results, e.g. from before and after a code change.
+//
+// Usage:
+//
+//	replay run -input orders.jsonl [-out result.json]
+//	replay run -journal data/engine.journal [-snapshots data/snapshots] [-out result.json]
+//	replay diff before.json after.json
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"order-matching-engine/clock"
+	"order-matching-engine/idgen"
+	"order-matching-engine/journal"
+	"order-matching-engine/models"
+	"order-matching-engine/services"
+	"os"
+	"time"
+)
+
+// command is one line of a JSON Lines input file. Op is "place", "cancel",
+// "amend" or "mass_cancel"; the remaining fields mirror the REST API request
+// bodies. An amend sets the order's total quantity and, if given, its price.
+type command struct {
+	Op string `json:"op"`
+	models.PlaceOrderRequest
+	OrderID  string   `json:"order_id,omitempty"`
+	MinPrice *float64 `json:"min_price,omitempty"`
+	MaxPrice *float64 `json:"max_price,omitempty"`
+}
+
+// Result is the output of a run. Step is the 1-based input line or journal
+// sequence number that produced each trade or error.
+type Result struct {
+	Trades []TradeRecord          `json:"trades"`
+	Errors []ErrorRecord          `json:"errors"`
+	Books  []journal.BookSnapshot `json:"books"`
+}
+
+type TradeRecord struct {
+	Step int `json:"step"`
+	*models.Trade
+}
+
+type ErrorRecord struct {
+	Step  int    `json:"step"`
+	Error string `json:"error"`
+}
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "run":
+		runCommand(os.Args[2:])
+	case "diff":
+		diffCommand(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: replay run (-input file | -journal file [-snapshots dir]) [-out file] [-start time] [-tick duration]")
+	fmt.Fprintln(os.Stderr, "       replay diff before.json after.json")
+	os.Exit(2)
+}
+
+func runCommand(args []string) {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	input := flags.String("input", "", "JSON Lines file of place/cancel/amend/mass_cancel commands")
+	journalPath := flags.String("journal", "", "engine journal to replay instead of -input")
+	snapshotDir := flags.String("snapshots", "data/snapshots", "with -journal, start from the latest snapshot here; empty to replay the journal alone")
+	out := flags.String("out", "", "write the result here instead of stdout")
+	start := flags.String("start", "2024-01-01T00:00:00Z", "initial time of the fake clock (RFC 3339)")
+	tick := flags.Duration("tick", time.Millisecond, "amount the fake clock advances on every reading")
+	flags.Parse(args)
+
+	if (*input == "") == (*journalPath == "") {
+		log.Fatal("exactly one of -input or -journal is required")
+	}
+
+	startTime, err := time.Parse(time.RFC3339, *start)
+	if err != nil {
+		log.Fatalf("invalid -start: %v", err)
+	}
+
+	r := newRunner(startTime, *tick)
+	if *input != "" {
+		err = r.runCommands(*input)
+	} else {
+		err = r.runJournal(*journalPath, *snapshotDir)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	output := os.Stdout
+	if *out != "" {
+		if output, err = os.Create(*out); err != nil {
+			log.Fatalf("failed to create output: %v", err)
+		}
+		defer output.Close()
+	}
+
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r.result()); err != nil {
+		log.Fatalf("failed to write result: %v", err)
+	}
+}
+
+type runner struct {
+	clock    *clock.Fake
+	engine   *services.MatchingEngine
+	orderIDs idgen.Generator
+	trades   []TradeRecord
+	errors   []ErrorRecord
+}
+
+func newRunner(start time.Time, tick time.Duration) *runner {
+	clk := clock.NewFake(start, tick)
+	return &runner{
+		clock: clk,
+		engine: services.NewMatchingEngine(
+			services.WithStore(services.NewMemoryStore()),
+			services.WithClock(clk),
+			services.WithTradeIDs(idgen.NewSequence("T", 0)),
+		),
+		orderIDs: idgen.NewSequence("O", 0),
+	}
+}
+
+func (r *runner) runCommands(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open input: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for step := 1; scanner.Scan(); step++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var cmd command
+		if err := json.Unmarshal(scanner.Bytes(), &cmd); err != nil {
+			return fmt.Errorf("line %d: %w", step, err)
+		}
+		if err := r.apply(step, &cmd); err != nil {
+			return fmt.Errorf("line %d: %w", step, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func (r *runner) apply(step int, cmd *command) error {
+	switch cmd.Op {
+	case "place":
+		r.place(step, &models.Order{
+			ID:                r.orderIDs.NewID(),
+			Symbol:            cmd.Symbol,
+			Account:           cmd.Account,
+			Side:              cmd.Side,
+			Type:              cmd.Type,
+			Price:             cmd.Price,
+			InitialQuantity:   cmd.Quantity,
+			RemainingQuantity: cmd.Quantity,
+			Status:            "open",
+		})
+	case "cancel":
+		r.cancel(step, cmd.OrderID)
+	case "amend":
+		r.amend(step, cmd.OrderID, cmd.Quantity, cmd.Price)
+	case "mass_cancel":
+		_, err := r.engine.MassCancel(models.MassCancelRequest{
+			Symbol:   cmd.Symbol,
+			Side:     cmd.Side,
+			Account:  cmd.Account,
+			MinPrice: cmd.MinPrice,
+			MaxPrice: cmd.MaxPrice,
+		})
+		r.recordError(step, err)
+	default:
+		return fmt.Errorf("unknown op %q", cmd.Op)
+	}
+	return nil
+}
+
+// runJournal re-submits the inputs recorded in an engine journal. Like
+// recovery, it starts from the latest snapshot in snapshotDir, if there is
+// one, and replays only the entries after it, which a snapshot may have
+// truncated from the journal anyway, skipping operations a rollback
+// withdrew. Orders keep their journaled IDs and
+// queue in journal order; timestamps and trade IDs are fresh deterministic
+// ones.
+func (r *runner) runJournal(path, snapshotDir string) error {
+	var lastSeq uint64
+	if snapshotDir != "" {
+		snap, err := journal.NewSnapshotStore(snapshotDir, 1).Latest()
+		if err != nil {
+			return fmt.Errorf("failed to load snapshot: %w", err)
+		}
+		if snap != nil {
+			r.engine.Restore(snap)
+			lastSeq = snap.Sequence
+			// Keep new orders' times after those of the restored ones
+			if gap := snap.CreatedAt.Sub(r.clock.Now()); gap > 0 {
+				r.clock.Advance(gap)
+			}
+		}
+	}
+
+	withdrawn, err := journal.RolledBack(path, lastSeq)
+	if err != nil {
+		return err
+	}
+	return journal.ReadAfter(path, lastSeq, func(entry *journal.Entry) error {
+		if entry.Sequence != lastSeq+1 {
+			return fmt.Errorf("%w: expected sequence %d, got %d", journal.ErrCorrupt, lastSeq+1, entry.Sequence)
+		}
+		lastSeq = entry.Sequence
+		if withdrawn[entry.Sequence] {
+			return nil
+		}
+		step := int(entry.Sequence)
+		switch entry.Type {
+		case journal.EntryOrderAccepted:
+			order := *entry.Order
+			r.place(step, &order)
+		case journal.EntryOrderAmended:
+			r.amend(step, entry.OrderID, entry.Order.InitialQuantity, entry.Order.Price)
+		case journal.EntryOrderCancelled:
+			// Unfilled market remainders are an output of matching, not an input
+			if entry.Reason != journal.ReasonNoLiquidity {
+				r.cancel(step, entry.OrderID)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *runner) place(step int, order *models.Order) {
+	trades, err := r.engine.ProcessOrder(context.Background(), order)
+	if r.recordError(step, err) {
+		return
+	}
+	for _, trade := range trades {
+		r.trades = append(r.trades, TradeRecord{Step: step, Trade: trade})
+	}
+}
+
+func (r *runner) amend(step int, orderID string, quantity int, price *float64) {
+	_, trades, err := r.engine.AmendOrder(orderID, quantity, price)
+	if r.recordError(step, err) {
+		return
+	}
+	for _, trade := range trades {
+		r.trades = append(r.trades, TradeRecord{Step: step, Trade: trade})
+	}
+}
+
+func (r *runner) cancel(step int, orderID string) {
+	r.recordError(step, r.engine.CancelOrder(orderID))
+}
+
+func (r *runner) recordError(step int, err error) bool {
+	if err == nil {
+		return false
+	}
+	r.errors = append(r.errors, ErrorRecord{Step: step, Error: err.Error()})
+	return true
+}
+
+func (r *runner) result() *Result {
+	return &Result{
+		Trades: nonNil(r.trades),
+		Errors: nonNil(r.errors),
+		Books:  r.engine.Snapshot().Books,
+	}
+}
+
+func diffCommand(args []string) {
+	if len(args) != 2 {
+		usage()
+	}
+
+	before, err := loadResult(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	after, err := loadResult(args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	divergence := firstDivergence(before, after)
+	if divergence == "" {
+		fmt.Println("no divergence")
+		return
+	}
+	fmt.Println(divergence)
+	os.Exit(1)
+}
+
+func loadResult(path string) (*Result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open result: %w", err)
+	}
+	defer file.Close()
+
+	result := &Result{}
+	if err := json.NewDecoder(file).Decode(result); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return result, nil
+}
+
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
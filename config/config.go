@@ -0,0 +1,232 @@
+// Package config defines the server's settings. Each one has a built-in
+// default that can be overridden by a YAML file, then by an environment
+// variable, then by a command line flag; see Load. Everything is validated
+// before the server starts.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Shortest admin or metrics token accepted
+const minTokenLength = 16
+
+type Config struct {
+	HTTP      HTTPConfig      `yaml:"http"`
+	GRPC      GRPCConfig      `yaml:"grpc"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Book      BookConfig      `yaml:"book"`
+	Features  FeaturesConfig  `yaml:"features"`
+	Auth      AuthConfig      `yaml:"auth"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Metrics   MetricsConfig   `yaml:"metrics"`
+	Journal   JournalConfig   `yaml:"journal"`
+	// Order and trade IDs: uuid or sequence
+	IDScheme string `yaml:"id_scheme" env:"ID_SCHEME"`
+	// How far back to rebuild candles from the trades table at startup
+	CandleBackfill time.Duration `yaml:"candle_backfill" env:"CANDLE_BACKFILL"`
+	FIX            FIXConfig     `yaml:"fix"`
+	OUCH           OUCHConfig    `yaml:"ouch"`
+	ITCH           ITCHConfig    `yaml:"itch"`
+	Tracing        TracingConfig `yaml:"tracing"`
+	Logging        LoggingConfig `yaml:"logging"`
+}
+
+type HTTPConfig struct {
+	ListenAddr        string        `yaml:"listen_addr" env:"HTTP_LISTEN_ADDR"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT"`
+	// Zero by default so the event stream and WebSocket stay open
+	WriteTimeout time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+}
+
+type GRPCConfig struct {
+	ListenAddr string `yaml:"listen_addr" env:"GRPC_LISTEN_ADDR"`
+}
+
+// BookConfig limits how many orders per side GET /orderbook returns.
+type BookConfig struct {
+	DefaultDepth int `yaml:"default_depth" env:"BOOK_DEFAULT_DEPTH"`
+	MaxDepth     int `yaml:"max_depth" env:"BOOK_MAX_DEPTH"`
+}
+
+// FeaturesConfig switches optional interfaces on or off. gRPC and metrics
+// are off unless asked for. FIX, OUCH and ITCH are enabled by setting their
+// listen or multicast address.
+type FeaturesConfig struct {
+	GRPC         bool `yaml:"grpc" env:"FEATURE_GRPC"`
+	MarketDataWS bool `yaml:"market_data_ws" env:"FEATURE_MARKET_DATA_WS"`
+	EventStream  bool `yaml:"event_stream" env:"FEATURE_EVENT_STREAM"`
+	Metrics      bool `yaml:"metrics" env:"FEATURE_METRICS"`
+}
+
+type AuthConfig struct {
+	// Enables key management and engine controls under /admin
+	AdminToken string        `yaml:"admin_token" env:"ADMIN_TOKEN" flag:"-"`
+	Window     time.Duration `yaml:"window" env:"AUTH_WINDOW"`
+	Disabled   bool          `yaml:"disabled" env:"AUTH_DISABLED"`
+}
+
+// RateLimitConfig holds the rate limit specs parsed by the ratelimit
+// package.
+type RateLimitConfig struct {
+	Tiers       string `yaml:"tiers" env:"RATE_LIMIT_TIERS"`
+	DefaultTier string `yaml:"default_tier" env:"RATE_LIMIT_DEFAULT_TIER"`
+	Accounts    string `yaml:"accounts" env:"RATE_LIMIT_ACCOUNTS"`
+	PerIP       string `yaml:"per_ip" env:"RATE_LIMIT_PER_IP"`
+	TrustProxy  bool   `yaml:"trust_proxy" env:"RATE_LIMIT_TRUST_PROXY"`
+}
+
+type MetricsConfig struct {
+	// Bearer token required to scrape /metrics, which needs one to be enabled
+	Token string `yaml:"token" env:"METRICS_TOKEN" flag:"-"`
+}
+
+type JournalConfig struct {
+	Path             string        `yaml:"path" env:"JOURNAL_PATH"`
+	SnapshotDir      string        `yaml:"snapshot_dir" env:"SNAPSHOT_DIR"`
+	SnapshotInterval time.Duration `yaml:"snapshot_interval" env:"SNAPSHOT_INTERVAL"`
+}
+
+type FIXConfig struct {
+	ListenAddr string `yaml:"listen_addr" env:"FIX_LISTEN_ADDR"`
+	CompID     string `yaml:"comp_id" env:"FIX_COMP_ID"`
+	Sessions   string `yaml:"sessions" env:"FIX_SESSIONS"`
+	StoreDir   string `yaml:"store_dir" env:"FIX_STORE_DIR"`
+}
+
+type OUCHConfig struct {
+	ListenAddr string `yaml:"listen_addr" env:"OUCH_LISTEN_ADDR"`
+	// username:password[:account] entries
+	Users string `yaml:"users" env:"OUCH_USERS" flag:"-"`
+}
+
+type ITCHConfig struct {
+	MulticastAddr  string `yaml:"multicast_addr" env:"ITCH_MULTICAST_ADDR"`
+	Interface      string `yaml:"interface" env:"ITCH_INTERFACE"`
+	TTL            int    `yaml:"ttl" env:"ITCH_TTL"`
+	RetransmitAddr string `yaml:"retransmit_addr" env:"ITCH_RETRANSMIT_ADDR"`
+	Retention      int    `yaml:"retention" env:"ITCH_RETENTION"`
+}
+
+type TracingConfig struct {
+	Endpoint    string  `yaml:"endpoint" env:"TRACING_ENDPOINT"`
+	Insecure    bool    `yaml:"insecure" env:"TRACING_INSECURE"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+}
+
+type LoggingConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL"`
+	Format string `yaml:"format" env:"LOG_FORMAT"`
+}
+
+// Default returns the built-in settings. They hold no credentials, so the
+// database user and password must always be configured.
+func Default() *Config {
+	return &Config{
+		HTTP: HTTPConfig{
+			ListenAddr:        ":8080",
+			ReadHeaderTimeout: 5 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+		},
+		GRPC: GRPCConfig{ListenAddr: ":9090"},
+		Database: DatabaseConfig{
+			Host:            "localhost",
+			Port:            3306,
+			Database:        "order_matching",
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			ConnectTimeout:  5 * time.Second,
+			ReadTimeout:     30 * time.Second,
+			WriteTimeout:    30 * time.Second,
+		},
+		Book:     BookConfig{DefaultDepth: 10, MaxDepth: 100},
+		Features: FeaturesConfig{MarketDataWS: true, EventStream: true},
+		Auth:     AuthConfig{Window: 30 * time.Second},
+		RateLimit: RateLimitConfig{
+			Tiers:       "standard:order_entry=10/20,market_data=50/100",
+			DefaultTier: "standard",
+			PerIP:       "order_entry=20/40,market_data=100/200",
+		},
+		Journal: JournalConfig{
+			Path:             "data/engine.journal",
+			SnapshotDir:      "data/snapshots",
+			SnapshotInterval: 5 * time.Minute,
+		},
+		IDScheme:       "uuid",
+		CandleBackfill: 168 * time.Hour,
+		FIX:            FIXConfig{CompID: "MATCHER", StoreDir: "data/fix"},
+		ITCH:           ITCHConfig{TTL: 1, RetransmitAddr: ":30002", Retention: 1000000},
+		Tracing:        TracingConfig{SampleRatio: 1},
+		Logging:        LoggingConfig{Level: "info", Format: "json"},
+	}
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	checkAddr := func(name, addr string) {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			errs = append(errs, fmt.Errorf("%s %q is not a host:port address", name, addr))
+		}
+	}
+	checkToken := func(name, token string) {
+		check(token == "" || len(token) >= minTokenLength, "%s must be at least %d characters", name, minTokenLength)
+	}
+
+	checkAddr("http.listen_addr", c.HTTP.ListenAddr)
+	check(c.HTTP.ReadHeaderTimeout > 0, "http.read_header_timeout must be positive")
+	check(c.HTTP.IdleTimeout >= 0 && c.HTTP.WriteTimeout >= 0, "http.idle_timeout and write_timeout must not be negative")
+	if c.Features.GRPC {
+		checkAddr("grpc.listen_addr", c.GRPC.ListenAddr)
+	}
+	errs = append(errs, c.Database.validate()...)
+	check(c.Book.DefaultDepth >= 1, "book.default_depth must be at least 1")
+	check(c.Book.MaxDepth >= c.Book.DefaultDepth, "book.max_depth must be at least book.default_depth")
+
+	checkToken("auth.admin_token", c.Auth.AdminToken)
+	check(c.Auth.Window > 0, "auth.window must be positive")
+	checkToken("metrics.token", c.Metrics.Token)
+	check(!c.Features.Metrics || c.Metrics.Token != "", "metrics.token is required when features.metrics is on")
+
+	check(c.Journal.Path != "", "journal.path is required")
+	check(c.Journal.SnapshotDir != "", "journal.snapshot_dir is required")
+	check(c.Journal.SnapshotInterval > 0, "journal.snapshot_interval must be positive")
+	check(c.IDScheme == "uuid" || c.IDScheme == "sequence", "id_scheme %q must be uuid or sequence", c.IDScheme)
+	check(c.CandleBackfill >= 0, "candle_backfill must not be negative")
+
+	if c.FIX.ListenAddr != "" {
+		checkAddr("fix.listen_addr", c.FIX.ListenAddr)
+		check(c.FIX.CompID != "", "fix.comp_id is required")
+	}
+	if c.OUCH.ListenAddr != "" {
+		checkAddr("ouch.listen_addr", c.OUCH.ListenAddr)
+	}
+	if c.ITCH.MulticastAddr != "" {
+		checkAddr("itch.multicast_addr", c.ITCH.MulticastAddr)
+		checkAddr("itch.retransmit_addr", c.ITCH.RetransmitAddr)
+		check(c.ITCH.TTL >= 0 && c.ITCH.TTL <= 255, "itch.ttl must be between 0 and 255")
+		check(c.ITCH.Retention > 0, "itch.retention must be positive")
+	}
+
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1")
+	switch strings.ToLower(c.Logging.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("logging.level %q must be debug, info, warn or error", c.Logging.Level))
+	}
+	check(c.Logging.Format == "json" || c.Logging.Format == "text", "logging.format %q must be json or text", c.Logging.Format)
+
+	return errors.Join(errs...)
+}
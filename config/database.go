@@ -1,34 +1,97 @@
 package config
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
+	"strconv"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
 type DatabaseConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	Database string
+	Host     string `yaml:"host" env:"DB_HOST"`
+	Port     int    `yaml:"port" env:"DB_PORT"`
+	User     string `yaml:"user" env:"DB_USER"`
+	Password string `yaml:"password" env:"DB_PASSWORD" flag:"-"`
+	Database string `yaml:"name" env:"DB_NAME"`
+
+	// Connection pool. Idle connections are capped at MaxOpenConns.
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+
+	// Timeouts for dialing and for each read and write on a connection
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT"`
+	ReadTimeout    time.Duration `yaml:"read_timeout" env:"DB_READ_TIMEOUT"`
+	WriteTimeout   time.Duration `yaml:"write_timeout" env:"DB_WRITE_TIMEOUT"`
+}
+
+func (c DatabaseConfig) validate() []error {
+	var errs []error
+	if c.Host == "" {
+		errs = append(errs, fmt.Errorf("database.host is required"))
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("database.port %d is not a valid port", c.Port))
+	}
+	// There are no default credentials
+	if c.User == "" {
+		errs = append(errs, fmt.Errorf("database.user is required (DB_USER)"))
+	}
+	if c.Password == "" {
+		errs = append(errs, fmt.Errorf("database.password is required (DB_PASSWORD)"))
+	}
+	if c.Database == "" {
+		errs = append(errs, fmt.Errorf("database.name is required"))
+	}
+	if c.MaxOpenConns < 1 {
+		errs = append(errs, fmt.Errorf("database.max_open_conns must be at least 1"))
+	}
+	if c.MaxIdleConns < 0 {
+		errs = append(errs, fmt.Errorf("database.max_idle_conns must not be negative"))
+	}
+	if c.ConnMaxLifetime < 0 || c.ConnMaxIdleTime < 0 {
+		errs = append(errs, fmt.Errorf("database.conn_max_lifetime and conn_max_idle_time must not be negative"))
+	}
+	if c.ConnectTimeout <= 0 || c.ReadTimeout <= 0 || c.WriteTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("database.connect_timeout, read_timeout and write_timeout must be positive"))
+	}
+	return errs
 }
 
 func NewDatabaseConnection(config DatabaseConfig) (*sql.DB, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
-		config.User, config.Password, config.Host, config.Port, config.Database)
+	dsn := mysql.NewConfig()
+	dsn.User = config.User
+	dsn.Passwd = config.Password
+	dsn.Net = "tcp"
+	dsn.Addr = net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	dsn.DBName = config.Database
+	dsn.ParseTime = true
+	dsn.Timeout = config.ConnectTimeout
+	dsn.ReadTimeout = config.ReadTimeout
+	dsn.WriteTimeout = config.WriteTimeout
 
-	db, err := sql.Open("mysql", dsn)
+	db, err := sql.Open("mysql", dsn.FormatDSN())
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
 
-	if err := db.Ping(); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), config.ConnectTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	log.Println("Database connection established")
+	slog.Info("Database connection established", "host", config.Host, "port", config.Port, "database", config.Database)
 	return db, nil
-}
\ No newline at end of file
+}
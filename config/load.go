@@ -0,0 +1,186 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the configuration in four layers, each overriding the last:
+// the defaults, the YAML file named by the -config flag or CONFIG_FILE,
+// environment variables, and command line flags. Every setting has a flag
+// named after its place in the file, such as -http.listen_addr, except
+// secrets, which would show up in the process list. Load registers its flags
+// on fs and parses args with it, so callers can add their own flags first.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := Default()
+	settings := collect(reflect.ValueOf(cfg).Elem(), "")
+
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML configuration `file`")
+	var flagged []*settingFlag
+	for _, s := range settings {
+		if s.flag {
+			f := &settingFlag{setting: s}
+			fs.Var(f, s.name, s.usage())
+			flagged = append(flagged, f)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(cfg, *configFile); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range settings {
+		if value := os.Getenv(s.env); s.env != "" && value != "" {
+			if err := s.set(value); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", s.env, err)
+			}
+		}
+	}
+	for _, f := range flagged {
+		if f.given {
+			if err := f.setting.set(f.raw); err != nil {
+				return nil, fmt.Errorf("invalid -%s: %w", f.setting.name, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// loadFile overlays the settings in a YAML file on cfg. Unknown keys are
+// errors so a misspelt setting is not silently ignored.
+func loadFile(cfg *Config, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// setting is one configurable field.
+type setting struct {
+	// Dotted path of YAML keys, used as the flag name
+	name  string
+	env   string
+	flag  bool
+	value reflect.Value
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// collect lists the settings in the struct v, recursing into nested
+// structs.
+func collect(v reflect.Value, prefix string) []*setting {
+	var settings []*setting
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := prefix + strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if field.Type.Kind() == reflect.Struct && field.Type != durationType {
+			settings = append(settings, collect(v.Field(i), name+".")...)
+			continue
+		}
+		settings = append(settings, &setting{
+			name:  name,
+			env:   field.Tag.Get("env"),
+			flag:  field.Tag.Get("flag") != "-",
+			value: v.Field(i),
+		})
+	}
+	return settings
+}
+
+func (s *setting) set(raw string) error {
+	switch {
+	case s.value.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(int64(d))
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(raw)
+	case s.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		s.value.SetBool(b)
+	case s.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(int64(n))
+	case s.value.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		s.value.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported setting type %s", s.value.Type())
+	}
+	return nil
+}
+
+func (s *setting) usage() string {
+	if s.env == "" {
+		return s.name
+	}
+	return "overrides " + s.env
+}
+
+// settingFlag holds a flag's value until the file and environment have been
+// applied, so flags take precedence over both.
+type settingFlag struct {
+	setting *setting
+	raw     string
+	given   bool
+}
+
+func (f *settingFlag) String() string {
+	if f == nil || f.setting == nil {
+		return ""
+	}
+	if f.given {
+		return f.raw
+	}
+	return fmt.Sprint(f.setting.value.Interface())
+}
+
+func (f *settingFlag) Set(raw string) error {
+	// Check the value now so flag parsing reports it
+	probe := setting{value: reflect.New(f.setting.value.Type()).Elem()}
+	if err := probe.set(raw); err != nil {
+		return err
+	}
+	f.raw, f.given = raw, true
+	return nil
+}
+
+func (f *settingFlag) IsBoolFlag() bool {
+	return f.setting != nil && f.setting.value.Kind() == reflect.Bool
+}
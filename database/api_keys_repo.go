@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"order-matching-engine/models"
+	"time"
+)
+
+func SaveAPIKey(ctx context.Context, key *models.APIKey) (err error) {
+	ctx, call := startCall(ctx, "SaveAPIKey")
+	defer func() { call.end(err) }()
+
+	query := `INSERT INTO api_keys (id, secret, account, scope, label, created_at) 
+			  VALUES (?, ?, ?, ?, ?, ?)`
+	_, err = DB.ExecContext(ctx, query, key.ID, key.Secret, key.Account, key.Scope, key.Label, key.CreatedAt)
+	return err
+}
+
+// GetAPIKey returns a key with its secret, or nil if there is none with id.
+// Revoked keys are returned too.
+func GetAPIKey(ctx context.Context, id string) (_ *models.APIKey, err error) {
+	ctx, call := startCall(ctx, "GetAPIKey")
+	defer func() { call.end(err) }()
+
+	query := `SELECT id, secret, account, scope, label, created_at, revoked_at 
+			  FROM api_keys WHERE id = ?`
+
+	key := &models.APIKey{}
+	err = DB.QueryRowContext(ctx, query, id).Scan(&key.ID, &key.Secret, &key.Account, &key.Scope, &key.Label,
+		&key.CreatedAt, &key.RevokedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return key, err
+}
+
+// ListAPIKeys returns the keys of an account, or of every account if account
+// is empty, newest first and without their secrets.
+func ListAPIKeys(ctx context.Context, account string) (_ []*models.APIKey, err error) {
+	ctx, call := startCall(ctx, "ListAPIKeys")
+	defer func() { call.end(err) }()
+
+	query := `SELECT id, account, scope, label, created_at, revoked_at FROM api_keys`
+	var args []interface{}
+	if account != "" {
+		query += ` WHERE account = ?`
+		args = append(args, account)
+	}
+	query += ` ORDER BY created_at DESC, id`
+
+	rows, err := DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]*models.APIKey, 0)
+	for rows.Next() {
+		key := &models.APIKey{}
+		if err := rows.Scan(&key.ID, &key.Account, &key.Scope, &key.Label, &key.CreatedAt, &key.RevokedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey marks a key revoked. It reports false if there is no such
+// key or it was already revoked.
+func RevokeAPIKey(ctx context.Context, id string, at time.Time) (_ bool, err error) {
+	ctx, call := startCall(ctx, "RevokeAPIKey")
+	defer func() { call.end(err) }()
+
+	result, err := DB.ExecContext(ctx, `UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, at, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
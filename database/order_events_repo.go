@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"order-matching-engine/models"
+	"order-matching-engine/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const maxReasonLength = 255
+
+// SaveOrderEvents records events outside a matching transaction, e.g. for
+// orders that were rejected before anything was persisted
+func SaveOrderEvents(ctx context.Context, events []*models.OrderEvent) (err error) {
+	ctx, call := startCall(ctx, "SaveOrderEvents", attribute.Int("events", len(events)))
+	defer func() { call.end(err) }()
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Will be ignored if tx.Commit() succeeds
+
+	if err := saveOrderEventsTx(ctx, tx, events); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetOrderEvents returns an order's lifecycle events in the order they happened
+func GetOrderEvents(ctx context.Context, orderID string) (_ []*models.OrderEvent, err error) {
+	ctx, call := startCall(ctx, "GetOrderEvents", tracing.OrderID(orderID))
+	defer func() { call.end(err) }()
+
+	query := `SELECT id, order_id, type, quantity, remaining_quantity, price, trade_id, reason, created_at 
+			  FROM order_events WHERE order_id = ? ORDER BY id`
+
+	rows, err := DB.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]*models.OrderEvent, 0)
+	for rows.Next() {
+		event := &models.OrderEvent{}
+		var tradeID sql.NullString
+		err := rows.Scan(&event.ID, &event.OrderID, &event.Type, &event.Quantity, &event.RemainingQuantity,
+			&event.Price, &tradeID, &event.Reason, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		event.TradeID = tradeID.String
+		events = append(events, event)
+	}
+
+	// Check for errors that occurred during iteration
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func saveOrderEventsTx(ctx context.Context, tx *sql.Tx, events []*models.OrderEvent) error {
+	query := `INSERT INTO order_events (order_id, type, quantity, remaining_quantity, price, trade_id, reason, created_at) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	for _, event := range events {
+		var tradeID sql.NullString
+		if event.TradeID != "" {
+			tradeID = sql.NullString{String: event.TradeID, Valid: true}
+		}
+		// Reject reasons carry error text of any length
+		reason := event.Reason
+		if len(reason) > maxReasonLength {
+			reason = reason[:maxReasonLength]
+		}
+		result, err := tx.ExecContext(ctx, query, event.OrderID, event.Type, event.Quantity, event.RemainingQuantity,
+			event.Price, tradeID, reason, event.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if event.ID, err = result.LastInsertId(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,33 +1,45 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"order-matching-engine/models"
+	"order-matching-engine/tracing"
+	"strings"
 )
 
-func SaveOrder(order *models.Order) error {
-	query := `INSERT INTO orders (id, symbol, side, type, price, initial_quantity, remaining_quantity, status, created_at) 
-			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+func SaveOrder(ctx context.Context, order *models.Order) (err error) {
+	ctx, call := startCall(ctx, "SaveOrder", tracing.OrderID(order.ID), tracing.Symbol(order.Symbol))
+	defer func() { call.end(err) }()
+
+	query := `INSERT INTO orders (id, symbol, account, side, type, price, initial_quantity, remaining_quantity, status, created_at) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	
-	_, err := DB.Exec(query, order.ID, order.Symbol, order.Side, order.Type, order.Price, 
+	_, err = DB.ExecContext(ctx, query, order.ID, order.Symbol, order.Account, order.Side, order.Type, order.Price, 
 		order.InitialQuantity, order.RemainingQuantity, order.Status, order.CreatedAt)
 	return err
 }
 
-func UpdateOrder(order *models.Order) error {
+func UpdateOrder(ctx context.Context, order *models.Order) (err error) {
+	ctx, call := startCall(ctx, "UpdateOrder", tracing.OrderID(order.ID), tracing.Symbol(order.Symbol))
+	defer func() { call.end(err) }()
+
 	query := `UPDATE orders SET remaining_quantity = ?, status = ? WHERE id = ?`
-	_, err := DB.Exec(query, order.RemainingQuantity, order.Status, order.ID)
+	_, err = DB.ExecContext(ctx, query, order.RemainingQuantity, order.Status, order.ID)
 	return err
 }
 
-func GetOrderByID(id string) (*models.Order, error) {
-	query := `SELECT id, symbol, side, type, price, initial_quantity, remaining_quantity, status, created_at 
+func GetOrderByID(ctx context.Context, id string) (_ *models.Order, err error) {
+	ctx, call := startCall(ctx, "GetOrderByID", tracing.OrderID(id))
+	defer func() { call.end(err) }()
+
+	query := `SELECT id, symbol, account, side, type, price, initial_quantity, remaining_quantity, status, created_at 
 			  FROM orders WHERE id = ?`
 	
-	row := DB.QueryRow(query, id)
+	row := DB.QueryRowContext(ctx, query, id)
 	order := &models.Order{}
 	
-	err := row.Scan(&order.ID, &order.Symbol, &order.Side, &order.Type, &order.Price,
+	err = row.Scan(&order.ID, &order.Symbol, &order.Account, &order.Side, &order.Type, &order.Price,
 		&order.InitialQuantity, &order.RemainingQuantity, &order.Status, &order.CreatedAt)
 	
 	if err == sql.ErrNoRows {
@@ -36,12 +48,15 @@ func GetOrderByID(id string) (*models.Order, error) {
 	return order, err
 }
 
-func GetOpenOrdersBySymbol(symbol string) ([]*models.Order, error) {
-	query := `SELECT id, symbol, side, type, price, initial_quantity, remaining_quantity, status, created_at 
+func GetOpenOrdersBySymbol(ctx context.Context, symbol string) (_ []*models.Order, err error) {
+	ctx, call := startCall(ctx, "GetOpenOrdersBySymbol", tracing.Symbol(symbol))
+	defer func() { call.end(err) }()
+
+	query := `SELECT id, symbol, account, side, type, price, initial_quantity, remaining_quantity, status, created_at 
 			  FROM orders WHERE symbol = ? AND status IN ('open', 'partial') 
 			  ORDER BY side, price, created_at`
 	
-	rows, err := DB.Query(query, symbol)
+	rows, err := DB.QueryContext(ctx, query, symbol)
 	if err != nil {
 		return nil, err
 	}
@@ -50,7 +65,7 @@ func GetOpenOrdersBySymbol(symbol string) ([]*models.Order, error) {
 	var orders []*models.Order
 	for rows.Next() {
 		order := &models.Order{}
-		err := rows.Scan(&order.ID, &order.Symbol, &order.Side, &order.Type, &order.Price,
+		err := rows.Scan(&order.ID, &order.Symbol, &order.Account, &order.Side, &order.Type, &order.Price,
 			&order.InitialQuantity, &order.RemainingQuantity, &order.Status, &order.CreatedAt)
 		if err != nil {
 			return nil, err
@@ -64,4 +79,105 @@ func GetOpenOrdersBySymbol(symbol string) ([]*models.Order, error) {
 	}
 	
 	return orders, nil
-}
\ No newline at end of file
+}
+
+// ListOrders returns one page of orders matching q. It fetches one extra row
+// to tell whether another page follows.
+func ListOrders(ctx context.Context, q models.OrderQuery) (_ *models.OrderPage, err error) {
+	ctx, call := startCall(ctx, "ListOrders")
+	defer func() { call.end(err) }()
+
+	var conditions []string
+	var args []interface{}
+
+	if q.Symbol != "" {
+		conditions = append(conditions, "symbol = ?")
+		args = append(args, q.Symbol)
+	}
+	if q.Account != "" {
+		conditions = append(conditions, "account = ?")
+		args = append(args, q.Account)
+	}
+	if q.Side != "" {
+		conditions = append(conditions, "side = ?")
+		args = append(args, q.Side)
+	}
+	if q.Type != "" {
+		conditions = append(conditions, "type = ?")
+		args = append(args, q.Type)
+	}
+	if len(q.Statuses) > 0 {
+		conditions = append(conditions, "status IN (?"+strings.Repeat(", ?", len(q.Statuses)-1)+")")
+		for _, status := range q.Statuses {
+			args = append(args, status)
+		}
+	}
+	if !q.From.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, q.From)
+	}
+	if !q.To.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, q.To)
+	}
+
+	direction, after := "DESC", "<"
+	if q.Ascending {
+		direction, after = "ASC", ">"
+	}
+	if q.After != nil {
+		conditions = append(conditions, "(created_at "+after+" ? OR (created_at = ? AND id "+after+" ?))")
+		args = append(args, q.After.Time, q.After.Time, q.After.ID)
+	}
+
+	query := `SELECT id, symbol, account, side, type, price, initial_quantity, remaining_quantity, status, created_at 
+			  FROM orders`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at " + direction + ", id " + direction + " LIMIT ?"
+	args = append(args, q.Limit+1)
+
+	rows, err := DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &models.OrderPage{Orders: make([]*models.Order, 0, q.Limit)}
+	for rows.Next() {
+		order := &models.Order{}
+		err := rows.Scan(&order.ID, &order.Symbol, &order.Account, &order.Side, &order.Type, &order.Price,
+			&order.InitialQuantity, &order.RemainingQuantity, &order.Status, &order.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		page.Orders = append(page.Orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Orders) > q.Limit {
+		page.Orders = page.Orders[:q.Limit]
+		last := page.Orders[q.Limit-1]
+		page.NextCursor = models.Cursor{Time: last.CreatedAt, ID: last.ID}.String()
+	}
+	return page, nil
+}
+
+// LastOrderIDWithPrefix returns the highest order ID starting with prefix, or
+// "" if there is none. Sequence IDs are fixed width, so the highest ID sorts last.
+func LastOrderIDWithPrefix(ctx context.Context, prefix string) (_ string, err error) {
+	ctx, call := startCall(ctx, "LastOrderIDWithPrefix")
+	defer func() { call.end(err) }()
+
+	query := `SELECT id FROM orders WHERE id LIKE CONCAT(?, '%') ORDER BY id DESC LIMIT 1`
+
+	var id string
+	err = DB.QueryRowContext(ctx, query, prefix).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
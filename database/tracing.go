@@ -0,0 +1,42 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"order-matching-engine/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("order-matching-engine/database")
+
+// call is a repository call in progress, traced and logged when it ends.
+type call struct {
+	ctx   context.Context
+	name  string
+	span  trace.Span
+	start time.Time
+}
+
+// startCall starts a span for a repository call. End it with call.end.
+func startCall(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, *call) {
+	attrs = append(attrs, semconv.DBSystemMySQL, semconv.DBOperationName(name))
+	ctx, span := tracer.Start(ctx, "database."+name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx, &call{ctx: ctx, name: name, span: span, start: time.Now()}
+}
+
+// end records err, if any, on the call's span and logs the call: failures
+// at error level, the rest at debug.
+func (c *call) end(err error) {
+	duration := float64(time.Since(c.start).Microseconds()) / 1000
+	if err != nil {
+		slog.ErrorContext(c.ctx, "database call failed", "operation", c.name, "duration_ms", duration, "error", err)
+	} else {
+		slog.DebugContext(c.ctx, "database call", "operation", c.name, "duration_ms", duration)
+	}
+	tracing.End(c.span, err)
+}
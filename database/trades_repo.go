@@ -1,21 +1,35 @@
 package database
 
-import "order-matching-engine/models"
+import (
+	"context"
+	"database/sql"
+	"order-matching-engine/models"
+	"order-matching-engine/tracing"
+	"strings"
+	"time"
+)
+
+func SaveTrade(ctx context.Context, trade *models.Trade) (err error) {
+	ctx, call := startCall(ctx, "SaveTrade", tracing.Symbol(trade.Symbol))
+	defer func() { call.end(err) }()
 
-func SaveTrade(trade *models.Trade) error {
 	query := `INSERT INTO trades (id, symbol, buy_order_id, sell_order_id, price, quantity, executed_at) 
 			  VALUES (?, ?, ?, ?, ?, ?, ?)`
 	
-	_, err := DB.Exec(query, trade.ID, trade.Symbol, trade.BuyOrderID, trade.SellOrderID,
+	_, err = DB.ExecContext(ctx, query, trade.ID, trade.Symbol, trade.BuyOrderID, trade.SellOrderID,
 		trade.Price, trade.Quantity, trade.ExecutedAt)
 	return err
 }
 
-func GetTradesBySymbol(symbol string) ([]*models.Trade, error) {
+// GetTradesSince returns every trade executed at or after since, oldest first
+func GetTradesSince(ctx context.Context, since time.Time) (_ []*models.Trade, err error) {
+	ctx, call := startCall(ctx, "GetTradesSince")
+	defer func() { call.end(err) }()
+
 	query := `SELECT id, symbol, buy_order_id, sell_order_id, price, quantity, executed_at 
-			  FROM trades WHERE symbol = ? ORDER BY executed_at DESC`
+			  FROM trades WHERE executed_at >= ? ORDER BY executed_at, id`
 	
-	rows, err := DB.Query(query, symbol)
+	rows, err := DB.QueryContext(ctx, query, since)
 	if err != nil {
 		return nil, err
 	}
@@ -40,17 +54,51 @@ func GetTradesBySymbol(symbol string) ([]*models.Trade, error) {
 	return trades, nil
 }
 
-func GetAllTrades() ([]*models.Trade, error) {
-	query := `SELECT id, symbol, buy_order_id, sell_order_id, price, quantity, executed_at 
-			  FROM trades ORDER BY executed_at DESC`
-	
-	rows, err := DB.Query(query)
+// ListTrades returns one page of trades matching q, newest first. It fetches
+// one extra row to tell whether another page follows.
+func ListTrades(ctx context.Context, q models.TradeQuery) (_ *models.TradePage, err error) {
+	ctx, call := startCall(ctx, "ListTrades")
+	defer func() { call.end(err) }()
+
+	var query string
+	var args []interface{}
+	if q.Account == "" {
+		conditions, conditionArgs := tradeConditions(q, "")
+		query = `SELECT id, symbol, buy_order_id, sell_order_id, price, quantity, executed_at FROM trades`
+		if len(conditions) > 0 {
+			query += " WHERE " + strings.Join(conditions, " AND ")
+		}
+		args = conditionArgs
+	} else {
+		// An account's trades are those of its buy orders and those of its
+		// sell orders. Each half is read through the account's orders and the
+		// trades index on that side, then they are merged; an OR of the two
+		// could use neither index. UNION drops trades where the account was
+		// on both sides from appearing twice.
+		var branches []string
+		for _, side := range []string{"buy_order_id", "sell_order_id"} {
+			conditions, conditionArgs := tradeConditions(q, "t.")
+			conditions = append([]string{"o.account = ?"}, conditions...)
+			branches = append(branches, `(SELECT t.id, t.symbol, t.buy_order_id, t.sell_order_id, t.price, t.quantity, t.executed_at 
+				FROM orders o JOIN trades t ON t.`+side+` = o.id WHERE `+strings.Join(conditions, " AND ")+`
+				ORDER BY t.executed_at DESC, t.id DESC LIMIT ?)`)
+			args = append(args, q.Account)
+			args = append(args, conditionArgs...)
+			args = append(args, q.Limit+1)
+		}
+		query = `SELECT id, symbol, buy_order_id, sell_order_id, price, quantity, executed_at FROM (` +
+			strings.Join(branches, " UNION ") + `) AS account_trades`
+	}
+	query += " ORDER BY executed_at DESC, id DESC LIMIT ?"
+	args = append(args, q.Limit+1)
+
+	rows, err := DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var trades []*models.Trade
+	page := &models.TradePage{Trades: make([]*models.Trade, 0, q.Limit)}
 	for rows.Next() {
 		trade := &models.Trade{}
 		err := rows.Scan(&trade.ID, &trade.Symbol, &trade.BuyOrderID, &trade.SellOrderID,
@@ -58,13 +106,61 @@ func GetAllTrades() ([]*models.Trade, error) {
 		if err != nil {
 			return nil, err
 		}
-		trades = append(trades, trade)
+		page.Trades = append(page.Trades, trade)
 	}
-	
-	// Check for errors that occurred during iteration
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-	
-	return trades, nil
-}
\ No newline at end of file
+
+	if len(page.Trades) > q.Limit {
+		page.Trades = page.Trades[:q.Limit]
+		last := page.Trades[q.Limit-1]
+		page.NextCursor = models.Cursor{Time: last.ExecutedAt, ID: last.ID}.String()
+	}
+	return page, nil
+}
+
+// LastTradeIDWithPrefix returns the highest trade ID starting with prefix, or
+// "" if there is none. Sequence IDs are fixed width, so the highest ID sorts last.
+func LastTradeIDWithPrefix(ctx context.Context, prefix string) (_ string, err error) {
+	ctx, call := startCall(ctx, "LastTradeIDWithPrefix")
+	defer func() { call.end(err) }()
+
+	query := `SELECT id FROM trades WHERE id LIKE CONCAT(?, '%') ORDER BY id DESC LIMIT 1`
+
+	var id string
+	err = DB.QueryRowContext(ctx, query, prefix).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
+// tradeConditions returns the filters of q other than its account, with
+// trades columns qualified by prefix.
+func tradeConditions(q models.TradeQuery, prefix string) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if q.Symbol != "" {
+		conditions = append(conditions, prefix+"symbol = ?")
+		args = append(args, q.Symbol)
+	}
+	if q.OrderID != "" {
+		conditions = append(conditions, "("+prefix+"buy_order_id = ? OR "+prefix+"sell_order_id = ?)")
+		args = append(args, q.OrderID, q.OrderID)
+	}
+	if !q.From.IsZero() {
+		conditions = append(conditions, prefix+"executed_at >= ?")
+		args = append(args, q.From)
+	}
+	if !q.To.IsZero() {
+		conditions = append(conditions, prefix+"executed_at < ?")
+		args = append(args, q.To)
+	}
+	if q.After != nil {
+		conditions = append(conditions, "("+prefix+"executed_at < ? OR ("+prefix+"executed_at = ? AND "+prefix+"id < ?))")
+		args = append(args, q.After.Time, q.After.Time, q.After.ID)
+	}
+	return conditions, args
+}
@@ -1,59 +1,145 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"order-matching-engine/models"
+	"order-matching-engine/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // ExecuteOrderMatching performs all order matching operations in a single transaction
-func ExecuteOrderMatching(order *models.Order, trades []*models.Trade, updatedOrders []*models.Order) error {
-	tx, err := DB.Begin()
+func ExecuteOrderMatching(ctx context.Context, order *models.Order, trades []*models.Trade, updatedOrders []*models.Order, events []*models.OrderEvent) (err error) {
+	ctx, call := startCall(ctx, "ExecuteOrderMatching", tracing.OrderID(order.ID), tracing.Symbol(order.Symbol),
+		attribute.Int("trades", len(trades)))
+	defer func() { call.end(err) }()
+
+	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback() // Will be ignored if tx.Commit() succeeds
 
 	// Save the new order
-	if err := saveOrderTx(tx, order); err != nil {
+	if err := saveOrderTx(ctx, tx, order); err != nil {
 		return fmt.Errorf("failed to save order: %w", err)
 	}
 
 	// Save all trades
 	for _, trade := range trades {
-		if err := saveTradeTx(tx, trade); err != nil {
+		if err := saveTradeTx(ctx, tx, trade); err != nil {
 			return fmt.Errorf("failed to save trade %s: %w", trade.ID, err)
 		}
 	}
 
 	// Update all modified orders
 	for _, updatedOrder := range updatedOrders {
-		if err := updateOrderTx(tx, updatedOrder); err != nil {
+		if err := updateOrderTx(ctx, tx, updatedOrder); err != nil {
 			return fmt.Errorf("failed to update order %s: %w", updatedOrder.ID, err)
 		}
 	}
 
+	// Record every order transition caused by the match
+	if err := saveOrderEventsTx(ctx, tx, events); err != nil {
+		return fmt.Errorf("failed to save order events: %w", err)
+	}
+
 	return tx.Commit()
 }
 
-func saveOrderTx(tx *sql.Tx, order *models.Order) error {
-	query := `INSERT INTO orders (id, symbol, side, type, price, initial_quantity, remaining_quantity, status, created_at) 
-			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	_, err := tx.Exec(query, order.ID, order.Symbol, order.Side, order.Type, order.Price, 
+// AmendOrder saves an amended order's new price and quantities together with
+// any trades the amendment caused, in a single transaction. created_at is
+// written too: an amend that loses the order its priority requeues it as of
+// the amend, and the book must be rebuilt in that order.
+func AmendOrder(ctx context.Context, order *models.Order, trades []*models.Trade, updatedOrders []*models.Order, events []*models.OrderEvent) (err error) {
+	ctx, call := startCall(ctx, "AmendOrder", tracing.OrderID(order.ID), tracing.Symbol(order.Symbol),
+		attribute.Int("trades", len(trades)))
+	defer func() { call.end(err) }()
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Will be ignored if tx.Commit() succeeds
+
+	if err := amendOrderTx(ctx, tx, order); err != nil {
+		return fmt.Errorf("failed to amend order: %w", err)
+	}
+	for _, trade := range trades {
+		if err := saveTradeTx(ctx, tx, trade); err != nil {
+			return fmt.Errorf("failed to save trade %s: %w", trade.ID, err)
+		}
+	}
+	for _, updatedOrder := range updatedOrders {
+		if err := updateOrderTx(ctx, tx, updatedOrder); err != nil {
+			return fmt.Errorf("failed to update order %s: %w", updatedOrder.ID, err)
+		}
+	}
+	if err := saveOrderEventsTx(ctx, tx, events); err != nil {
+		return fmt.Errorf("failed to save order events: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// CancelOrders marks every given order as cancelled and records their cancel
+// events in a single transaction
+func CancelOrders(ctx context.Context, orders []*models.Order, events []*models.OrderEvent) (err error) {
+	ctx, call := startCall(ctx, "CancelOrders", attribute.Int("orders", len(orders)))
+	defer func() { call.end(err) }()
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Will be ignored if tx.Commit() succeeds
+
+	for _, order := range orders {
+		if err := cancelOrderTx(ctx, tx, order.ID); err != nil {
+			return fmt.Errorf("failed to cancel order %s: %w", order.ID, err)
+		}
+	}
+
+	if err := saveOrderEventsTx(ctx, tx, events); err != nil {
+		return fmt.Errorf("failed to save order events: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func saveOrderTx(ctx context.Context, tx *sql.Tx, order *models.Order) error {
+	query := `INSERT INTO orders (id, symbol, account, side, type, price, initial_quantity, remaining_quantity, status, created_at) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := tx.ExecContext(ctx, query, order.ID, order.Symbol, order.Account, order.Side, order.Type, order.Price, 
 		order.InitialQuantity, order.RemainingQuantity, order.Status, order.CreatedAt)
 	return err
 }
 
-func saveTradeTx(tx *sql.Tx, trade *models.Trade) error {
+func saveTradeTx(ctx context.Context, tx *sql.Tx, trade *models.Trade) error {
 	query := `INSERT INTO trades (id, symbol, buy_order_id, sell_order_id, price, quantity, executed_at) 
 			  VALUES (?, ?, ?, ?, ?, ?, ?)`
-	_, err := tx.Exec(query, trade.ID, trade.Symbol, trade.BuyOrderID, trade.SellOrderID, 
+	_, err := tx.ExecContext(ctx, query, trade.ID, trade.Symbol, trade.BuyOrderID, trade.SellOrderID, 
 		trade.Price, trade.Quantity, trade.ExecutedAt)
 	return err
 }
 
-func updateOrderTx(tx *sql.Tx, order *models.Order) error {
+func updateOrderTx(ctx context.Context, tx *sql.Tx, order *models.Order) error {
 	query := `UPDATE orders SET remaining_quantity = ?, status = ? WHERE id = ?`
-	_, err := tx.Exec(query, order.RemainingQuantity, order.Status, order.ID)
+	_, err := tx.ExecContext(ctx, query, order.RemainingQuantity, order.Status, order.ID)
 	return err
-}
\ No newline at end of file
+}
+
+func amendOrderTx(ctx context.Context, tx *sql.Tx, order *models.Order) error {
+	query := `UPDATE orders SET price = ?, initial_quantity = ?, remaining_quantity = ?, status = ?, created_at = ? WHERE id = ?`
+	_, err := tx.ExecContext(ctx, query, order.Price, order.InitialQuantity, order.RemainingQuantity, order.Status,
+		order.CreatedAt, order.ID)
+	return err
+}
+
+func cancelOrderTx(ctx context.Context, tx *sql.Tx, orderID string) error {
+	query := `UPDATE orders SET status = 'cancelled' WHERE id = ?`
+	_, err := tx.ExecContext(ctx, query, orderID)
+	return err
+}
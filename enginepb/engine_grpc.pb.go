@@ -0,0 +1,339 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: engine.proto
+
+package enginepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	MatchingEngine_PlaceOrder_FullMethodName      = "/engine.v1.MatchingEngine/PlaceOrder"
+	MatchingEngine_CancelOrder_FullMethodName     = "/engine.v1.MatchingEngine/CancelOrder"
+	MatchingEngine_GetOrder_FullMethodName        = "/engine.v1.MatchingEngine/GetOrder"
+	MatchingEngine_SubscribeBook_FullMethodName   = "/engine.v1.MatchingEngine/SubscribeBook"
+	MatchingEngine_SubscribeTrades_FullMethodName = "/engine.v1.MatchingEngine/SubscribeTrades"
+)
+
+// MatchingEngineClient is the client API for MatchingEngine service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// MatchingEngine is the gRPC counterpart of the REST order and market data
+// API. Both front the same engine, so orders entered over either are matched
+// against each other.
+type MatchingEngineClient interface {
+	// PlaceOrder validates and matches a new order, returning it as it stands
+	// after matching along with the trades it made.
+	PlaceOrder(ctx context.Context, in *PlaceOrderRequest, opts ...grpc.CallOption) (*PlaceOrderResponse, error)
+	// CancelOrder cancels a resting order.
+	CancelOrder(ctx context.Context, in *CancelOrderRequest, opts ...grpc.CallOption) (*CancelOrderResponse, error)
+	GetOrder(ctx context.Context, in *GetOrderRequest, opts ...grpc.CallOption) (*Order, error)
+	// SubscribeBook streams a snapshot of a symbol's price levels followed by
+	// every change to them.
+	SubscribeBook(ctx context.Context, in *SubscribeBookRequest, opts ...grpc.CallOption) (MatchingEngine_SubscribeBookClient, error)
+	// SubscribeTrades streams the trades of one or more symbols as they
+	// execute.
+	SubscribeTrades(ctx context.Context, in *SubscribeTradesRequest, opts ...grpc.CallOption) (MatchingEngine_SubscribeTradesClient, error)
+}
+
+type matchingEngineClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMatchingEngineClient(cc grpc.ClientConnInterface) MatchingEngineClient {
+	return &matchingEngineClient{cc}
+}
+
+func (c *matchingEngineClient) PlaceOrder(ctx context.Context, in *PlaceOrderRequest, opts ...grpc.CallOption) (*PlaceOrderResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PlaceOrderResponse)
+	err := c.cc.Invoke(ctx, MatchingEngine_PlaceOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *matchingEngineClient) CancelOrder(ctx context.Context, in *CancelOrderRequest, opts ...grpc.CallOption) (*CancelOrderResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CancelOrderResponse)
+	err := c.cc.Invoke(ctx, MatchingEngine_CancelOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *matchingEngineClient) GetOrder(ctx context.Context, in *GetOrderRequest, opts ...grpc.CallOption) (*Order, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Order)
+	err := c.cc.Invoke(ctx, MatchingEngine_GetOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *matchingEngineClient) SubscribeBook(ctx context.Context, in *SubscribeBookRequest, opts ...grpc.CallOption) (MatchingEngine_SubscribeBookClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MatchingEngine_ServiceDesc.Streams[0], MatchingEngine_SubscribeBook_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &matchingEngineSubscribeBookClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type MatchingEngine_SubscribeBookClient interface {
+	Recv() (*BookUpdate, error)
+	grpc.ClientStream
+}
+
+type matchingEngineSubscribeBookClient struct {
+	grpc.ClientStream
+}
+
+func (x *matchingEngineSubscribeBookClient) Recv() (*BookUpdate, error) {
+	m := new(BookUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *matchingEngineClient) SubscribeTrades(ctx context.Context, in *SubscribeTradesRequest, opts ...grpc.CallOption) (MatchingEngine_SubscribeTradesClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MatchingEngine_ServiceDesc.Streams[1], MatchingEngine_SubscribeTrades_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &matchingEngineSubscribeTradesClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type MatchingEngine_SubscribeTradesClient interface {
+	Recv() (*Trade, error)
+	grpc.ClientStream
+}
+
+type matchingEngineSubscribeTradesClient struct {
+	grpc.ClientStream
+}
+
+func (x *matchingEngineSubscribeTradesClient) Recv() (*Trade, error) {
+	m := new(Trade)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MatchingEngineServer is the server API for MatchingEngine service.
+// All implementations must embed UnimplementedMatchingEngineServer
+// for forward compatibility
+//
+// MatchingEngine is the gRPC counterpart of the REST order and market data
+// API. Both front the same engine, so orders entered over either are matched
+// against each other.
+type MatchingEngineServer interface {
+	// PlaceOrder validates and matches a new order, returning it as it stands
+	// after matching along with the trades it made.
+	PlaceOrder(context.Context, *PlaceOrderRequest) (*PlaceOrderResponse, error)
+	// CancelOrder cancels a resting order.
+	CancelOrder(context.Context, *CancelOrderRequest) (*CancelOrderResponse, error)
+	GetOrder(context.Context, *GetOrderRequest) (*Order, error)
+	// SubscribeBook streams a snapshot of a symbol's price levels followed by
+	// every change to them.
+	SubscribeBook(*SubscribeBookRequest, MatchingEngine_SubscribeBookServer) error
+	// SubscribeTrades streams the trades of one or more symbols as they
+	// execute.
+	SubscribeTrades(*SubscribeTradesRequest, MatchingEngine_SubscribeTradesServer) error
+	mustEmbedUnimplementedMatchingEngineServer()
+}
+
+// UnimplementedMatchingEngineServer must be embedded to have forward compatible implementations.
+type UnimplementedMatchingEngineServer struct {
+}
+
+func (UnimplementedMatchingEngineServer) PlaceOrder(context.Context, *PlaceOrderRequest) (*PlaceOrderResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PlaceOrder not implemented")
+}
+func (UnimplementedMatchingEngineServer) CancelOrder(context.Context, *CancelOrderRequest) (*CancelOrderResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelOrder not implemented")
+}
+func (UnimplementedMatchingEngineServer) GetOrder(context.Context, *GetOrderRequest) (*Order, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOrder not implemented")
+}
+func (UnimplementedMatchingEngineServer) SubscribeBook(*SubscribeBookRequest, MatchingEngine_SubscribeBookServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeBook not implemented")
+}
+func (UnimplementedMatchingEngineServer) SubscribeTrades(*SubscribeTradesRequest, MatchingEngine_SubscribeTradesServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeTrades not implemented")
+}
+func (UnimplementedMatchingEngineServer) mustEmbedUnimplementedMatchingEngineServer() {}
+
+// UnsafeMatchingEngineServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MatchingEngineServer will
+// result in compilation errors.
+type UnsafeMatchingEngineServer interface {
+	mustEmbedUnimplementedMatchingEngineServer()
+}
+
+func RegisterMatchingEngineServer(s grpc.ServiceRegistrar, srv MatchingEngineServer) {
+	s.RegisterService(&MatchingEngine_ServiceDesc, srv)
+}
+
+func _MatchingEngine_PlaceOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PlaceOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MatchingEngineServer).PlaceOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MatchingEngine_PlaceOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MatchingEngineServer).PlaceOrder(ctx, req.(*PlaceOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MatchingEngine_CancelOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MatchingEngineServer).CancelOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MatchingEngine_CancelOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MatchingEngineServer).CancelOrder(ctx, req.(*CancelOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MatchingEngine_GetOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MatchingEngineServer).GetOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MatchingEngine_GetOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MatchingEngineServer).GetOrder(ctx, req.(*GetOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MatchingEngine_SubscribeBook_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeBookRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MatchingEngineServer).SubscribeBook(m, &matchingEngineSubscribeBookServer{ServerStream: stream})
+}
+
+type MatchingEngine_SubscribeBookServer interface {
+	Send(*BookUpdate) error
+	grpc.ServerStream
+}
+
+type matchingEngineSubscribeBookServer struct {
+	grpc.ServerStream
+}
+
+func (x *matchingEngineSubscribeBookServer) Send(m *BookUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _MatchingEngine_SubscribeTrades_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeTradesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MatchingEngineServer).SubscribeTrades(m, &matchingEngineSubscribeTradesServer{ServerStream: stream})
+}
+
+type MatchingEngine_SubscribeTradesServer interface {
+	Send(*Trade) error
+	grpc.ServerStream
+}
+
+type matchingEngineSubscribeTradesServer struct {
+	grpc.ServerStream
+}
+
+func (x *matchingEngineSubscribeTradesServer) Send(m *Trade) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// MatchingEngine_ServiceDesc is the grpc.ServiceDesc for MatchingEngine service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MatchingEngine_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "engine.v1.MatchingEngine",
+	HandlerType: (*MatchingEngineServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "PlaceOrder",
+			Handler:    _MatchingEngine_PlaceOrder_Handler,
+		},
+		{
+			MethodName: "CancelOrder",
+			Handler:    _MatchingEngine_CancelOrder_Handler,
+		},
+		{
+			MethodName: "GetOrder",
+			Handler:    _MatchingEngine_GetOrder_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeBook",
+			Handler:       _MatchingEngine_SubscribeBook_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SubscribeTrades",
+			Handler:       _MatchingEngine_SubscribeTrades_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "engine.proto",
+}
@@ -0,0 +1,5 @@
+// Package enginepb holds the protobuf messages and gRPC service definition
+// for the engine's gRPC API, generated from engine.proto.
+package enginepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative engine.proto
@@ -0,0 +1,656 @@
+package fix
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"order-matching-engine/clock"
+	"order-matching-engine/idgen"
+	"order-matching-engine/metrics"
+	"order-matching-engine/models"
+	"order-matching-engine/services"
+	"order-matching-engine/utils"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	logonTimeout    = 10 * time.Second
+	maxHeartBtInt   = 300
+	readBufferBytes = 64 * 1024
+)
+
+// ExecType (tag 150) and OrdStatus (tag 39) values
+const (
+	ExecTypeNew      = "0"
+	ExecTypeCanceled = "4"
+	ExecTypeReplaced = "5"
+	ExecTypeRejected = "8"
+	ExecTypeTrade    = "F"
+
+	OrdStatusNew             = "0"
+	OrdStatusPartiallyFilled = "1"
+	OrdStatusFilled          = "2"
+	OrdStatusCanceled        = "4"
+	OrdStatusRejected        = "8"
+)
+
+// OrdRejReason (103), CxlRejReason (102) and BusinessRejectReason (380)
+const (
+	ordRejExchangeClosed      = 2
+	ordRejDuplicateOrder      = 6
+	ordRejOther               = 99
+	cxlRejTooLate             = 0
+	cxlRejUnknownOrder        = 1
+	cxlRejBrokerOption        = 2
+	cxlRejAlreadyPending      = 3
+	cxlRejDuplicateClOrdID    = 6
+	businessRejectUnsupported = 3
+)
+
+// CxlRejResponseTo (434) values
+const (
+	cxlRejResponseToCancel  = "1"
+	cxlRejResponseToReplace = "2"
+)
+
+var (
+	fixSides    = map[string]string{"1": "buy", "2": "sell"}
+	fixOrdTypes = map[string]string{"1": "market", "2": "limit"}
+)
+
+type Config struct {
+	ListenAddr string
+	// Our CompID; counterparties send it as TargetCompID
+	CompID   string
+	StoreDir string
+	// Sessions maps the SenderCompID of every counterparty allowed to log
+	// on to the account its orders are placed for. An empty account takes
+	// the Account tag from each order instead.
+	Sessions map[string]string
+}
+
+// ParseSessions reads a session list of the form "COMPID[:account],...".
+func ParseSessions(spec string) (map[string]string, error) {
+	sessions := make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		compID, account, _ := strings.Cut(entry, ":")
+		if compID == "" {
+			return nil, fmt.Errorf("invalid FIX session %q", entry)
+		}
+		if _, exists := sessions[compID]; exists {
+			return nil, fmt.Errorf("duplicate FIX session %q", compID)
+		}
+		sessions[compID] = account
+	}
+	return sessions, nil
+}
+
+// Acceptor accepts FIX 4.4 order entry sessions and reports on the orders
+// entered through them. It must be registered with the engine as a listener
+// so it sees the fills and cancels that happen to those orders.
+type Acceptor struct {
+	cfg      Config
+	clock    clock.Clock
+	orderIDs idgen.Generator
+	engine   *services.MatchingEngine
+	sessions map[string]*Session
+	orders   *orderRegistry
+}
+
+func NewAcceptor(cfg Config, clk clock.Clock, orderIDs idgen.Generator) (*Acceptor, error) {
+	if cfg.CompID == "" {
+		return nil, errors.New("FIX CompID is required")
+	}
+	if len(cfg.Sessions) == 0 {
+		return nil, errors.New("at least one FIX session must be configured")
+	}
+
+	orders, err := openOrderRegistry(cfg.StoreDir)
+	if err != nil {
+		return nil, err
+	}
+	a := &Acceptor{
+		cfg:      cfg,
+		clock:    clk,
+		orderIDs: orderIDs,
+		sessions: make(map[string]*Session),
+		orders:   orders,
+	}
+	for compID, account := range cfg.Sessions {
+		store, err := OpenFileStore(cfg.StoreDir, cfg.CompID, compID)
+		if err != nil {
+			return nil, err
+		}
+		session := newSession(cfg.CompID, compID, account, store, clk)
+		go session.flushLoop()
+		a.sessions[compID] = session
+	}
+	return a, nil
+}
+
+// ListenAndServe accepts connections on cfg.ListenAddr and routes orders to
+// engine. It only returns if the listener fails.
+func (a *Acceptor) ListenAndServe(engine *services.MatchingEngine) error {
+	a.engine = engine
+
+	listener, err := net.Listen("tcp", a.cfg.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for FIX connections: %w", err)
+	}
+	defer listener.Close()
+
+	compIDs := make([]string, 0, len(a.sessions))
+	for compID := range a.sessions {
+		compIDs = append(compIDs, compID)
+	}
+	sort.Strings(compIDs)
+	slog.Info("FIX acceptor listening", "comp_id", a.cfg.CompID, "addr", listener.Addr().String(), "sessions", strings.Join(compIDs, ","))
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return fmt.Errorf("failed to accept FIX connection: %w", err)
+		}
+		go a.serveConn(conn)
+	}
+}
+
+// serveConn authenticates the opening Logon and hands the connection to its
+// session. Connections that do not log on as a configured session are
+// dropped without a reply.
+func (a *Acceptor) serveConn(conn net.Conn) {
+	r := bufio.NewReaderSize(conn, readBufferBytes)
+	conn.SetReadDeadline(time.Now().Add(logonTimeout))
+	logon, err := ReadMessage(r)
+	if err != nil {
+		slog.Warn("FIX connection without valid Logon", "remote_addr", conn.RemoteAddr().String(), "error", err)
+		conn.Close()
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	session := a.sessions[getString(logon, TagSenderCompID)]
+	heartBtInt, _ := logon.Int(TagHeartBtInt)
+	encryptMethod, hasEncryptMethod := logon.Get(TagEncryptMethod)
+	switch {
+	case logon.MsgType() != MsgLogon:
+		err = errors.New("first message is not a Logon")
+	case session == nil:
+		err = fmt.Errorf("unknown SenderCompID %q", getString(logon, TagSenderCompID))
+	case getString(logon, TagTargetCompID) != a.cfg.CompID:
+		err = fmt.Errorf("TargetCompID %q is not ours", getString(logon, TagTargetCompID))
+	case heartBtInt <= 0 || heartBtInt > maxHeartBtInt:
+		err = fmt.Errorf("HeartBtInt must be between 1 and %d", maxHeartBtInt)
+	case hasEncryptMethod && encryptMethod != "0":
+		err = errors.New("encryption is not supported")
+	}
+	if err != nil {
+		slog.Warn("FIX Logon refused", "remote_addr", conn.RemoteAddr().String(), "error", err)
+		conn.Close()
+		return
+	}
+
+	session.run(conn, r, logon, time.Duration(heartBtInt)*time.Second, func(msg *Message) {
+		a.handleApp(session, msg)
+	})
+}
+
+func (a *Acceptor) handleApp(s *Session, msg *Message) {
+	switch msg.MsgType() {
+	case MsgNewOrderSingle:
+		a.newOrder(s, msg)
+	case MsgOrderCancelRequest:
+		a.cancelOrder(s, msg)
+	case MsgOrderCancelReplaceRequest:
+		a.replaceOrder(s, msg)
+	default:
+		seq, _ := msg.Int(TagMsgSeqNum)
+		s.sendAdmin(NewMessage(MsgBusinessMessageReject).
+			SetInt(TagRefSeqNum, seq).
+			Set(TagRefMsgType, msg.MsgType()).
+			SetInt(TagBusinessRejectReason, businessRejectUnsupported).
+			Set(TagText, "Unsupported message type"))
+	}
+}
+
+func (a *Acceptor) newOrder(s *Session, msg *Message) {
+	if !requireTags(s, msg, TagClOrdID, TagSymbol, TagSide, TagOrderQty, TagOrdType) {
+		return
+	}
+	clOrdID := getString(msg, TagClOrdID)
+
+	req := models.PlaceOrderRequest{
+		Symbol:  getString(msg, TagSymbol),
+		Account: s.account,
+		Side:    fixSides[getString(msg, TagSide)],
+		Type:    fixOrdTypes[getString(msg, TagOrdType)],
+	}
+	if account, found := msg.Get(TagAccount); found {
+		if s.account != "" && account != s.account {
+			a.rejectOrder(s, msg, "NONE", ordRejOther, "Account not permitted for this session")
+			return
+		}
+		req.Account = account
+	}
+	if req.Side == "" {
+		a.rejectOrder(s, msg, "NONE", ordRejOther, "Unsupported Side")
+		return
+	}
+	if req.Type == "" {
+		a.rejectOrder(s, msg, "NONE", ordRejOther, "Unsupported OrdType")
+		return
+	}
+	var ok bool
+	if req.Quantity, ok = msg.Int(TagOrderQty); !ok {
+		a.rejectOrder(s, msg, "NONE", ordRejOther, "OrderQty must be a whole number")
+		return
+	}
+	if _, found := msg.Get(TagPrice); found {
+		price, ok := msg.Float(TagPrice)
+		if !ok {
+			a.rejectOrder(s, msg, "NONE", ordRejOther, "Invalid Price")
+			return
+		}
+		req.Price = &price
+	}
+	if err := req.Validate(); err != nil {
+		a.rejectOrder(s, msg, "NONE", ordRejOther, err.Error())
+		return
+	}
+
+	order := &models.Order{
+		ID:                a.orderIDs.NewID(),
+		Symbol:            req.Symbol,
+		Account:           req.Account,
+		Side:              req.Side,
+		Type:              req.Type,
+		Price:             req.Price,
+		InitialQuantity:   req.Quantity,
+		RemainingQuantity: req.Quantity,
+		Status:            "open",
+	}
+	rec := &orderRecord{
+		OrderID:  order.ID,
+		Session:  s.theirs,
+		ClOrdID:  clOrdID,
+		Account:  order.Account,
+		Symbol:   order.Symbol,
+		Side:     order.Side,
+		Type:     order.Type,
+		Price:    order.Price,
+		OrderQty: order.InitialQuantity,
+	}
+
+	// Register the order before the engine sees it so the listener can
+	// report on it
+	a.orders.mu.Lock()
+	if a.orders.inUse(s.theirs, clOrdID) {
+		a.orders.mu.Unlock()
+		a.rejectOrder(s, msg, "NONE", ordRejDuplicateOrder, "Duplicate ClOrdID")
+		return
+	}
+	a.orders.add(rec)
+	a.saveRecord(rec)
+	a.orders.mu.Unlock()
+
+	if _, err := a.engine.ProcessOrder(context.Background(), order); err != nil {
+		a.orders.mu.Lock()
+		rec.Done = true
+		a.saveRecord(rec)
+		a.orders.mu.Unlock()
+		a.rejectOrder(s, msg, order.ID, orderRejectReason(err), err.Error())
+	}
+}
+
+func (a *Acceptor) cancelOrder(s *Session, msg *Message) {
+	if !requireTags(s, msg, TagClOrdID) {
+		return
+	}
+	clOrdID := getString(msg, TagClOrdID)
+
+	a.orders.mu.Lock()
+	rec, reason, text := a.pendingTarget(s, msg)
+	if rec == nil {
+		a.orders.mu.Unlock()
+		a.rejectCancel(s, msg, cxlRejResponseToCancel, nil, reason, text)
+		return
+	}
+	rec.pendingCancel = clOrdID
+	orderID := rec.OrderID
+	a.orders.mu.Unlock()
+
+	// The listener reports the cancel when the engine commits it
+	if err := a.engine.CancelOrder(orderID); err != nil {
+		a.orders.mu.Lock()
+		rec.pendingCancel = ""
+		a.orders.mu.Unlock()
+		a.rejectCancel(s, msg, cxlRejResponseToCancel, rec, cancelRejectReason(err), err.Error())
+	}
+}
+
+func (a *Acceptor) replaceOrder(s *Session, msg *Message) {
+	if !requireTags(s, msg, TagClOrdID, TagOrderQty) {
+		return
+	}
+	quantity, ok := msg.Int(TagOrderQty)
+	if !ok {
+		s.reject(seqNum(msg), TagOrderQty, rejectIncorrectDataFormat, "OrderQty must be a whole number")
+		return
+	}
+	var price *float64
+	if _, found := msg.Get(TagPrice); found {
+		value, ok := msg.Float(TagPrice)
+		if !ok {
+			s.reject(seqNum(msg), TagPrice, rejectIncorrectDataFormat, "Invalid Price")
+			return
+		}
+		price = &value
+	}
+
+	a.orders.mu.Lock()
+	rec, reason, text := a.pendingTarget(s, msg)
+	if rec == nil {
+		a.orders.mu.Unlock()
+		a.rejectCancel(s, msg, cxlRejResponseToReplace, nil, reason, text)
+		return
+	}
+	if side, found := msg.Get(TagSide); found && fixSides[side] != rec.Side {
+		rec = nil
+		text = "Side cannot be changed"
+	} else if symbol, found := msg.Get(TagSymbol); found && symbol != rec.Symbol {
+		rec = nil
+		text = "Symbol cannot be changed"
+	}
+	if rec == nil {
+		a.orders.mu.Unlock()
+		a.rejectCancel(s, msg, cxlRejResponseToReplace, nil, cxlRejBrokerOption, text)
+		return
+	}
+	newPrice := price
+	if newPrice == nil {
+		newPrice = rec.Price
+	}
+	rec.pendingReplace = &replaceRequest{ClOrdID: getString(msg, TagClOrdID), Quantity: quantity, Price: newPrice}
+	orderID := rec.OrderID
+	a.orders.mu.Unlock()
+
+	// The listener normally reports the replace from the engine's events,
+	// ahead of any fills it causes
+	_, _, err := a.engine.AmendOrder(orderID, quantity, price)
+
+	a.orders.mu.Lock()
+	defer a.orders.mu.Unlock()
+	if err != nil {
+		rec.pendingReplace = nil
+		a.rejectCancel(s, msg, cxlRejResponseToReplace, rec, cancelRejectReason(err), err.Error())
+		return
+	}
+	if rec.pendingReplace != nil && !rec.Done {
+		a.replaced(rec)
+	}
+}
+
+// pendingTarget finds the order a cancel or cancel/replace request refers
+// to and checks that it can take one. On failure it returns a nil record
+// and the reason. It must be called with a.orders.mu held.
+func (a *Acceptor) pendingTarget(s *Session, msg *Message) (*orderRecord, int, string) {
+	rec := a.orders.lookup(s.theirs, getString(msg, TagOrigClOrdID), getString(msg, TagOrderID))
+	switch {
+	case rec == nil:
+		return nil, cxlRejUnknownOrder, "Unknown order"
+	case rec.pendingCancel != "" || rec.pendingReplace != nil:
+		return nil, cxlRejAlreadyPending, "Order already has a cancel or replace pending"
+	case a.orders.inUse(s.theirs, getString(msg, TagClOrdID)):
+		return nil, cxlRejDuplicateClOrdID, "Duplicate ClOrdID"
+	}
+	return rec, 0, ""
+}
+
+func orderRejectReason(err error) int {
+	if errors.Is(err, utils.ErrSymbolHalted) || errors.Is(err, utils.ErrMaintenanceMode) {
+		return ordRejExchangeClosed
+	}
+	return ordRejOther
+}
+
+func cancelRejectReason(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrOrderNotFound):
+		return cxlRejUnknownOrder
+	case errors.Is(err, utils.ErrSymbolHalted), errors.Is(err, utils.ErrMaintenanceMode):
+		return cxlRejBrokerOption
+	}
+	return cxlRejTooLate
+}
+
+// OnEvent implements services.Listener. It turns engine events on orders
+// entered over FIX into ExecutionReports.
+func (a *Acceptor) OnEvent(event services.Event) {
+	switch event.Type {
+	case services.EventTrade:
+		a.orders.mu.Lock()
+		defer a.orders.mu.Unlock()
+
+		trade := event.Trade
+		for _, side := range []struct{ orderID, side string }{{trade.BuyOrderID, "buy"}, {trade.SellOrderID, "sell"}} {
+			rec := a.orders.byOrderID[side.orderID]
+			if rec == nil {
+				continue
+			}
+			if !rec.Acked {
+				a.acknowledge(rec)
+			} else if rec.pendingReplace != nil && event.Aggressor == side.side {
+				// A resting order only takes liquidity when a replace
+				// requeues it at a crossing price
+				a.replaced(rec)
+			}
+
+			rec.CumQty += trade.Quantity
+			rec.CumNotional += trade.Price * float64(trade.Quantity)
+			rec.Done = rec.CumQty >= rec.OrderQty
+			report := a.report(rec, ExecTypeTrade, rec.ordStatus()).
+				SetInt(TagLastQty, trade.Quantity).
+				SetFloat(TagLastPx, trade.Price).
+				SetTime(TagTransactTime, trade.ExecutedAt)
+			a.send(rec, report)
+		}
+	case services.EventOrderStatus:
+		a.orders.mu.Lock()
+		defer a.orders.mu.Unlock()
+
+		order := event.Order
+		rec := a.orders.byOrderID[order.ID]
+		if rec == nil {
+			return
+		}
+		if !rec.Acked {
+			a.acknowledge(rec)
+		}
+		if p := rec.pendingReplace; p != nil && order.Status != "cancelled" && order.InitialQuantity == p.Quantity && samePrice(order.Price, p.Price) {
+			a.replaced(rec)
+		}
+		if order.Status != "cancelled" {
+			return
+		}
+
+		origClOrdID := ""
+		if rec.pendingCancel != "" {
+			origClOrdID = rec.ClOrdID
+			rec.ClOrdID = rec.pendingCancel
+			rec.pendingCancel = ""
+		}
+		rec.Done = true
+		report := a.report(rec, ExecTypeCanceled, OrdStatusCanceled)
+		if origClOrdID != "" {
+			report.Set(TagOrigClOrdID, origClOrdID)
+		}
+		a.send(rec, report)
+	}
+}
+
+// The helpers below must be called with a.orders.mu held.
+
+func (a *Acceptor) acknowledge(rec *orderRecord) {
+	rec.Acked = true
+	report := a.report(rec, ExecTypeNew, OrdStatusNew)
+	a.send(rec, report)
+}
+
+// replaced applies the pending cancel/replace to rec and confirms it.
+func (a *Acceptor) replaced(rec *orderRecord) {
+	p := rec.pendingReplace
+	origClOrdID := rec.ClOrdID
+	rec.pendingReplace = nil
+	rec.ClOrdID = p.ClOrdID
+	rec.OrderQty = p.Quantity
+	rec.Price = p.Price
+	a.orders.addClOrdID(rec, p.ClOrdID)
+
+	report := a.report(rec, ExecTypeReplaced, rec.ordStatus()).Set(TagOrigClOrdID, origClOrdID)
+	a.send(rec, report)
+}
+
+// report builds an ExecutionReport on rec with a fresh ExecID.
+func (a *Acceptor) report(rec *orderRecord, execType, ordStatus string) *Message {
+	rec.ExecSeq++
+	msg := NewMessage(MsgExecutionReport).
+		Set(TagOrderID, rec.OrderID).
+		Set(TagClOrdID, rec.ClOrdID).
+		Set(TagExecID, fmt.Sprintf("%s-%d", rec.OrderID, rec.ExecSeq)).
+		Set(TagExecType, execType).
+		Set(TagOrdStatus, ordStatus).
+		Set(TagSymbol, rec.Symbol).
+		Set(TagSide, fixCode(fixSides, rec.Side)).
+		Set(TagOrdType, fixCode(fixOrdTypes, rec.Type)).
+		SetInt(TagOrderQty, rec.OrderQty).
+		SetInt(TagLeavesQty, rec.leavesQty()).
+		SetInt(TagCumQty, rec.CumQty).
+		SetFloat(TagAvgPx, rec.avgPx()).
+		SetTime(TagTransactTime, a.clock.Now())
+	if rec.Price != nil {
+		msg.SetFloat(TagPrice, *rec.Price)
+	}
+	if rec.Account != "" {
+		msg.Set(TagAccount, rec.Account)
+	}
+	return msg
+}
+
+func (a *Acceptor) saveRecord(rec *orderRecord) {
+	line, err := a.orders.save(rec)
+	if err == nil {
+		err = a.orders.write(rec.OrderID, line)
+	}
+	if err != nil {
+		slog.Error("failed to save FIX order record", "order_id", rec.OrderID, "error", err)
+	}
+}
+
+// send queues msg on rec's session behind a write of rec's current state.
+// It is called from the engine listener, so the disk writes are left to the
+// session's flush goroutine.
+func (a *Acceptor) send(rec *orderRecord, msg *Message) {
+	session := a.sessions[rec.Session]
+	if session == nil {
+		slog.Error("FIX order belongs to unknown session", "order_id", rec.OrderID, "session", rec.Session)
+		return
+	}
+	orderID := rec.OrderID
+	line, err := a.orders.save(rec)
+	if err != nil {
+		slog.Error("failed to save FIX order record", "order_id", orderID, "error", err)
+		session.Queue(msg, nil)
+		return
+	}
+	session.Queue(msg, func() error {
+		return a.orders.write(orderID, line)
+	})
+}
+
+// rejectOrder answers a NewOrderSingle that was not accepted. orderID is
+// "NONE" if the order never reached the engine.
+func (a *Acceptor) rejectOrder(s *Session, msg *Message, orderID string, reason int, text string) {
+	// The engine counts the orders it rejects itself
+	if orderID == "NONE" {
+		metrics.OrderRejected(metrics.ReasonInvalid)
+		slog.Warn("order rejected", "session", s.theirs, "cl_ord_id", getString(msg, TagClOrdID), "symbol", getString(msg, TagSymbol),
+			"reason", metrics.ReasonInvalid, "error", text)
+	}
+	report := NewMessage(MsgExecutionReport).
+		Set(TagOrderID, orderID).
+		Set(TagClOrdID, getString(msg, TagClOrdID)).
+		Set(TagExecID, fmt.Sprintf("%s-R%d", s.theirs, seqNum(msg))).
+		Set(TagExecType, ExecTypeRejected).
+		Set(TagOrdStatus, OrdStatusRejected).
+		Set(TagSymbol, getString(msg, TagSymbol)).
+		Set(TagSide, getString(msg, TagSide)).
+		Set(TagOrdType, getString(msg, TagOrdType)).
+		Set(TagOrderQty, getString(msg, TagOrderQty)).
+		SetInt(TagLeavesQty, 0).
+		SetInt(TagCumQty, 0).
+		SetFloat(TagAvgPx, 0).
+		SetInt(TagOrdRejReason, reason).
+		Set(TagText, text).
+		SetTime(TagTransactTime, a.clock.Now())
+	// Queued behind any reports the engine has already produced for it
+	s.Queue(report, nil)
+}
+
+// rejectCancel answers a cancel or cancel/replace request with an
+// OrderCancelReject. rec is nil when the order is unknown.
+func (a *Acceptor) rejectCancel(s *Session, msg *Message, responseTo string, rec *orderRecord, reason int, text string) {
+	orderID, ordStatus := "NONE", OrdStatusRejected
+	if rec != nil {
+		orderID, ordStatus = rec.OrderID, rec.ordStatus()
+	}
+	reject := NewMessage(MsgOrderCancelReject).
+		Set(TagOrderID, orderID).
+		Set(TagClOrdID, getString(msg, TagClOrdID)).
+		Set(TagOrigClOrdID, getString(msg, TagOrigClOrdID)).
+		Set(TagOrdStatus, ordStatus).
+		Set(TagCxlRejResponseTo, responseTo).
+		SetInt(TagCxlRejReason, reason).
+		Set(TagText, text)
+	s.Queue(reject, nil)
+}
+
+// requireTags sends a session-level Reject naming the first missing tag.
+func requireTags(s *Session, msg *Message, tags ...int) bool {
+	for _, tag := range tags {
+		if value, found := msg.Get(tag); !found || value == "" {
+			s.reject(seqNum(msg), tag, rejectRequiredTagMissing, fmt.Sprintf("Required tag %d missing", tag))
+			return false
+		}
+	}
+	return true
+}
+
+func seqNum(msg *Message) int {
+	seq, _ := msg.Int(TagMsgSeqNum)
+	return seq
+}
+
+func fixCode(codes map[string]string, value string) string {
+	for code, v := range codes {
+		if v == value {
+			return code
+		}
+	}
+	return ""
+}
+
+func samePrice(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
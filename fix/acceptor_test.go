@@ -0,0 +1,207 @@
+package fix
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"order-matching-engine/clock"
+	"order-matching-engine/idgen"
+	"order-matching-engine/models"
+	"order-matching-engine/services"
+)
+
+// testClient is the counterparty end of a session served over net.Pipe.
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+	seq    int
+}
+
+func newTestAcceptor(t *testing.T) (*Acceptor, *services.MatchingEngine) {
+	t.Helper()
+	a, err := NewAcceptor(Config{CompID: "MATCHER", StoreDir: t.TempDir(), Sessions: map[string]string{"CLIENT": "acct-1"}},
+		clock.System{}, idgen.NewSequence("O", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.engine = services.NewMatchingEngine(services.WithStore(services.NewMemoryStore()), services.WithListener(a))
+	return a, a.engine
+}
+
+// connect serves a new pipe connection on a and returns the client end.
+func connect(t *testing.T, a *Acceptor) *testClient {
+	t.Helper()
+	server, client := net.Pipe()
+	go a.serveConn(server)
+	t.Cleanup(func() { client.Close() })
+	return &testClient{t: t, conn: client, reader: bufio.NewReader(client), seq: 1}
+}
+
+func logonMessage() *Message {
+	return NewMessage(MsgLogon).Set(TagEncryptMethod, "0").SetInt(TagHeartBtInt, 30)
+}
+
+// send writes msg with the next sequence number unless it already has one.
+func (c *testClient) send(msg *Message) {
+	c.t.Helper()
+	if _, found := msg.Get(TagMsgSeqNum); !found {
+		msg.SetInt(TagMsgSeqNum, c.seq)
+		c.seq++
+	}
+	if _, found := msg.Get(TagSenderCompID); !found {
+		msg.Set(TagSenderCompID, "CLIENT")
+	}
+	if _, found := msg.Get(TagTargetCompID); !found {
+		msg.Set(TagTargetCompID, "MATCHER")
+	}
+	msg.SetTime(TagSendingTime, time.Now())
+	c.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.conn.Write(msg.Bytes()); err != nil {
+		c.t.Fatalf("write %s: %v", msg.MsgType(), err)
+	}
+}
+
+func (c *testClient) read() (*Message, error) {
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return ReadMessage(c.reader)
+}
+
+// expect reads the next message and checks its type and fields.
+func (c *testClient) expect(msgType string, fields map[int]string) *Message {
+	c.t.Helper()
+	msg, err := c.read()
+	if err != nil {
+		c.t.Fatalf("reading %s: %v", msgType, err)
+	}
+	if msg.MsgType() != msgType {
+		c.t.Fatalf("got %s, want MsgType %s", msg, msgType)
+	}
+	for tag, want := range fields {
+		if got, _ := msg.Get(tag); got != want {
+			c.t.Fatalf("tag %d = %q, want %q in %s", tag, got, want, msg)
+		}
+	}
+	return msg
+}
+
+func (c *testClient) logon() {
+	c.t.Helper()
+	c.send(logonMessage())
+	c.expect(MsgLogon, map[int]string{TagMsgSeqNum: "1", TagHeartBtInt: "30"})
+}
+
+func TestLogonValidation(t *testing.T) {
+	for name, logon := range map[string]*Message{
+		"not a logon":         NewMessage(MsgHeartbeat),
+		"unknown session":     logonMessage().Set(TagSenderCompID, "STRANGER"),
+		"wrong target":        logonMessage().Set(TagTargetCompID, "OTHER"),
+		"no heartbeat":        NewMessage(MsgLogon).Set(TagEncryptMethod, "0"),
+		"heartbeat too long":  logonMessage().SetInt(TagHeartBtInt, maxHeartBtInt+1),
+		"encryption required": logonMessage().Set(TagEncryptMethod, "1"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			a, _ := newTestAcceptor(t)
+			c := connect(t, a)
+			c.send(logon)
+			// Refused logons are dropped without a reply
+			if msg, err := c.read(); !errors.Is(err, io.EOF) {
+				t.Fatalf("read after refused logon = %v, %v; want EOF", msg, err)
+			}
+		})
+	}
+
+	a, _ := newTestAcceptor(t)
+	connect(t, a).logon()
+}
+
+func TestSequenceGapHandling(t *testing.T) {
+	a, _ := newTestAcceptor(t)
+	c := connect(t, a)
+	c.logon()
+
+	// Messages 2 and 3 are lost
+	c.send(NewMessage(MsgHeartbeat).SetInt(TagMsgSeqNum, 4))
+	c.expect(MsgResendRequest, map[int]string{TagMsgSeqNum: "2", TagBeginSeqNo: "2", TagEndSeqNo: "0"})
+	// Further messages beyond the gap do not repeat the request
+	c.send(NewMessage(MsgHeartbeat).SetInt(TagMsgSeqNum, 5))
+
+	// The counterparty only had admin messages to resend
+	c.send(NewMessage(MsgSequenceReset).SetInt(TagMsgSeqNum, 2).Set(TagPossDupFlag, "Y").Set(TagGapFillFlag, "Y").SetInt(TagNewSeqNo, 6))
+	c.seq = 6
+	c.send(NewMessage(MsgTestRequest).Set(TagTestReqID, "after-gap"))
+	c.expect(MsgHeartbeat, map[int]string{TagMsgSeqNum: "3", TagTestReqID: "after-gap"})
+
+	// A repeat marked as a possible duplicate is ignored, otherwise the
+	// session ends
+	c.send(NewMessage(MsgHeartbeat).SetInt(TagMsgSeqNum, 3).Set(TagPossDupFlag, "Y"))
+	c.send(NewMessage(MsgHeartbeat).SetInt(TagMsgSeqNum, 3))
+	c.expect(MsgLogout, map[int]string{TagText: "MsgSeqNum too low, expecting 7 but received 3"})
+}
+
+func TestResendRequestReplaysReportsAndGapFillsAdmin(t *testing.T) {
+	a, _ := newTestAcceptor(t)
+	c := connect(t, a)
+	c.logon()
+
+	c.send(NewMessage(MsgNewOrderSingle).Set(TagClOrdID, "c1").Set(TagSymbol, "AAPL").Set(TagSide, "1").
+		SetInt(TagOrderQty, 10).Set(TagOrdType, "2").SetFloat(TagPrice, 100))
+	original := c.expect(MsgExecutionReport, map[int]string{TagMsgSeqNum: "2", TagExecType: ExecTypeNew, TagClOrdID: "c1"})
+	c.send(NewMessage(MsgTestRequest).Set(TagTestReqID, "t1"))
+	c.expect(MsgHeartbeat, map[int]string{TagMsgSeqNum: "3"})
+
+	c.send(NewMessage(MsgResendRequest).SetInt(TagBeginSeqNo, 1).SetInt(TagEndSeqNo, 0))
+	// The Logon and Heartbeat are not resent
+	c.expect(MsgSequenceReset, map[int]string{TagMsgSeqNum: "1", TagGapFillFlag: "Y", TagPossDupFlag: "Y", TagNewSeqNo: "2"})
+	resent := c.expect(MsgExecutionReport, map[int]string{TagMsgSeqNum: "2", TagPossDupFlag: "Y", TagExecID: getString(original, TagExecID)})
+	if got, want := getString(resent, TagOrigSendingTime), getString(original, TagSendingTime); got != want {
+		t.Fatalf("OrigSendingTime = %q, want %q", got, want)
+	}
+	c.expect(MsgSequenceReset, map[int]string{TagMsgSeqNum: "3", TagGapFillFlag: "Y", TagNewSeqNo: "4"})
+
+	// Numbering carries on after the resend
+	c.send(NewMessage(MsgTestRequest).Set(TagTestReqID, "t2"))
+	c.expect(MsgHeartbeat, map[int]string{TagMsgSeqNum: "4", TagTestReqID: "t2"})
+}
+
+func TestExecutionReportQuantities(t *testing.T) {
+	a, engine := newTestAcceptor(t)
+	c := connect(t, a)
+	c.logon()
+	rest := func(id, side string, price float64, quantity int) {
+		t.Helper()
+		order := &models.Order{ID: id, Symbol: "AAPL", Account: "acct-2", Side: side, Type: "limit", Price: &price,
+			InitialQuantity: quantity, RemainingQuantity: quantity, Status: "open"}
+		if _, err := engine.ProcessOrder(context.Background(), order); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rest("s1", "sell", 100, 4)
+	rest("s2", "sell", 101, 3)
+
+	c.send(NewMessage(MsgNewOrderSingle).Set(TagClOrdID, "c1").Set(TagSymbol, "AAPL").Set(TagSide, "1").
+		SetInt(TagOrderQty, 10).Set(TagOrdType, "2").SetFloat(TagPrice, 101))
+	c.expect(MsgExecutionReport, map[int]string{TagExecType: ExecTypeNew, TagOrdStatus: OrdStatusNew,
+		TagCumQty: "0", TagLeavesQty: "10", TagAvgPx: "0", TagAccount: "acct-1"})
+	c.expect(MsgExecutionReport, map[int]string{TagExecType: ExecTypeTrade, TagOrdStatus: OrdStatusPartiallyFilled,
+		TagLastQty: "4", TagLastPx: "100", TagCumQty: "4", TagLeavesQty: "6", TagAvgPx: "100"})
+	c.expect(MsgExecutionReport, map[int]string{TagExecType: ExecTypeTrade, TagOrdStatus: OrdStatusPartiallyFilled,
+		TagLastQty: "3", TagLastPx: "101", TagCumQty: "7", TagLeavesQty: "3", TagAvgPx: "100.42857142857143"})
+
+	// Replacing the quantity keeps what has been filled
+	c.send(NewMessage(MsgOrderCancelReplaceRequest).Set(TagClOrdID, "c2").Set(TagOrigClOrdID, "c1").SetInt(TagOrderQty, 12))
+	c.expect(MsgExecutionReport, map[int]string{TagExecType: ExecTypeReplaced, TagOrdStatus: OrdStatusPartiallyFilled,
+		TagClOrdID: "c2", TagOrigClOrdID: "c1", TagOrderQty: "12", TagCumQty: "7", TagLeavesQty: "5", TagAvgPx: "100.42857142857143"})
+	c.send(NewMessage(MsgOrderCancelReplaceRequest).Set(TagClOrdID, "c3").Set(TagOrigClOrdID, "c2").SetInt(TagOrderQty, 8))
+	c.expect(MsgExecutionReport, map[int]string{TagExecType: ExecTypeReplaced, TagClOrdID: "c3", TagOrigClOrdID: "c2",
+		TagOrderQty: "8", TagCumQty: "7", TagLeavesQty: "1"})
+
+	rest("s3", "sell", 101, 5)
+	c.expect(MsgExecutionReport, map[int]string{TagExecType: ExecTypeTrade, TagOrdStatus: OrdStatusFilled, TagClOrdID: "c3",
+		TagLastQty: "1", TagCumQty: "8", TagLeavesQty: "0", TagAvgPx: "100.5"})
+}
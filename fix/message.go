@@ -0,0 +1,309 @@
+// Package fix implements a FIX 4.4 order entry acceptor: session management
+// (logon, heartbeats, sequence numbers, resend) over TCP, and translation of
+// NewOrderSingle, OrderCancelRequest and OrderCancelReplaceRequest into
+// matching engine calls answered with ExecutionReports.
+package fix
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	soh         = '\x01'
+	beginString = "FIX.4.4"
+
+	// Largest body accepted from a counterparty
+	maxBodyLength = 64 * 1024
+
+	sendingTimeFormat = "20060102-15:04:05.000"
+)
+
+// Message types
+const (
+	MsgHeartbeat                 = "0"
+	MsgTestRequest               = "1"
+	MsgResendRequest             = "2"
+	MsgReject                    = "3"
+	MsgSequenceReset             = "4"
+	MsgLogout                    = "5"
+	MsgExecutionReport           = "8"
+	MsgOrderCancelReject         = "9"
+	MsgLogon                     = "A"
+	MsgNewOrderSingle            = "D"
+	MsgOrderCancelRequest        = "F"
+	MsgOrderCancelReplaceRequest = "G"
+	MsgBusinessMessageReject     = "j"
+)
+
+// Tags
+const (
+	TagAccount              = 1
+	TagAvgPx                = 6
+	TagBeginSeqNo           = 7
+	TagBeginString          = 8
+	TagBodyLength           = 9
+	TagCheckSum             = 10
+	TagClOrdID              = 11
+	TagCumQty               = 14
+	TagEndSeqNo             = 16
+	TagExecID               = 17
+	TagLastPx               = 31
+	TagLastQty              = 32
+	TagMsgSeqNum            = 34
+	TagMsgType              = 35
+	TagNewSeqNo             = 36
+	TagOrderID              = 37
+	TagOrderQty             = 38
+	TagOrdStatus            = 39
+	TagOrdType              = 40
+	TagOrigClOrdID          = 41
+	TagPossDupFlag          = 43
+	TagPrice                = 44
+	TagRefSeqNum            = 45
+	TagSenderCompID         = 49
+	TagSendingTime          = 52
+	TagSide                 = 54
+	TagSymbol               = 55
+	TagTargetCompID         = 56
+	TagText                 = 58
+	TagTransactTime         = 60
+	TagEncryptMethod        = 98
+	TagCxlRejReason         = 102
+	TagOrdRejReason         = 103
+	TagHeartBtInt           = 108
+	TagTestReqID            = 112
+	TagOrigSendingTime      = 122
+	TagGapFillFlag          = 123
+	TagResetSeqNumFlag      = 141
+	TagExecType             = 150
+	TagLeavesQty            = 151
+	TagRefTagID             = 371
+	TagRefMsgType           = 372
+	TagSessionRejectReason  = 373
+	TagBusinessRejectReason = 380
+	TagCxlRejResponseTo     = 434
+)
+
+// Header fields are written in this order, straight after MsgType.
+var headerTags = []int{TagSenderCompID, TagTargetCompID, TagMsgSeqNum, TagPossDupFlag, TagSendingTime, TagOrigSendingTime}
+
+var (
+	// ErrGarbled is returned for a message whose checksum or length is wrong.
+	// The FIX spec says to ignore such messages rather than disconnect.
+	ErrGarbled = errors.New("garbled message")
+	// ErrMalformed is returned when the stream cannot be framed at all.
+	ErrMalformed = errors.New("malformed message")
+)
+
+type Field struct {
+	Tag   int
+	Value string
+}
+
+// Message is a FIX message without its BeginString, BodyLength and CheckSum
+// fields, which are added by Bytes and checked by ReadMessage.
+type Message struct {
+	Fields []Field
+}
+
+func NewMessage(msgType string) *Message {
+	return &Message{Fields: []Field{{Tag: TagMsgType, Value: msgType}}}
+}
+
+func (m *Message) MsgType() string {
+	value, _ := m.Get(TagMsgType)
+	return value
+}
+
+// Get returns the first value of tag.
+func (m *Message) Get(tag int) (string, bool) {
+	for _, field := range m.Fields {
+		if field.Tag == tag {
+			return field.Value, true
+		}
+	}
+	return "", false
+}
+
+// Int returns tag as an integer; ok is false if it is missing or invalid.
+func (m *Message) Int(tag int) (int, bool) {
+	value, found := m.Get(tag)
+	if !found {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	return n, err == nil
+}
+
+// Float returns tag as a number; ok is false if it is missing or invalid.
+func (m *Message) Float(tag int) (float64, bool) {
+	value, found := m.Get(tag)
+	if !found {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	return f, err == nil
+}
+
+// Set replaces the value of tag, or appends it if absent.
+func (m *Message) Set(tag int, value string) *Message {
+	for i := range m.Fields {
+		if m.Fields[i].Tag == tag {
+			m.Fields[i].Value = value
+			return m
+		}
+	}
+	m.Fields = append(m.Fields, Field{Tag: tag, Value: value})
+	return m
+}
+
+func (m *Message) SetInt(tag, value int) *Message {
+	return m.Set(tag, strconv.Itoa(value))
+}
+
+func (m *Message) SetFloat(tag int, value float64) *Message {
+	return m.Set(tag, strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+func (m *Message) SetTime(tag int, t time.Time) *Message {
+	return m.Set(tag, t.UTC().Format(sendingTimeFormat))
+}
+
+// Bytes encodes the message with MsgType and the standard header first and a
+// correct BodyLength and CheckSum.
+func (m *Message) Bytes() []byte {
+	var body bytes.Buffer
+	write := func(field Field) {
+		body.WriteString(strconv.Itoa(field.Tag))
+		body.WriteByte('=')
+		body.WriteString(field.Value)
+		body.WriteByte(soh)
+	}
+
+	msgType, _ := m.Get(TagMsgType)
+	write(Field{Tag: TagMsgType, Value: msgType})
+	for _, tag := range headerTags {
+		if value, found := m.Get(tag); found {
+			write(Field{Tag: tag, Value: value})
+		}
+	}
+	for _, field := range m.Fields {
+		if field.Tag != TagMsgType && !isHeaderTag(field.Tag) {
+			write(field)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "8=%s%c9=%d%c", beginString, soh, body.Len(), soh)
+	out.Write(body.Bytes())
+	fmt.Fprintf(&out, "10=%03d%c", checksum(out.Bytes()), soh)
+	return out.Bytes()
+}
+
+// String renders the message with | in place of SOH, for logs.
+func (m *Message) String() string {
+	return string(bytes.ReplaceAll(m.Bytes(), []byte{soh}, []byte{'|'}))
+}
+
+// ReadMessage reads one message from r. It returns ErrGarbled, with the bad
+// message consumed, when the checksum does not match, and ErrMalformed when
+// the stream is not FIX at all.
+func ReadMessage(r *bufio.Reader) (*Message, error) {
+	begin, err := readField(r)
+	if err != nil {
+		return nil, err
+	}
+	if begin.Tag != TagBeginString || begin.Value != beginString {
+		return nil, fmt.Errorf("%w: expected BeginString %s", ErrMalformed, beginString)
+	}
+	length, err := readField(r)
+	if err != nil {
+		return nil, err
+	}
+	bodyLength, convErr := strconv.Atoi(length.Value)
+	if length.Tag != TagBodyLength || convErr != nil || bodyLength <= 0 || bodyLength > maxBodyLength {
+		return nil, fmt.Errorf("%w: invalid BodyLength", ErrMalformed)
+	}
+
+	body := make([]byte, bodyLength)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	trailer, err := readField(r)
+	if err != nil {
+		return nil, err
+	}
+	if trailer.Tag != TagCheckSum {
+		return nil, fmt.Errorf("%w: BodyLength does not match body", ErrGarbled)
+	}
+
+	var prefix bytes.Buffer
+	fmt.Fprintf(&prefix, "8=%s%c9=%s%c", begin.Value, soh, length.Value, soh)
+	prefix.Write(body)
+	if want, err := strconv.Atoi(trailer.Value); err != nil || want != checksum(prefix.Bytes()) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrGarbled)
+	}
+
+	msg := &Message{}
+	for _, raw := range bytes.Split(bytes.TrimSuffix(body, []byte{soh}), []byte{soh}) {
+		field, err := parseField(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrGarbled, err)
+		}
+		msg.Fields = append(msg.Fields, field)
+	}
+	if msg.MsgType() == "" || msg.Fields[0].Tag != TagMsgType {
+		return nil, fmt.Errorf("%w: MsgType must be the first body field", ErrGarbled)
+	}
+	return msg, nil
+}
+
+func readField(r *bufio.Reader) (Field, error) {
+	raw, err := r.ReadSlice(soh)
+	if err == bufio.ErrBufferFull {
+		return Field{}, fmt.Errorf("%w: field too long", ErrMalformed)
+	}
+	if err != nil {
+		return Field{}, err
+	}
+	field, err := parseField(raw[:len(raw)-1])
+	if err != nil {
+		return Field{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return field, nil
+}
+
+func parseField(raw []byte) (Field, error) {
+	tag, value, found := bytes.Cut(raw, []byte{'='})
+	if !found {
+		return Field{}, fmt.Errorf("field %q has no '='", raw)
+	}
+	n, err := strconv.Atoi(string(tag))
+	if err != nil || n <= 0 {
+		return Field{}, fmt.Errorf("invalid tag %q", tag)
+	}
+	return Field{Tag: n, Value: string(value)}, nil
+}
+
+func checksum(data []byte) int {
+	sum := 0
+	for _, b := range data {
+		sum += int(b)
+	}
+	return sum % 256
+}
+
+func isHeaderTag(tag int) bool {
+	for _, header := range headerTags {
+		if tag == header {
+			return true
+		}
+	}
+	return false
+}
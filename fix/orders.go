@@ -0,0 +1,250 @@
+package fix
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// orderRecord ties an engine order to the FIX session and ClOrdID that
+// entered it and tracks what has been reported back on it.
+type orderRecord struct {
+	OrderID     string   `json:"order_id"`
+	Session     string   `json:"session"`
+	ClOrdID     string   `json:"cl_ord_id"`
+	Account     string   `json:"account,omitempty"`
+	Symbol      string   `json:"symbol"`
+	Side        string   `json:"side"`
+	Type        string   `json:"type"`
+	Price       *float64 `json:"price,omitempty"`
+	OrderQty    int      `json:"order_qty"`
+	CumQty      int      `json:"cum_qty"`
+	CumNotional float64  `json:"cum_notional"`
+	// Number of ExecutionReports sent, used to build unique ExecIDs
+	ExecSeq int  `json:"exec_seq"`
+	Acked   bool `json:"acked"`
+	Done    bool `json:"done,omitempty"`
+	// Every ClOrdID the order has had, so a cancel or replace naming an
+	// earlier one still finds it after a restart, and all are released when
+	// it is done
+	ClOrdIDs []string `json:"cl_ord_ids,omitempty"`
+
+	// A cancel or cancel/replace request waiting on the engine
+	pendingCancel  string
+	pendingReplace *replaceRequest
+}
+
+type replaceRequest struct {
+	ClOrdID  string
+	Quantity int
+	Price    *float64
+}
+
+func (rec *orderRecord) leavesQty() int {
+	if rec.Done {
+		return 0
+	}
+	return rec.OrderQty - rec.CumQty
+}
+
+func (rec *orderRecord) avgPx() float64 {
+	if rec.CumQty == 0 {
+		return 0
+	}
+	return rec.CumNotional / float64(rec.CumQty)
+}
+
+// ordStatus is the OrdStatus (tag 39) for the fills seen so far.
+func (rec *orderRecord) ordStatus() string {
+	switch {
+	case rec.CumQty >= rec.OrderQty:
+		return OrdStatusFilled
+	case rec.CumQty > 0:
+		return OrdStatusPartiallyFilled
+	default:
+		return OrdStatusNew
+	}
+}
+
+type clOrdKey struct {
+	session string
+	clOrdID string
+}
+
+// orderRegistry holds the live orders entered over FIX. Every change is
+// appended to <dir>/orders.jsonl so fills and cancels are still reported
+// against the right ClOrdID after a restart; the file is compacted to the
+// live orders when it is loaded.
+type orderRegistry struct {
+	mu        sync.Mutex
+	byOrderID map[string]*orderRecord
+	byClOrdID map[clOrdKey]*orderRecord
+
+	// Serialises writes to file; held without mu
+	fileMu sync.Mutex
+	file   *os.File
+}
+
+func openOrderRegistry(dir string) (*orderRegistry, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create FIX store directory: %w", err)
+	}
+	path := filepath.Join(dir, "orders.jsonl")
+	r := &orderRegistry{
+		byOrderID: make(map[string]*orderRecord),
+		byClOrdID: make(map[clOrdKey]*orderRecord),
+	}
+
+	live, err := loadOrderRecords(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeOrderRecords(path, live); err != nil {
+		return nil, err
+	}
+	for _, rec := range live {
+		r.add(rec)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open FIX order registry: %w", err)
+	}
+	r.file = file
+	return r, nil
+}
+
+// loadOrderRecords returns the latest state of every order that is not done.
+func loadOrderRecords(path string) ([]*orderRecord, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open FIX order registry: %w", err)
+	}
+	defer f.Close()
+
+	latest := make(map[string]*orderRecord)
+	var order []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec orderRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// A torn final line from a crash
+			continue
+		}
+		if _, seen := latest[rec.OrderID]; !seen {
+			order = append(order, rec.OrderID)
+		}
+		latest[rec.OrderID] = &rec
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read FIX order registry: %w", err)
+	}
+
+	var live []*orderRecord
+	for _, id := range order {
+		if rec := latest[id]; !rec.Done {
+			live = append(live, rec)
+		}
+	}
+	return live, nil
+}
+
+func writeOrderRecords(path string, records []*orderRecord) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to compact FIX order registry: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	for _, rec := range records {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("failed to encode FIX order %s: %w", rec.OrderID, err)
+		}
+		w.Write(append(line, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to compact FIX order registry: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to compact FIX order registry: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to compact FIX order registry: %w", err)
+	}
+	return nil
+}
+
+// The methods below must be called with r.mu held.
+
+func (r *orderRegistry) add(rec *orderRecord) {
+	r.byOrderID[rec.OrderID] = rec
+	if len(rec.ClOrdIDs) == 0 {
+		r.addClOrdID(rec, rec.ClOrdID)
+		return
+	}
+	// A record loaded from the file
+	for _, clOrdID := range rec.ClOrdIDs {
+		r.byClOrdID[clOrdKey{session: rec.Session, clOrdID: clOrdID}] = rec
+	}
+}
+
+func (r *orderRegistry) addClOrdID(rec *orderRecord, clOrdID string) {
+	r.byClOrdID[clOrdKey{session: rec.Session, clOrdID: clOrdID}] = rec
+	rec.ClOrdIDs = append(rec.ClOrdIDs, clOrdID)
+}
+
+// lookup finds a session's order by its current or an earlier ClOrdID, or
+// failing that by the engine order ID.
+func (r *orderRegistry) lookup(session, clOrdID, orderID string) *orderRecord {
+	if clOrdID != "" {
+		return r.byClOrdID[clOrdKey{session: session, clOrdID: clOrdID}]
+	}
+	if rec := r.byOrderID[orderID]; rec != nil && rec.Session == session {
+		return rec
+	}
+	return nil
+}
+
+func (r *orderRegistry) inUse(session, clOrdID string) bool {
+	_, exists := r.byClOrdID[clOrdKey{session: session, clOrdID: clOrdID}]
+	return exists
+}
+
+// save returns the line recording rec's current state, for write, and
+// forgets rec once it is done.
+func (r *orderRegistry) save(rec *orderRecord) ([]byte, error) {
+	if rec.Done {
+		delete(r.byOrderID, rec.OrderID)
+		for _, clOrdID := range rec.ClOrdIDs {
+			delete(r.byClOrdID, clOrdKey{session: rec.Session, clOrdID: clOrdID})
+		}
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode FIX order %s: %w", rec.OrderID, err)
+	}
+	return append(line, '\n'), nil
+}
+
+// write appends a line returned by save to the registry file. Unlike the
+// methods above it does not need r.mu, so the disk write can happen off the
+// engine's listener path.
+func (r *orderRegistry) write(orderID string, line []byte) error {
+	r.fileMu.Lock()
+	defer r.fileMu.Unlock()
+
+	if _, err := r.file.Write(line); err != nil {
+		return fmt.Errorf("failed to save FIX order %s: %w", orderID, err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package fix
+
+import "testing"
+
+func TestOrderRegistryKeepsClOrdIDChainAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	registry, err := openOrderRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	save := func(rec *orderRecord) {
+		t.Helper()
+		line, err := registry.save(rec)
+		if err == nil {
+			err = registry.write(rec.OrderID, line)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := &orderRecord{OrderID: "O1", Session: "CLIENT", ClOrdID: "c1", Symbol: "AAPL", Side: "buy", Type: "limit", OrderQty: 10}
+	registry.add(rec)
+	save(rec)
+	rec.ClOrdID = "c2"
+	registry.addClOrdID(rec, "c2")
+	save(rec)
+	registry.file.Close()
+
+	reopened, err := openOrderRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.file.Close()
+	for _, clOrdID := range []string{"c1", "c2"} {
+		found := reopened.lookup("CLIENT", clOrdID, "")
+		if found == nil || found.OrderID != "O1" || found.ClOrdID != "c2" {
+			t.Fatalf("lookup(%s) after restart = %+v, want O1 with ClOrdID c2", clOrdID, found)
+		}
+	}
+
+	// Once the order is done every ClOrdID it had is free again
+	found := reopened.lookup("CLIENT", "c2", "")
+	found.Done = true
+	if _, err := reopened.save(found); err != nil {
+		t.Fatal(err)
+	}
+	if reopened.inUse("CLIENT", "c1") || reopened.inUse("CLIENT", "c2") {
+		t.Fatal("ClOrdIDs still in use after the order is done")
+	}
+}
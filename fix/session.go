@@ -0,0 +1,527 @@
+package fix
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"order-matching-engine/clock"
+	"sync"
+	"time"
+)
+
+const (
+	// Outgoing messages buffered per connection. A counterparty that falls
+	// this far behind is disconnected and recovers by resend on its next
+	// logon.
+	outboundBuffer = 4096
+	writeTimeout   = 10 * time.Second
+	checkInterval  = time.Second
+)
+
+// Session-level reject reasons (tag 373)
+const (
+	rejectRequiredTagMissing  = 1
+	rejectValueIncorrect      = 5
+	rejectIncorrectDataFormat = 6
+	rejectCompIDProblem       = 9
+)
+
+// Session is the state of one FIX session between us and a counterparty.
+// It outlives individual connections: messages sent while the counterparty
+// is disconnected are numbered and stored, and it catches up on them with a
+// ResendRequest after logging on again.
+type Session struct {
+	ours    string
+	theirs  string
+	account string
+	store   *FileStore
+	clock   clock.Clock
+
+	mu       sync.Mutex
+	conn     *connection
+	lastSent time.Time
+
+	// Messages handed over by Queue, waiting for flushLoop
+	queueMu sync.Mutex
+	queue   []queued
+	wake    chan struct{}
+}
+
+// queued is an application message waiting to be numbered and sent, with
+// whatever must be persisted before it goes out.
+type queued struct {
+	msg    *Message
+	before func() error
+}
+
+func newSession(ours, theirs, account string, store *FileStore, clk clock.Clock) *Session {
+	return &Session{ours: ours, theirs: theirs, account: account, store: store, clock: clk, wake: make(chan struct{}, 1)}
+}
+
+// Send numbers msg, stores it for resending and writes it to the current
+// connection if there is one. It never blocks on the network.
+func (s *Session) Send(msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.sendLocked(msg)
+}
+
+// Queue hands msg to the session's flush goroutine, which runs before (if
+// not nil) and then sends msg as Send would. It never blocks on the disk
+// or the network, so it is safe to call from an engine listener. Queued
+// messages are sent in order; a message that was queued but not yet sent
+// when the process stops is lost.
+func (s *Session) Queue(msg *Message, before func() error) {
+	s.queueMu.Lock()
+	s.queue = append(s.queue, queued{msg: msg, before: before})
+	s.queueMu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// flushLoop persists and sends the messages handed to Queue. It runs for
+// the life of the process.
+func (s *Session) flushLoop() {
+	for range s.wake {
+		s.queueMu.Lock()
+		pending := s.queue
+		s.queue = nil
+		s.queueMu.Unlock()
+
+		for _, q := range pending {
+			if q.before != nil {
+				if err := q.before(); err != nil {
+					slog.Error("failed to persist FIX session state", "session", s.theirs, "error", err)
+				}
+			}
+			if err := s.Send(q.msg); err != nil {
+				slog.Warn("failed to send FIX message", "session", s.theirs, "msg_type", q.msg.MsgType(), "error", err)
+			}
+		}
+	}
+}
+
+func (s *Session) sendLocked(msg *Message) error {
+	seq := s.store.NextOut()
+	msg.Set(TagSenderCompID, s.ours).Set(TagTargetCompID, s.theirs).SetInt(TagMsgSeqNum, seq).SetTime(TagSendingTime, s.clock.Now())
+	raw := msg.Bytes()
+
+	if !isAdminMessage(msg.MsgType()) {
+		if err := s.store.SaveMessage(seq, raw); err != nil {
+			return err
+		}
+	}
+	if err := s.store.SetNextOut(seq + 1); err != nil {
+		return err
+	}
+	s.deliverLocked(raw)
+	return nil
+}
+
+func (s *Session) deliverLocked(raw []byte) {
+	if s.conn == nil {
+		return
+	}
+	select {
+	case s.conn.out <- raw:
+		s.lastSent = s.clock.Now()
+	default:
+		slog.Warn("FIX outbound queue full, disconnecting", "session", s.theirs)
+		s.conn.close()
+	}
+}
+
+// connection is one TCP connection carrying a session.
+type connection struct {
+	netConn net.Conn
+	out     chan []byte
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newConnection(netConn net.Conn) *connection {
+	c := &connection{netConn: netConn, out: make(chan []byte, outboundBuffer), done: make(chan struct{})}
+	go c.writeLoop()
+	return c
+}
+
+// writeLoop writes queued messages in order. A nil message closes the
+// connection once everything queued before it has been written.
+func (c *connection) writeLoop() {
+	for {
+		select {
+		case raw := <-c.out:
+			if raw == nil {
+				c.close()
+				return
+			}
+			c.netConn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if _, err := c.netConn.Write(raw); err != nil {
+				c.close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *connection) close() {
+	c.once.Do(func() {
+		close(c.done)
+		c.netConn.Close()
+	})
+}
+
+// closeAfterFlush closes the connection after the messages already queued
+// have been written.
+func (c *connection) closeAfterFlush() {
+	select {
+	case c.out <- nil:
+	default:
+		c.close()
+	}
+}
+
+type inbound struct {
+	msg *Message
+	err error
+}
+
+// run serves the session on netConn after the counterparty's Logon has been
+// read and authenticated. It returns when the connection closes.
+func (s *Session) run(netConn net.Conn, r *bufio.Reader, logon *Message, heartBtInt time.Duration, app func(*Message)) {
+	reset := getString(logon, TagResetSeqNumFlag) == "Y"
+
+	s.mu.Lock()
+	if s.conn != nil {
+		s.mu.Unlock()
+		slog.Warn("FIX session rejected second connection", "session", s.theirs, "remote_addr", netConn.RemoteAddr().String())
+		netConn.Close()
+		return
+	}
+	if reset {
+		if err := s.store.Reset(); err != nil {
+			s.mu.Unlock()
+			slog.Error("failed to reset FIX session store", "session", s.theirs, "error", err)
+			netConn.Close()
+			return
+		}
+	}
+	conn := newConnection(netConn)
+	s.conn = conn
+	ack := NewMessage(MsgLogon).Set(TagEncryptMethod, "0").SetInt(TagHeartBtInt, int(heartBtInt/time.Second))
+	if reset {
+		ack.Set(TagResetSeqNumFlag, "Y")
+	}
+	err := s.sendLocked(ack)
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		if s.conn == conn {
+			s.conn = nil
+		}
+		s.mu.Unlock()
+		// Let a final Logout reach the counterparty
+		conn.closeAfterFlush()
+		select {
+		case <-conn.done:
+		case <-time.After(writeTimeout):
+			conn.close()
+		}
+		slog.Info("FIX session disconnected", "session", s.theirs)
+	}()
+	if err != nil {
+		slog.Warn("FIX session logon failed", "session", s.theirs, "error", err)
+		return
+	}
+	slog.Info("FIX session logged on", "session", s.theirs, "remote_addr", netConn.RemoteAddr().String())
+
+	incoming := make(chan inbound)
+	go func() {
+		for {
+			msg, err := ReadMessage(r)
+			if errors.Is(err, ErrGarbled) {
+				slog.Warn("FIX session ignoring garbled message", "session", s.theirs, "error", err)
+				continue
+			}
+			select {
+			case incoming <- inbound{msg: msg, err: err}:
+			case <-conn.done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	sc := &sessionConn{Session: s, conn: conn, heartBtInt: heartBtInt, lastReceived: s.clock.Now(), app: app}
+	if !sc.handle(logon) {
+		return
+	}
+	sc.loggedOn = true
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case in := <-incoming:
+			if in.err != nil {
+				if !errors.Is(in.err, net.ErrClosed) {
+					slog.Warn("FIX session read failed", "session", s.theirs, "error", in.err)
+				}
+				return
+			}
+			sc.lastReceived = s.clock.Now()
+			sc.testRequestSent = time.Time{}
+			if !sc.handle(in.msg) {
+				return
+			}
+		case <-ticker.C:
+			if !sc.checkHeartbeats() {
+				return
+			}
+		case <-conn.done:
+			return
+		}
+	}
+}
+
+// sessionConn holds the per-connection state used by the inbound loop.
+type sessionConn struct {
+	*Session
+	conn            *connection
+	heartBtInt      time.Duration
+	lastReceived    time.Time
+	testRequestSent time.Time
+	// Highest sequence number seen ahead of a gap we asked to be resent
+	resendTarget int
+	loggedOn     bool
+	app          func(*Message)
+}
+
+// handle applies the session rules to one inbound message and passes
+// application messages on. It returns false once the connection should end.
+func (c *sessionConn) handle(msg *Message) bool {
+	seq, ok := msg.Int(TagMsgSeqNum)
+	if !ok {
+		return c.logout("MsgSeqNum missing or invalid")
+	}
+	if getString(msg, TagSenderCompID) != c.theirs || getString(msg, TagTargetCompID) != c.ours {
+		c.reject(seq, TagSenderCompID, rejectCompIDProblem, "CompID problem")
+		return c.logout("Incorrect SenderCompID or TargetCompID")
+	}
+
+	msgType := msg.MsgType()
+	if msgType == MsgSequenceReset && getString(msg, TagGapFillFlag) != "Y" {
+		// SequenceReset-Reset applies regardless of its own sequence number
+		c.applySequenceReset(msg, seq)
+		return true
+	}
+
+	expected := c.store.NextIn()
+	switch {
+	case seq < expected:
+		if getString(msg, TagPossDupFlag) == "Y" {
+			return true
+		}
+		return c.logout(fmt.Sprintf("MsgSeqNum too low, expecting %d but received %d", expected, seq))
+	case seq > expected:
+		if msgType == MsgLogout {
+			c.sendAdmin(NewMessage(MsgLogout))
+			c.conn.closeAfterFlush()
+			return false
+		}
+		if msgType == MsgResendRequest {
+			c.resend(msg)
+		}
+		if c.resendTarget < expected {
+			c.sendAdmin(NewMessage(MsgResendRequest).SetInt(TagBeginSeqNo, expected).SetInt(TagEndSeqNo, 0))
+		}
+		c.resendTarget = max(c.resendTarget, seq)
+		return true
+	}
+
+	keepGoing := true
+	switch msgType {
+	case MsgSequenceReset:
+		c.applySequenceReset(msg, seq)
+		return true
+	case MsgLogon:
+		// Only the logon that opened the connection is expected
+		if c.loggedOn {
+			keepGoing = c.logout("Unexpected Logon")
+		}
+	case MsgHeartbeat:
+	case MsgTestRequest:
+		heartbeat := NewMessage(MsgHeartbeat)
+		if id, found := msg.Get(TagTestReqID); found {
+			heartbeat.Set(TagTestReqID, id)
+		}
+		c.sendAdmin(heartbeat)
+	case MsgResendRequest:
+		c.resend(msg)
+	case MsgReject:
+		slog.Warn("FIX counterparty rejected message", "session", c.theirs, "ref_seq_num", getString(msg, TagRefSeqNum), "text", getString(msg, TagText))
+	case MsgLogout:
+		c.advanceIn(seq)
+		c.sendAdmin(NewMessage(MsgLogout))
+		c.conn.closeAfterFlush()
+		return false
+	default:
+		c.app(msg)
+	}
+	c.advanceIn(seq)
+	return keepGoing
+}
+
+func (c *sessionConn) advanceIn(seq int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.store.SetNextIn(seq + 1); err != nil {
+		slog.Error("failed to store FIX inbound sequence number", "session", c.theirs, "error", err)
+	}
+}
+
+func (c *sessionConn) applySequenceReset(msg *Message, seq int) {
+	newSeq, ok := msg.Int(TagNewSeqNo)
+	if !ok {
+		c.reject(seq, TagNewSeqNo, rejectRequiredTagMissing, "NewSeqNo missing")
+		return
+	}
+	if newSeq < c.store.NextIn() {
+		c.reject(seq, TagNewSeqNo, rejectValueIncorrect, "Attempt to lower sequence number")
+		return
+	}
+	c.advanceIn(newSeq - 1)
+}
+
+// resend answers a ResendRequest with the stored application messages in
+// the range, marked as possible duplicates, and gap fills in place of
+// everything else.
+func (c *sessionConn) resend(msg *Message) {
+	begin, okBegin := msg.Int(TagBeginSeqNo)
+	end, okEnd := msg.Int(TagEndSeqNo)
+	if !okBegin || !okEnd {
+		seq, _ := msg.Int(TagMsgSeqNum)
+		c.reject(seq, TagBeginSeqNo, rejectRequiredTagMissing, "BeginSeqNo and EndSeqNo are required")
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	last := c.store.NextOut() - 1
+	if end == 0 || end > last {
+		end = last
+	}
+	begin = max(begin, 1)
+	if begin > end {
+		return
+	}
+
+	now := c.clock.Now()
+	next := begin
+	for _, stored := range c.store.Messages(begin, end) {
+		if stored.Seq > next {
+			c.gapFillLocked(next, stored.Seq, now)
+		}
+		original, err := ReadMessage(bufio.NewReader(bytes.NewReader(stored.Raw)))
+		if err != nil {
+			slog.Error("stored FIX message is unreadable", "session", c.theirs, "seq", stored.Seq, "error", err)
+			c.gapFillLocked(stored.Seq, stored.Seq+1, now)
+		} else {
+			original.Set(TagPossDupFlag, "Y").Set(TagOrigSendingTime, getString(original, TagSendingTime)).SetTime(TagSendingTime, now)
+			c.deliverLocked(original.Bytes())
+		}
+		next = stored.Seq + 1
+	}
+	if next <= end {
+		c.gapFillLocked(next, end+1, now)
+	}
+}
+
+// gapFillLocked tells the counterparty to skip from seq to newSeq.
+func (c *sessionConn) gapFillLocked(seq, newSeq int, now time.Time) {
+	msg := NewMessage(MsgSequenceReset).
+		Set(TagSenderCompID, c.ours).
+		Set(TagTargetCompID, c.theirs).
+		SetInt(TagMsgSeqNum, seq).
+		Set(TagPossDupFlag, "Y").
+		SetTime(TagSendingTime, now).
+		SetTime(TagOrigSendingTime, now).
+		Set(TagGapFillFlag, "Y").
+		SetInt(TagNewSeqNo, newSeq)
+	c.deliverLocked(msg.Bytes())
+}
+
+// checkHeartbeats sends a Heartbeat when we have been quiet for a whole
+// interval, a TestRequest when the counterparty has, and gives up if the
+// TestRequest goes unanswered. It returns false to end the connection.
+func (c *sessionConn) checkHeartbeats() bool {
+	now := c.clock.Now()
+
+	c.mu.Lock()
+	idle := now.Sub(c.lastSent)
+	c.mu.Unlock()
+	if idle >= c.heartBtInt {
+		c.sendAdmin(NewMessage(MsgHeartbeat))
+	}
+
+	silent := now.Sub(c.lastReceived)
+	switch {
+	case !c.testRequestSent.IsZero() && now.Sub(c.testRequestSent) >= c.heartBtInt:
+		slog.Warn("FIX session did not answer TestRequest", "session", c.theirs)
+		return false
+	case c.testRequestSent.IsZero() && silent >= c.heartBtInt+c.heartBtInt/5:
+		c.testRequestSent = now
+		c.sendAdmin(NewMessage(MsgTestRequest).Set(TagTestReqID, now.UTC().Format(sendingTimeFormat)))
+	}
+	return true
+}
+
+// reject sends a session-level Reject for the inbound message refSeq.
+func (s *Session) reject(refSeq, refTag, reason int, text string) {
+	msg := NewMessage(MsgReject).SetInt(TagRefSeqNum, refSeq).SetInt(TagSessionRejectReason, reason).Set(TagText, text)
+	if refTag != 0 {
+		msg.SetInt(TagRefTagID, refTag)
+	}
+	s.sendAdmin(msg)
+}
+
+// logout sends a Logout with text and closes the connection once it has
+// been written. It returns false so callers can end the session with it.
+func (c *sessionConn) logout(text string) bool {
+	slog.Info("FIX session logging out", "session", c.theirs, "text", text)
+	c.sendAdmin(NewMessage(MsgLogout).Set(TagText, text))
+	c.conn.closeAfterFlush()
+	return false
+}
+
+func (s *Session) sendAdmin(msg *Message) {
+	if err := s.Send(msg); err != nil {
+		slog.Warn("failed to send FIX message", "session", s.theirs, "msg_type", msg.MsgType(), "error", err)
+	}
+}
+
+func isAdminMessage(msgType string) bool {
+	switch msgType {
+	case MsgHeartbeat, MsgTestRequest, MsgResendRequest, MsgReject, MsgSequenceReset, MsgLogout, MsgLogon:
+		return true
+	}
+	return false
+}
+
+func getString(msg *Message, tag int) string {
+	value, _ := msg.Get(tag)
+	return value
+}
@@ -0,0 +1,253 @@
+package fix
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// storedMessages is how many of the latest outbound sequence numbers a
+// FileStore keeps messages for. A ResendRequest reaching further back is
+// answered with a gap fill over the dropped messages.
+const storedMessages = 100000
+
+// FileStore persists one session's sequence numbers and the application
+// messages sent on it, so a session resumes where it left off after a
+// restart and can answer ResendRequests. Administrative messages are not
+// stored; a resend replaces them with a gap fill. Only the messages of the
+// last storedMessages sequence numbers are kept, and the message file is
+// compacted to them when it has grown to twice that.
+//
+// Files are <dir>/<ours>-<theirs>.seqnums, holding "nextOut nextIn", and
+// <dir>/<ours>-<theirs>.messages, holding one "seq base64(message)" line per
+// stored message.
+type FileStore struct {
+	seqPath  string
+	msgPath  string
+	nextOut  int
+	nextIn   int
+	messages map[int][]byte
+	msgFile  *os.File
+	retain   int
+	// Lowest sequence number that may still have a message kept
+	first int
+	// Lines in the message file, including dropped messages
+	lines int
+}
+
+func OpenFileStore(dir, ours, theirs string) (*FileStore, error) {
+	return openFileStore(dir, ours, theirs, storedMessages)
+}
+
+func openFileStore(dir, ours, theirs string, retain int) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create FIX store directory: %w", err)
+	}
+	base := filepath.Join(dir, ours+"-"+theirs)
+	s := &FileStore{
+		seqPath:  base + ".seqnums",
+		msgPath:  base + ".messages",
+		nextOut:  1,
+		nextIn:   1,
+		messages: make(map[int][]byte),
+		retain:   retain,
+		first:    1,
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if len(s.messages) == 0 {
+		s.first = s.nextOut
+	}
+	s.drop(s.nextOut)
+	if s.lines > len(s.messages) {
+		if err := s.compact(); err != nil {
+			return nil, err
+		}
+	}
+
+	msgFile, err := os.OpenFile(s.msgPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open FIX message store: %w", err)
+	}
+	s.msgFile = msgFile
+	return s, nil
+}
+
+func (s *FileStore) load() error {
+	data, err := os.ReadFile(s.seqPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read FIX sequence numbers: %w", err)
+	}
+	if err == nil {
+		if _, err := fmt.Sscan(string(data), &s.nextOut, &s.nextIn); err != nil {
+			return fmt.Errorf("failed to parse FIX sequence numbers in %s: %w", s.seqPath, err)
+		}
+	}
+
+	f, err := os.Open(s.msgPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open FIX message store: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*maxBodyLength)
+	for scanner.Scan() {
+		seqText, encoded, found := strings.Cut(scanner.Text(), " ")
+		seq, seqErr := strconv.Atoi(seqText)
+		raw, decodeErr := base64.StdEncoding.DecodeString(encoded)
+		if !found || seqErr != nil || decodeErr != nil {
+			// A torn final line from a crash; the message was never sent
+			continue
+		}
+		if len(s.messages) == 0 || seq < s.first {
+			s.first = seq
+		}
+		s.messages[seq] = raw
+		s.lines++
+		// The message may have been stored without the sequence number
+		// being saved after it
+		if seq >= s.nextOut {
+			s.nextOut = seq + 1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read FIX message store: %w", err)
+	}
+	return nil
+}
+
+// NextOut is the sequence number of the next message we send.
+func (s *FileStore) NextOut() int {
+	return s.nextOut
+}
+
+// NextIn is the sequence number expected on the next message received.
+func (s *FileStore) NextIn() int {
+	return s.nextIn
+}
+
+func (s *FileStore) SetNextOut(seq int) error {
+	s.nextOut = seq
+	return s.saveSeqNums()
+}
+
+func (s *FileStore) SetNextIn(seq int) error {
+	s.nextIn = seq
+	return s.saveSeqNums()
+}
+
+// SaveMessage stores an outgoing message for resending.
+func (s *FileStore) SaveMessage(seq int, raw []byte) error {
+	line := strconv.Itoa(seq) + " " + base64.StdEncoding.EncodeToString(raw) + "\n"
+	if _, err := s.msgFile.WriteString(line); err != nil {
+		return fmt.Errorf("failed to store FIX message %d: %w", seq, err)
+	}
+	s.messages[seq] = raw
+	s.lines++
+	s.drop(seq + 1)
+	if s.lines >= 2*s.retain {
+		return s.compact()
+	}
+	return nil
+}
+
+// drop forgets the messages older than the last retain sequence numbers
+// before next.
+func (s *FileStore) drop(next int) {
+	for ; s.first < next-s.retain; s.first++ {
+		delete(s.messages, s.first)
+	}
+}
+
+// compact rewrites the message file with only the messages still kept,
+// replacing it atomically.
+func (s *FileStore) compact() error {
+	tmp := s.msgPath + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to compact FIX message store: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	for _, stored := range s.Messages(1, s.nextOut) {
+		w.WriteString(strconv.Itoa(stored.Seq) + " " + base64.StdEncoding.EncodeToString(stored.Raw) + "\n")
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to compact FIX message store: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to compact FIX message store: %w", err)
+	}
+	if err := os.Rename(tmp, s.msgPath); err != nil {
+		return fmt.Errorf("failed to compact FIX message store: %w", err)
+	}
+	s.lines = len(s.messages)
+
+	if s.msgFile == nil {
+		return nil
+	}
+	msgFile, err := os.OpenFile(s.msgPath, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen FIX message store: %w", err)
+	}
+	s.msgFile.Close()
+	s.msgFile = msgFile
+	return nil
+}
+
+// Messages returns the stored messages numbered begin to end inclusive, in
+// sequence order.
+func (s *FileStore) Messages(begin, end int) []StoredMessage {
+	var stored []StoredMessage
+	for seq, raw := range s.messages {
+		if seq >= begin && seq <= end {
+			stored = append(stored, StoredMessage{Seq: seq, Raw: raw})
+		}
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].Seq < stored[j].Seq })
+	return stored
+}
+
+// Reset starts both sequences again from 1 and discards stored messages.
+func (s *FileStore) Reset() error {
+	if err := s.msgFile.Truncate(0); err != nil {
+		return fmt.Errorf("failed to reset FIX message store: %w", err)
+	}
+	s.messages = make(map[int][]byte)
+	s.lines = 0
+	s.first = 1
+	s.nextOut, s.nextIn = 1, 1
+	return s.saveSeqNums()
+}
+
+func (s *FileStore) Close() error {
+	return s.msgFile.Close()
+}
+
+// saveSeqNums replaces the sequence file atomically so a crash leaves
+// either the old or the new numbers.
+func (s *FileStore) saveSeqNums() error {
+	tmp := s.seqPath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", s.nextOut, s.nextIn)), 0o644); err != nil {
+		return fmt.Errorf("failed to write FIX sequence numbers: %w", err)
+	}
+	if err := os.Rename(tmp, s.seqPath); err != nil {
+		return fmt.Errorf("failed to write FIX sequence numbers: %w", err)
+	}
+	return nil
+}
+
+type StoredMessage struct {
+	Seq int
+	Raw []byte
+}
@@ -0,0 +1,86 @@
+package fix
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestFileStoreRetention(t *testing.T) {
+	dir := t.TempDir()
+	store, err := openFileStore(dir, "MATCHER", "CLIENT", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seq := 1; seq <= 7; seq++ {
+		if err := store.SaveMessage(seq, []byte("message "+strconv.Itoa(seq))); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SetNextOut(seq + 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assertStored(t, store.Messages(1, 7), 5, 6, 7)
+	// Compacted when the sixth line was written, then appended to
+	if lines := countLines(t, store.msgPath); lines != 4 {
+		t.Fatalf("message file holds %d lines, want 4", lines)
+	}
+	store.Close()
+
+	reopened, err := openFileStore(dir, "MATCHER", "CLIENT", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if reopened.NextOut() != 8 {
+		t.Fatalf("NextOut = %d, want 8", reopened.NextOut())
+	}
+	assertStored(t, reopened.Messages(1, 7), 5, 6, 7)
+	if lines := countLines(t, reopened.msgPath); lines != 3 {
+		t.Fatalf("message file holds %d lines after reopening, want 3", lines)
+	}
+}
+
+func TestFileStoreReset(t *testing.T) {
+	store, err := OpenFileStore(t.TempDir(), "MATCHER", "CLIENT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.SaveMessage(1, []byte("message 1"))
+	store.SetNextOut(2)
+	store.SetNextIn(5)
+
+	if err := store.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if store.NextOut() != 1 || store.NextIn() != 1 || len(store.Messages(1, 10)) != 0 {
+		t.Fatalf("after Reset: next out %d, next in %d, %d messages", store.NextOut(), store.NextIn(), len(store.Messages(1, 10)))
+	}
+	if lines := countLines(t, store.msgPath); lines != 0 {
+		t.Fatalf("message file holds %d lines after Reset", lines)
+	}
+}
+
+func assertStored(t *testing.T, stored []StoredMessage, want ...int) {
+	t.Helper()
+	if len(stored) != len(want) {
+		t.Fatalf("%d messages stored, want %v", len(stored), want)
+	}
+	for i, seq := range want {
+		if stored[i].Seq != seq || string(stored[i].Raw) != "message "+strconv.Itoa(seq) {
+			t.Fatalf("message %d = %d %q, want %d", i, stored[i].Seq, stored[i].Raw, seq)
+		}
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Count(content, []byte("\n"))
+}
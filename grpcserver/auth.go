@@ -0,0 +1,175 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"order-matching-engine/auth"
+	"order-matching-engine/enginepb"
+	"order-matching-engine/ratelimit"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Metadata keys carrying the signature, as the X-API-* headers do over HTTP
+const (
+	keyKey       = "x-api-key"
+	keyTimestamp = "x-api-timestamp"
+	keyNonce     = "x-api-nonce"
+	keySignature = "x-api-signature"
+)
+
+// RPCs that change orders. They need a trade key and count as order entry;
+// the rest count as market data.
+var orderEntryMethods = map[string]bool{
+	enginepb.MatchingEngine_PlaceOrder_FullMethodName:  true,
+	enginepb.MatchingEngine_CancelOrder_FullMethodName: true,
+}
+
+// Guard applies the REST API's rate limits and API key authentication to
+// gRPC calls. A call is signed like a REST request with method POST, the
+// full RPC name (e.g. /engine.v1.MatchingEngine/PlaceOrder) as the path and
+// the request message's deterministic protobuf encoding as the body, and
+// carries the signature in x-api-* metadata.
+type Guard struct {
+	// nil when authentication is disabled
+	auth    *auth.Authenticator
+	limiter *ratelimit.Limiter
+}
+
+func NewGuard(authenticator *auth.Authenticator, limiter *ratelimit.Limiter) *Guard {
+	return &Guard{auth: authenticator, limiter: limiter}
+}
+
+// Unary checks the client IP's rate limit, authenticates the call and
+// checks its account's rate limit before passing it on with the API key in
+// its context.
+func (g *Guard) Unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	setTrailer := func(md metadata.MD) { grpc.SetTrailer(ctx, md) }
+	if err := g.checkIP(ctx, info.FullMethod, setTrailer); err != nil {
+		return nil, err
+	}
+	ctx, err := g.authenticate(ctx, info.FullMethod, req, setTrailer)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// Stream does what Unary does for server streaming calls, authenticating
+// them when the request message arrives.
+func (g *Guard) Stream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := g.checkIP(stream.Context(), info.FullMethod, stream.SetTrailer); err != nil {
+		return err
+	}
+	return handler(srv, &guardedStream{ServerStream: stream, ctx: stream.Context(), guard: g, method: info.FullMethod})
+}
+
+func (g *Guard) checkIP(ctx context.Context, method string, setTrailer func(metadata.MD)) error {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return status.Error(codes.InvalidArgument, "unknown client address")
+	}
+	ip, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "unknown client address")
+	}
+	return limited(g.limiter.AllowIP(ip, classify(method)), setTrailer)
+}
+
+// authenticate verifies the call's signature over req, checks the key's
+// scope and its account's rate limit and returns ctx carrying the key.
+func (g *Guard) authenticate(ctx context.Context, method string, req interface{}, setTrailer func(metadata.MD)) (context.Context, error) {
+	if g.auth == nil {
+		return ctx, nil
+	}
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return nil, status.Error(codes.Internal, "request is not a protobuf message")
+	}
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to encode request")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(name string) string {
+		if values := md.Get(name); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	key, err := g.auth.Verify(ctx, auth.SignedRequest{
+		KeyID:      first(keyKey),
+		Timestamp:  first(keyTimestamp),
+		Nonce:      first(keyNonce),
+		Signature:  first(keySignature),
+		Method:     http.MethodPost,
+		RequestURI: method,
+		Body:       body,
+	})
+	var refused *auth.Error
+	if errors.As(err, &refused) {
+		return nil, status.Error(codes.Unauthenticated, refused.Message)
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to authenticate request")
+	}
+	if orderEntryMethods[method] && !key.CanTrade() {
+		return nil, status.Error(codes.PermissionDenied, "API key scope does not allow this request")
+	}
+
+	if err := limited(g.limiter.AllowAccount(key.Account, classify(method)), setTrailer); err != nil {
+		return nil, err
+	}
+	return auth.WithKey(ctx, key), nil
+}
+
+// limited turns a refused rate limit result into RESOURCE_EXHAUSTED, with a
+// retry-after trailer in seconds.
+func limited(result ratelimit.Result, setTrailer func(metadata.MD)) error {
+	if result.Allowed {
+		return nil
+	}
+	setTrailer(metadata.Pairs("retry-after", strconv.Itoa(ratelimit.RetryAfterSeconds(result))))
+	return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+}
+
+func classify(method string) ratelimit.Class {
+	if orderEntryMethods[method] {
+		return ratelimit.ClassOrderEntry
+	}
+	return ratelimit.ClassMarketData
+}
+
+// guardedStream authenticates a streaming call when its request message is
+// received, before the handler sees it.
+type guardedStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	guard  *Guard
+	method string
+}
+
+func (s *guardedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *guardedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	ctx, err := s.guard.authenticate(s.ctx, s.method, m, s.ServerStream.SetTrailer)
+	if err != nil {
+		return err
+	}
+	s.ctx = ctx
+	return nil
+}
@@ -0,0 +1,73 @@
+package grpcserver
+
+import (
+	"context"
+	"log/slog"
+	"order-matching-engine/logging"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata key carrying the request ID, as X-Request-ID does over HTTP
+const requestIDKey = "x-request-id"
+
+// UnaryRequestID gives every call a request ID, keeping the client's
+// x-request-id metadata if usable, returns it in the response header and
+// logs the call when it completes.
+func UnaryRequestID(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx = withRequestID(ctx)
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	logCall(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+// StreamRequestID does what UnaryRequestID does for streaming calls.
+func StreamRequestID(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := withRequestID(stream.Context())
+	start := time.Now()
+	err := handler(srv, &requestIDStream{ServerStream: stream, ctx: ctx})
+	logCall(ctx, info.FullMethod, start, err)
+	return err
+}
+
+func withRequestID(ctx context.Context) context.Context {
+	var clientID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDKey); len(values) > 0 {
+			clientID = values[0]
+		}
+	}
+	id := logging.NewRequestID(clientID)
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, id))
+	return logging.WithRequestID(ctx, id)
+}
+
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	level := slog.LevelInfo
+	switch code {
+	case codes.Unknown, codes.Internal, codes.DataLoss:
+		level = slog.LevelError
+	}
+	slog.Log(ctx, level, "gRPC call completed",
+		"method", strings.TrimPrefix(method, "/"),
+		"code", code.String(),
+		"duration_ms", float64(time.Since(start).Microseconds())/1000,
+	)
+}
+
+// requestIDStream is a stream whose context carries the request ID.
+type requestIDStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *requestIDStream) Context() context.Context {
+	return s.ctx
+}
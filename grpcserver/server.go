@@ -0,0 +1,274 @@
+// Package grpcserver implements the enginepb.MatchingEngine gRPC service on
+// top of the same matching engine and market data feed as the REST API.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"order-matching-engine/auth"
+	"order-matching-engine/enginepb"
+	"order-matching-engine/idgen"
+	"order-matching-engine/marketdata"
+	"order-matching-engine/metrics"
+	"order-matching-engine/models"
+	"order-matching-engine/services"
+	"order-matching-engine/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Messages buffered per stream before a slow subscriber is dropped
+const streamBufferSize = 1024
+
+type Server struct {
+	enginepb.UnimplementedMatchingEngineServer
+
+	engine   *services.MatchingEngine
+	feed     *marketdata.Feed
+	orderIDs idgen.Generator
+}
+
+func NewServer(engine *services.MatchingEngine, feed *marketdata.Feed, orderIDs idgen.Generator) *Server {
+	return &Server{engine: engine, feed: feed, orderIDs: orderIDs}
+}
+
+func (s *Server) PlaceOrder(ctx context.Context, in *enginepb.PlaceOrderRequest) (*enginepb.PlaceOrderResponse, error) {
+	// Validate with the same rules as POST /orders
+	req := models.PlaceOrderRequest{
+		Symbol:   in.GetSymbol(),
+		Account:  in.GetAccount(),
+		Side:     sideFromProto(in.GetSide()),
+		Type:     orderTypeFromProto(in.GetType()),
+		Price:    in.Price,
+		Quantity: int(in.GetQuantity()),
+	}
+	if err := auth.RestrictAccount(ctx, &req.Account); err != nil {
+		metrics.OrderRejected(metrics.ReasonInvalid)
+		slog.WarnContext(ctx, "order rejected", "symbol", req.Symbol, "account", in.GetAccount(),
+			"side", req.Side, "type", req.Type, "reason", metrics.ReasonInvalid, "error", err)
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if err := req.Validate(); err != nil {
+		metrics.OrderRejected(metrics.ReasonInvalid)
+		slog.WarnContext(ctx, "order rejected", "symbol", req.Symbol, "account", req.Account,
+			"side", req.Side, "type", req.Type, "reason", metrics.ReasonInvalid, "error", err)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	order := &models.Order{
+		ID:                s.orderIDs.NewID(),
+		Symbol:            req.Symbol,
+		Account:           req.Account,
+		Side:              req.Side,
+		Type:              req.Type,
+		Price:             req.Price,
+		InitialQuantity:   req.Quantity,
+		RemainingQuantity: req.Quantity,
+		Status:            "open",
+	}
+
+	trades, err := s.engine.ProcessOrder(ctx, order)
+	if errors.Is(err, utils.ErrSymbolHalted) || errors.Is(err, utils.ErrMaintenanceMode) {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &enginepb.PlaceOrderResponse{Order: orderToProto(order)}
+	for _, trade := range trades {
+		resp.Trades = append(resp.Trades, tradeToProto(trade, 0))
+	}
+	return resp, nil
+}
+
+func (s *Server) CancelOrder(ctx context.Context, in *enginepb.CancelOrderRequest) (*enginepb.CancelOrderResponse, error) {
+	if in.GetOrderId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_id is required")
+	}
+
+	// API keys may only cancel their own account's orders
+	if auth.KeyFromContext(ctx) != nil {
+		order, err := s.engine.GetOrder(ctx, in.GetOrderId())
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		if order == nil || !auth.OwnsOrder(ctx, order) {
+			return nil, status.Error(codes.NotFound, utils.ErrOrderNotFound.Error())
+		}
+	}
+
+	if err := s.engine.CancelOrder(in.GetOrderId()); err != nil {
+		if errors.Is(err, utils.ErrOrderNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &enginepb.CancelOrderResponse{OrderId: in.GetOrderId()}, nil
+}
+
+func (s *Server) GetOrder(ctx context.Context, in *enginepb.GetOrderRequest) (*enginepb.Order, error) {
+	if in.GetOrderId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_id is required")
+	}
+
+	order, err := s.engine.GetOrder(ctx, in.GetOrderId())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if order == nil || !auth.OwnsOrder(ctx, order) {
+		return nil, status.Error(codes.NotFound, utils.ErrOrderNotFound.Error())
+	}
+	return orderToProto(order), nil
+}
+
+// SubscribeBook sends a snapshot of the symbol's price levels and then
+// every change, until the client goes away or falls too far behind.
+func (s *Server) SubscribeBook(in *enginepb.SubscribeBookRequest, stream enginepb.MatchingEngine_SubscribeBookServer) error {
+	if in.GetSymbol() == "" {
+		return status.Error(codes.InvalidArgument, "symbol is required")
+	}
+
+	sub := s.feed.Subscribe(streamBufferSize)
+	defer sub.Close()
+	if err := sub.Add(marketdata.ChannelBook, in.GetSymbol()); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return forward(stream.Context(), sub, func(msg marketdata.Message) error {
+		return stream.Send(bookUpdateToProto(msg))
+	})
+}
+
+// SubscribeTrades sends every trade in the requested symbols as it executes.
+func (s *Server) SubscribeTrades(in *enginepb.SubscribeTradesRequest, stream enginepb.MatchingEngine_SubscribeTradesServer) error {
+	if len(in.GetSymbols()) == 0 {
+		return status.Error(codes.InvalidArgument, "at least one symbol is required")
+	}
+
+	sub := s.feed.Subscribe(streamBufferSize)
+	defer sub.Close()
+	for _, symbol := range in.GetSymbols() {
+		if symbol == "" {
+			return status.Error(codes.InvalidArgument, "symbols must not be empty")
+		}
+		if err := sub.Add(marketdata.ChannelTrades, symbol); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return forward(stream.Context(), sub, func(msg marketdata.Message) error {
+		return stream.Send(tradeToProto(msg.Trade, msg.Sequence))
+	})
+}
+
+// forward sends feed messages to a stream until the stream ends or the
+// feed drops the subscription for falling behind.
+func forward(ctx context.Context, sub *marketdata.Subscription, send func(marketdata.Message) error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case msg, ok := <-sub.Messages():
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "subscriber fell behind, resubscribe")
+			}
+			if err := send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func orderToProto(order *models.Order) *enginepb.Order {
+	return &enginepb.Order{
+		Id:                order.ID,
+		Symbol:            order.Symbol,
+		Account:           order.Account,
+		Side:              sideToProto(order.Side),
+		Type:              orderTypeToProto(order.Type),
+		Price:             order.Price,
+		InitialQuantity:   int64(order.InitialQuantity),
+		RemainingQuantity: int64(order.RemainingQuantity),
+		Status:            order.Status,
+		CreatedAt:         timestamppb.New(order.CreatedAt),
+	}
+}
+
+func tradeToProto(trade *models.Trade, sequence uint64) *enginepb.Trade {
+	return &enginepb.Trade{
+		Id:          trade.ID,
+		Symbol:      trade.Symbol,
+		BuyOrderId:  trade.BuyOrderID,
+		SellOrderId: trade.SellOrderID,
+		Price:       trade.Price,
+		Quantity:    int64(trade.Quantity),
+		ExecutedAt:  timestamppb.New(trade.ExecutedAt),
+		Sequence:    sequence,
+	}
+}
+
+func bookUpdateToProto(msg marketdata.Message) *enginepb.BookUpdate {
+	update := &enginepb.BookUpdate{
+		Type:     enginepb.BookUpdate_TYPE_UPDATE,
+		Symbol:   msg.Symbol,
+		Sequence: msg.Sequence,
+	}
+	levels := msg.Changes
+	if msg.Type == "snapshot" {
+		update.Type = enginepb.BookUpdate_TYPE_SNAPSHOT
+		levels = append(append(levels, msg.Bids...), msg.Asks...)
+	}
+	for _, level := range levels {
+		pl := &enginepb.PriceLevel{Price: level.Price, Quantity: int64(level.Quantity), Orders: int64(level.Orders)}
+		if level.Side == "buy" {
+			update.Bids = append(update.Bids, pl)
+		} else {
+			update.Asks = append(update.Asks, pl)
+		}
+	}
+	return update
+}
+
+func sideFromProto(side enginepb.Side) string {
+	switch side {
+	case enginepb.Side_SIDE_BUY:
+		return "buy"
+	case enginepb.Side_SIDE_SELL:
+		return "sell"
+	}
+	return ""
+}
+
+func sideToProto(side string) enginepb.Side {
+	switch side {
+	case "buy":
+		return enginepb.Side_SIDE_BUY
+	case "sell":
+		return enginepb.Side_SIDE_SELL
+	}
+	return enginepb.Side_SIDE_UNSPECIFIED
+}
+
+func orderTypeFromProto(orderType enginepb.OrderType) string {
+	switch orderType {
+	case enginepb.OrderType_ORDER_TYPE_LIMIT:
+		return "limit"
+	case enginepb.OrderType_ORDER_TYPE_MARKET:
+		return "market"
+	}
+	return ""
+}
+
+func orderTypeToProto(orderType string) enginepb.OrderType {
+	switch orderType {
+	case "limit":
+		return enginepb.OrderType_ORDER_TYPE_LIMIT
+	case "market":
+		return enginepb.OrderType_ORDER_TYPE_MARKET
+	}
+	return enginepb.OrderType_ORDER_TYPE_UNSPECIFIED
+}
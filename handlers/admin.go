@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"order-matching-engine/journal"
+	"order-matching-engine/models"
+	"order-matching-engine/services"
+	"order-matching-engine/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// AdminHandler serves the operator endpoints that control the engine.
+type AdminHandler struct {
+	engine    *services.MatchingEngine
+	snapshots *journal.SnapshotStore
+}
+
+func NewAdminHandler(engine *services.MatchingEngine, snapshots *journal.SnapshotStore) *AdminHandler {
+	return &AdminHandler{engine: engine, snapshots: snapshots}
+}
+
+// ListSymbols returns every symbol with its book size and halt state.
+func (h *AdminHandler) ListSymbols(w http.ResponseWriter, r *http.Request) {
+	utils.WriteSuccess(w, h.engine.SymbolStatuses())
+}
+
+// HaltSymbol stops new orders and amends on a symbol. Cancels still go
+// through.
+func (h *AdminHandler) HaltSymbol(w http.ResponseWriter, r *http.Request) {
+	symbol := mux.Vars(r)["symbol"]
+	if len(symbol) > 50 {
+		utils.WriteError(w, http.StatusBadRequest, "symbol too long (max 50 characters)")
+		return
+	}
+	h.engine.Halt(symbol)
+	slog.WarnContext(r.Context(), "symbol halted", "symbol", symbol)
+	utils.WriteSuccess(w, map[string]interface{}{"symbol": symbol, "halted": true})
+}
+
+func (h *AdminHandler) ResumeSymbol(w http.ResponseWriter, r *http.Request) {
+	symbol := mux.Vars(r)["symbol"]
+	h.engine.Resume(symbol)
+	slog.InfoContext(r.Context(), "symbol resumed", "symbol", symbol)
+	utils.WriteSuccess(w, map[string]interface{}{"symbol": symbol, "halted": false})
+}
+
+// CancelOrders force-cancels resting orders of any account, by ID or by
+// symbol, account, side and price filter.
+func (h *AdminHandler) CancelOrders(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/json" {
+		utils.WriteError(w, http.StatusBadRequest, "Content-Type must be application/json")
+		return
+	}
+
+	var req models.AdminCancelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Empty() {
+		utils.WriteError(w, http.StatusBadRequest, "order_ids or at least one filter is required")
+		return
+	}
+
+	cancelledIDs, err := h.engine.AdminCancel(req)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	slog.WarnContext(r.Context(), "orders force-cancelled", "count", len(cancelledIDs), "symbol", req.Symbol, "account", req.Account)
+	utils.WriteSuccess(w, map[string]interface{}{
+		"cancelled_order_ids": cancelledIDs,
+		"cancelled_count":     len(cancelledIDs),
+	})
+}
+
+// TakeSnapshot writes a snapshot now rather than at the next interval.
+func (h *AdminHandler) TakeSnapshot(w http.ResponseWriter, r *http.Request) {
+	snap, err := h.engine.TakeSnapshot(h.snapshots)
+	if errors.Is(err, utils.ErrJournalFailed) {
+		utils.WriteError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to write snapshot", "error", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to write snapshot")
+		return
+	}
+	utils.WriteJSON(w, http.StatusCreated, utils.Response{Success: true, Data: map[string]interface{}{
+		"sequence":   snap.Sequence,
+		"created_at": snap.CreatedAt,
+		"symbols":    len(snap.Books),
+	}})
+}
+
+// GetEngineStatus reports the trading state and engine lock contention.
+func (h *AdminHandler) GetEngineStatus(w http.ResponseWriter, r *http.Request) {
+	utils.WriteSuccess(w, h.engine.Status())
+}
+
+// SetMaintenance turns maintenance mode, in which only cancels are
+// accepted, on or off.
+func (h *AdminHandler) SetMaintenance(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/json" {
+		utils.WriteError(w, http.StatusBadRequest, "Content-Type must be application/json")
+		return
+	}
+
+	var req models.MaintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	h.engine.SetMaintenance(req.Enabled)
+	slog.WarnContext(r.Context(), "maintenance mode changed", "enabled", req.Enabled)
+	utils.WriteSuccess(w, map[string]bool{"maintenance": req.Enabled})
+}
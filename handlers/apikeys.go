@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"order-matching-engine/auth"
+	"order-matching-engine/models"
+	"order-matching-engine/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type APIKeyHandler struct {
+	keys *auth.Keys
+}
+
+func NewAPIKeyHandler(keys *auth.Keys) *APIKeyHandler {
+	return &APIKeyHandler{keys: keys}
+}
+
+// CreateKey issues a key for an account. The response is the only time the
+// secret is shown.
+func (h *APIKeyHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/json" {
+		utils.WriteError(w, http.StatusBadRequest, "Content-Type must be application/json")
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	key, err := h.keys.Create(r.Context(), req)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+	utils.WriteJSON(w, http.StatusCreated, utils.Response{Success: true, Data: key})
+}
+
+// ListKeys returns the keys of the account given as a query parameter, or
+// every key, without their secrets.
+func (h *APIKeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.keys.List(r.Context(), r.URL.Query().Get("account"))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to list API keys")
+		return
+	}
+	utils.WriteSuccess(w, keys)
+}
+
+func (h *APIKeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	revoked, err := h.keys.Revoke(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to revoke API key")
+		return
+	}
+	if !revoked {
+		utils.WriteError(w, http.StatusNotFound, "API key not found or already revoked")
+		return
+	}
+	utils.WriteSuccess(w, map[string]string{"message": "API key revoked"})
+}
+
+// restrictAccount binds an account field of a request to the account of the
+// API key it was signed with; see auth.RestrictAccount.
+func restrictAccount(r *http.Request, account *string) error {
+	return auth.RestrictAccount(r.Context(), account)
+}
+
+// ownsOrder reports whether the request's API key may see order.
+func ownsOrder(r *http.Request, order *models.Order) bool {
+	return auth.OwnsOrder(r.Context(), order)
+}
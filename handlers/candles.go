@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"order-matching-engine/services"
+	"order-matching-engine/utils"
+	"strconv"
+	"time"
+)
+
+type CandleHandler struct {
+	candles *services.CandleService
+}
+
+func NewCandleHandler(candles *services.CandleService) *CandleHandler {
+	return &CandleHandler{candles: candles}
+}
+
+func (h *CandleHandler) GetCandles(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	symbol := query.Get("symbol")
+	if symbol == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Symbol parameter is required")
+		return
+	}
+
+	interval := query.Get("interval")
+	if _, ok := services.CandleIntervals[interval]; !ok {
+		utils.WriteError(w, http.StatusBadRequest, "interval must be one of 1s, 5s, 15s, 30s, 1m, 5m, 15m, 30m, 1h, 4h, 1d")
+		return
+	}
+
+	from, err := parseTimeParam(query.Get("from"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "from must be an RFC 3339 time or Unix seconds")
+		return
+	}
+	to, err := parseTimeParam(query.Get("to"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "to must be an RFC 3339 time or Unix seconds")
+		return
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		utils.WriteError(w, http.StatusBadRequest, "to must not be before from")
+		return
+	}
+
+	candles, err := h.candles.Candles(symbol, interval, from, to)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to get candles")
+		return
+	}
+
+	utils.WriteSuccess(w, candles)
+}
+
+// parseTimeParam accepts an RFC 3339 timestamp or Unix seconds. An empty
+// value yields the zero time.
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"order-matching-engine/marketdata"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = 25 * time.Second
+	wsBufferSize   = 1024
+)
+
+type MarketDataHandler struct {
+	feed     *marketdata.Feed
+	upgrader websocket.Upgrader
+}
+
+func NewMarketDataHandler(feed *marketdata.Feed) *MarketDataHandler {
+	return &MarketDataHandler{
+		feed: feed,
+		upgrader: websocket.Upgrader{
+			// Market data is public and served without an API key, so
+			// browser clients from any origin may connect
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// wsRequest is a client control message, e.g.
+// {"op":"subscribe","channel":"book","symbol":"AAPL"}
+type wsRequest struct {
+	Op      string `json:"op"`
+	Channel string `json:"channel"`
+	Symbol  string `json:"symbol"`
+}
+
+// wsReply acknowledges a control message or reports why it failed.
+type wsReply struct {
+	Type    string `json:"type"`
+	Op      string `json:"op,omitempty"`
+	Channel string `json:"channel,omitempty"`
+	Symbol  string `json:"symbol,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (h *MarketDataHandler) ServeWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an HTTP error response
+		return
+	}
+	defer conn.Close()
+
+	sub := h.feed.Subscribe(wsBufferSize)
+	defer sub.Close()
+
+	replies := make(chan wsReply, 16)
+	done := make(chan struct{})
+	go h.readRequests(r.Context(), conn, sub, replies, done)
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case reply := <-replies:
+			if err := writeJSON(conn, reply); err != nil {
+				return
+			}
+		case msg, ok := <-sub.Messages():
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "subscriber too slow"),
+					time.Now().Add(wsWriteTimeout))
+				return
+			}
+			if err := writeJSON(conn, msg); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readRequests handles subscribe and unsubscribe messages until the client
+// disconnects. Replies go through the writer goroutine because a websocket
+// connection supports only one concurrent writer.
+func (h *MarketDataHandler) readRequests(ctx context.Context, conn *websocket.Conn, sub *marketdata.Subscription, replies chan<- wsReply, done chan<- struct{}) {
+	defer close(done)
+
+	conn.SetReadLimit(4096)
+	conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	for {
+		var req wsRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				slog.WarnContext(ctx, "market data websocket read failed", "error", err)
+			}
+			return
+		}
+
+		reply := wsReply{Type: "ack", Op: req.Op, Channel: req.Channel, Symbol: req.Symbol}
+		switch {
+		case req.Symbol == "":
+			reply = wsReply{Type: "error", Op: req.Op, Error: "symbol is required"}
+		case req.Op == "subscribe":
+			if err := sub.Add(req.Channel, req.Symbol); err != nil {
+				reply = wsReply{Type: "error", Op: req.Op, Channel: req.Channel, Error: "channel must be 'book', 'trades' or 'orders'"}
+			}
+		case req.Op == "unsubscribe":
+			sub.Remove(req.Channel, req.Symbol)
+		default:
+			reply = wsReply{Type: "error", Op: req.Op, Error: "op must be 'subscribe' or 'unsubscribe'"}
+		}
+
+		select {
+		case replies <- reply:
+		default:
+			// Client is sending faster than we can answer; drop the reply
+		}
+	}
+}
+
+func writeJSON(conn *websocket.Conn, v interface{}) error {
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return conn.WriteJSON(v)
+}
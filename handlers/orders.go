@@ -3,23 +3,38 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"order-matching-engine/auth"
+	"order-matching-engine/clock"
+	"order-matching-engine/config"
+	"order-matching-engine/database"
+	"order-matching-engine/idgen"
+	"order-matching-engine/metrics"
 	"order-matching-engine/models"
 	"order-matching-engine/services"
 	"order-matching-engine/utils"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
+// Most orders or order IDs accepted in one batch request
+const maxBatchSize = 100
+
 type OrderHandler struct {
-	engine *services.MatchingEngine
+	engine   *services.MatchingEngine
+	clock    clock.Clock
+	orderIDs idgen.Generator
+	depth    config.BookConfig
 }
 
-func NewOrderHandler(engine *services.MatchingEngine) *OrderHandler {
-	return &OrderHandler{engine: engine}
+func NewOrderHandler(engine *services.MatchingEngine, clk clock.Clock, orderIDs idgen.Generator, depth config.BookConfig) *OrderHandler {
+	return &OrderHandler{engine: engine, clock: clk, orderIDs: orderIDs, depth: depth}
 }
 
 func (h *OrderHandler) PlaceOrder(w http.ResponseWriter, r *http.Request) {
@@ -30,33 +45,30 @@ func (h *OrderHandler) PlaceOrder(w http.ResponseWriter, r *http.Request) {
 
 	var req models.PlaceOrderRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		rejectInvalid(r, &req, err)
 		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
+	if err := restrictAccount(r, &req.Account); err != nil {
+		rejectInvalid(r, &req, err)
+		utils.WriteError(w, http.StatusForbidden, err.Error())
+		return
+	}
 
 	// Validate order request
 	if err := h.validateOrderRequest(&req); err != nil {
+		rejectInvalid(r, &req, err)
 		utils.WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Create order
-	order := &models.Order{
-		ID:                uuid.New().String(),
-		Symbol:            req.Symbol,
-		Side:              req.Side,
-		Type:              req.Type,
-		Price:             req.Price,
-		InitialQuantity:   req.Quantity,
-		RemainingQuantity: req.Quantity,
-		Status:            "open",
-		CreatedAt:         time.Now(),
-	}
+	order := h.newOrder(&req)
 
 	// Process order through matching engine
-	trades, err := h.engine.ProcessOrder(order)
+	trades, err := h.engine.ProcessOrder(r.Context(), order)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		utils.WriteError(w, processErrorStatus(err), err.Error())
 		return
 	}
 
@@ -68,6 +80,147 @@ func (h *OrderHandler) PlaceOrder(w http.ResponseWriter, r *http.Request) {
 	utils.WriteSuccess(w, response)
 }
 
+// PlaceOrderBatch places a list of orders in request order and reports on
+// each one. In all-or-none mode every order is validated, and every symbol
+// checked for a halt or maintenance, before any reaches the engine. Orders
+// are still placed one at a time, so if the engine then fails one the batch
+// stops there and the orders already placed stand; the response is a 200
+// reporting which were accepted and which were skipped.
+func (h *OrderHandler) PlaceOrderBatch(w http.ResponseWriter, r *http.Request) {
+	if !h.validateContentType(w, r) {
+		return
+	}
+
+	var req models.BatchPlaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validateBatchSize("orders", len(req.Orders)); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	results := make([]models.BatchItemResult, len(req.Orders))
+	valid := true
+	for i := range req.Orders {
+		results[i].Index = i
+		err := restrictAccount(r, &req.Orders[i].Account)
+		if err == nil {
+			err = h.validateOrderRequest(&req.Orders[i])
+		}
+		if err != nil {
+			rejectInvalid(r, &req.Orders[i], err)
+			results[i].Status = models.BatchItemRejected
+			results[i].Error = err.Error()
+			valid = false
+		}
+	}
+	if req.AllOrNone && !valid {
+		skipRemaining(results)
+		writeBatchError(w, http.StatusBadRequest, "Batch rejected: not every order is valid", results)
+		return
+	}
+	if req.AllOrNone {
+		symbols := make([]string, len(req.Orders))
+		for i := range req.Orders {
+			symbols[i] = req.Orders[i].Symbol
+		}
+		if err := h.engine.CheckTrading(symbols...); err != nil {
+			skipRemaining(results)
+			writeBatchError(w, processErrorStatus(err), "Batch rejected: "+err.Error(), results)
+			return
+		}
+	}
+
+	for i := range req.Orders {
+		if results[i].Status != "" {
+			continue
+		}
+		order := h.newOrder(&req.Orders[i])
+		trades, err := h.engine.ProcessOrder(r.Context(), order)
+		if err != nil {
+			results[i].Status = models.BatchItemRejected
+			results[i].OrderID = order.ID
+			results[i].Error = err.Error()
+			if req.AllOrNone {
+				skipRemaining(results)
+				break
+			}
+			continue
+		}
+		results[i] = models.BatchItemResult{Index: i, Status: models.BatchItemAccepted, OrderID: order.ID, Order: order, Trades: trades}
+	}
+
+	utils.WriteSuccess(w, map[string]interface{}{
+		"results":        results,
+		"accepted_count": countStatus(results, models.BatchItemAccepted),
+		"rejected_count": countStatus(results, models.BatchItemRejected),
+		"skipped_count":  countStatus(results, models.BatchItemSkipped),
+	})
+}
+
+// CancelOrderBatch cancels a list of orders in one engine transaction and
+// reports on each one. In all-or-none mode nothing is cancelled unless every
+// order can be.
+func (h *OrderHandler) CancelOrderBatch(w http.ResponseWriter, r *http.Request) {
+	if !h.validateContentType(w, r) {
+		return
+	}
+
+	var req models.BatchCancelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validateBatchSize("order_ids", len(req.OrderIDs)); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, id := range req.OrderIDs {
+		if id == "" {
+			utils.WriteError(w, http.StatusBadRequest, "order_ids must not be empty")
+			return
+		}
+	}
+	if err := restrictAccount(r, &req.Account); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	errs, err := h.engine.CancelOrders(req)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	results := make([]models.BatchItemResult, len(req.OrderIDs))
+	failed := false
+	for i, id := range req.OrderIDs {
+		results[i] = models.BatchItemResult{Index: i, Status: models.BatchItemCancelled, OrderID: id}
+		if errs[i] != nil {
+			results[i].Status = models.BatchItemRejected
+			results[i].Error = errs[i].Error()
+			failed = true
+		}
+	}
+	if req.AllOrNone && failed {
+		for i := range results {
+			if results[i].Status == models.BatchItemCancelled {
+				results[i].Status = models.BatchItemSkipped
+			}
+		}
+		writeBatchError(w, http.StatusBadRequest, "Batch rejected: not every order can be cancelled", results)
+		return
+	}
+
+	utils.WriteSuccess(w, map[string]interface{}{
+		"results":         results,
+		"cancelled_count": countStatus(results, models.BatchItemCancelled),
+		"rejected_count":  countStatus(results, models.BatchItemRejected),
+	})
+}
+
 func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	orderID := vars["id"]
@@ -77,12 +230,12 @@ func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	order, err := h.engine.GetOrder(orderID)
+	order, err := h.engine.GetOrder(r.Context(), orderID)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	if order == nil {
+	if order == nil || !ownsOrder(r, order) {
 		utils.WriteError(w, http.StatusNotFound, "Order not found")
 		return
 	}
@@ -90,6 +243,33 @@ func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	utils.WriteSuccess(w, order)
 }
 
+// GetOrderEvents returns the lifecycle history of an order, oldest first.
+// Rejected orders have events but no order record, so API keys, which only
+// see their own account's orders, cannot see them.
+func (h *OrderHandler) GetOrderEvents(w http.ResponseWriter, r *http.Request) {
+	orderID := mux.Vars(r)["id"]
+
+	events, err := database.GetOrderEvents(r.Context(), orderID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to get order events")
+		return
+	}
+
+	if len(events) == 0 || auth.KeyFromContext(r.Context()) != nil {
+		order, err := h.engine.GetOrder(r.Context(), orderID)
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if order == nil || !ownsOrder(r, order) {
+			utils.WriteError(w, http.StatusNotFound, "Order not found")
+			return
+		}
+	}
+
+	utils.WriteSuccess(w, events)
+}
+
 func (h *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	orderID := vars["id"]
@@ -99,6 +279,19 @@ func (h *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// API keys may only cancel their own account's orders
+	if auth.KeyFromContext(r.Context()) != nil {
+		order, err := h.engine.GetOrder(r.Context(), orderID)
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if order == nil || !ownsOrder(r, order) {
+			utils.WriteError(w, http.StatusNotFound, utils.ErrOrderNotFound.Error())
+			return
+		}
+	}
+
 	err := h.engine.CancelOrder(orderID)
 	if err != nil {
 		if errors.Is(err, utils.ErrOrderNotFound) {
@@ -112,27 +305,78 @@ func (h *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
 	utils.WriteSuccess(w, map[string]string{"message": "Order cancelled successfully"})
 }
 
+func (h *OrderHandler) MassCancel(w http.ResponseWriter, r *http.Request) {
+	filter, err := h.parseMassCancelRequest(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := restrictAccount(r, &filter.Account); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	cancelledIDs, err := h.engine.MassCancel(*filter)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.WriteSuccess(w, map[string]interface{}{
+		"cancelled_order_ids": cancelledIDs,
+		"cancelled_count":     len(cancelledIDs),
+	})
+}
+
+// ListOrders returns orders matching the query filters one page at a time.
+// Pass the returned next_cursor as cursor, with the same sort, to fetch the
+// following page.
+func (h *OrderHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
+	q, err := h.parseOrderQuery(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := restrictAccount(r, &q.Account); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	page, err := database.ListOrders(r.Context(), *q)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to list orders")
+		return
+	}
+
+	utils.WriteSuccess(w, page)
+}
+
 func (h *OrderHandler) GetOrderBook(w http.ResponseWriter, r *http.Request) {
 	symbol := r.URL.Query().Get("symbol")
+	depth, err := h.parseDepth(r.URL.Query().Get("depth"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	
 	if symbol == "" {
 		// Return all order books if no symbol specified
-		allBooks := h.engine.GetAllOrderBooks()
+		allBooks := h.engine.GetAllOrderBooks(depth)
 		utils.WriteSuccess(w, allBooks)
 		return
 	}
 
 	book := h.engine.GetOrderBook(symbol)
 
-	bids := book.GetTopBids(10)
-	asks := book.GetTopAsks(10)
+	bids := book.GetTopBids(depth)
+	asks := book.GetTopAsks(depth)
 	
 	formattedBids := h.formatBidsWithTimestamp(bids)
 	formattedAsks := h.formatAsksWithTimestamp(asks)
 	
 	response := models.OrderBookResponse{
 		Symbol:         symbol,
-		Timestamp:      time.Now(),
+		Timestamp:      h.clock.Now(),
 		Bids:           formattedBids,
 		Asks:           formattedAsks,
 		Spread:         h.calculateSpread(formattedBids, formattedAsks),
@@ -262,48 +506,186 @@ func (h *OrderHandler) validateContentType(w http.ResponseWriter, r *http.Reques
 	return true
 }
 
+// rejectInvalid logs and counts an order refused before it reaches the
+// engine. The engine logs the orders it rejects itself.
+func rejectInvalid(r *http.Request, req *models.PlaceOrderRequest, err error) {
+	metrics.OrderRejected(metrics.ReasonInvalid)
+	slog.WarnContext(r.Context(), "order rejected", "symbol", req.Symbol, "account", req.Account,
+		"side", req.Side, "type", req.Type, "reason", metrics.ReasonInvalid, "error", err)
+}
+
+// newOrder builds an open order from a validated request.
+func (h *OrderHandler) newOrder(req *models.PlaceOrderRequest) *models.Order {
+	return &models.Order{
+		ID:                h.orderIDs.NewID(),
+		Symbol:            req.Symbol,
+		Account:           req.Account,
+		Side:              req.Side,
+		Type:              req.Type,
+		Price:             req.Price,
+		InitialQuantity:   req.Quantity,
+		RemainingQuantity: req.Quantity,
+		Status:            "open",
+	}
+}
+
+// validateOrderRequest applies the checks shared by every order entry
+// gateway; see models.PlaceOrderRequest.Validate.
 func (h *OrderHandler) validateOrderRequest(req *models.PlaceOrderRequest) error {
-	// Validate required fields
-	if req.Symbol == "" {
-		return errors.New("symbol is required")
+	return req.Validate()
+}
+
+func (h *OrderHandler) parseMassCancelRequest(r *http.Request) (*models.MassCancelRequest, error) {
+	query := r.URL.Query()
+	filter := &models.MassCancelRequest{
+		Symbol:  query.Get("symbol"),
+		Side:    query.Get("side"),
+		Account: query.Get("account"),
 	}
-	if len(req.Symbol) > 50 {
-		return errors.New("symbol too long (max 50 characters)")
+
+	if filter.Side != "" && filter.Side != "buy" && filter.Side != "sell" {
+		return nil, errors.New("side must be 'buy' or 'sell'")
 	}
-	if req.Side == "" {
-		return errors.New("side is required")
+
+	var err error
+	if filter.MinPrice, err = parseOptionalPrice(query.Get("min_price")); err != nil {
+		return nil, errors.New("min_price must be a positive number")
 	}
-	if req.Type == "" {
-		return errors.New("type is required")
+	if filter.MaxPrice, err = parseOptionalPrice(query.Get("max_price")); err != nil {
+		return nil, errors.New("max_price must be a positive number")
 	}
-	if req.Quantity <= 0 {
-		return errors.New("quantity must be positive")
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		return nil, errors.New("min_price cannot exceed max_price")
 	}
 
-	// Validate side
-	if req.Side != "buy" && req.Side != "sell" {
-		return errors.New("side must be 'buy' or 'sell'")
+	// Refuse to wipe every book in one call by accident
+	if filter.Symbol == "" && filter.Side == "" && filter.Account == "" &&
+		filter.MinPrice == nil && filter.MaxPrice == nil {
+		return nil, errors.New("at least one filter is required (symbol, side, account, min_price, max_price)")
 	}
 
-	// Validate type
-	if req.Type != "limit" && req.Type != "market" {
-		return errors.New("type must be 'limit' or 'market'")
+	return filter, nil
+}
+
+func (h *OrderHandler) parseOrderQuery(r *http.Request) (*models.OrderQuery, error) {
+	query := r.URL.Query()
+	q := &models.OrderQuery{
+		Symbol:  query.Get("symbol"),
+		Side:    query.Get("side"),
+		Type:    query.Get("type"),
+		Account: query.Get("account"),
 	}
 
-	// Validate price for limit orders
-	if req.Type == "limit" {
-		if req.Price == nil {
-			return errors.New("price required for limit orders")
-		}
-		if *req.Price <= 0 {
-			return errors.New("price must be positive")
+	if q.Side != "" && q.Side != "buy" && q.Side != "sell" {
+		return nil, errors.New("side must be 'buy' or 'sell'")
+	}
+	if q.Type != "" && q.Type != "limit" && q.Type != "market" {
+		return nil, errors.New("type must be 'limit' or 'market'")
+	}
+	if status := query.Get("status"); status != "" {
+		for _, s := range strings.Split(status, ",") {
+			if s != "open" && s != "partial" && s != "filled" && s != "cancelled" {
+				return nil, errors.New("status must be a comma-separated list of 'open', 'partial', 'filled' or 'cancelled'")
+			}
+			q.Statuses = append(q.Statuses, s)
 		}
 	}
 
-	// Market orders should not have price
-	if req.Type == "market" && req.Price != nil {
-		return errors.New("market orders should not have price")
+	switch query.Get("sort") {
+	case "", "-created_at":
+	case "created_at":
+		q.Ascending = true
+	default:
+		return nil, errors.New("sort must be 'created_at' or '-created_at'")
+	}
+
+	var err error
+	if q.Limit, err = parseLimit(query.Get("limit")); err != nil {
+		return nil, err
+	}
+	if q.From, err = parseTimeParam(query.Get("from")); err != nil {
+		return nil, errors.New("from must be an RFC 3339 time or Unix seconds")
+	}
+	if q.To, err = parseTimeParam(query.Get("to")); err != nil {
+		return nil, errors.New("to must be an RFC 3339 time or Unix seconds")
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		if q.After, err = models.ParseCursor(cursor); err != nil {
+			return nil, errors.New("invalid cursor")
+		}
 	}
 
+	return q, nil
+}
+
+func validateBatchSize(field string, size int) error {
+	if size == 0 {
+		return fmt.Errorf("%s must not be empty", field)
+	}
+	if size > maxBatchSize {
+		return fmt.Errorf("%s cannot contain more than %d items", field, maxBatchSize)
+	}
 	return nil
-}
\ No newline at end of file
+}
+
+// skipRemaining marks every item of an abandoned batch that was not rejected
+// as skipped.
+func skipRemaining(results []models.BatchItemResult) {
+	for i := range results {
+		if results[i].Status == "" {
+			results[i].Status = models.BatchItemSkipped
+		}
+	}
+}
+
+func countStatus(results []models.BatchItemResult, status string) int {
+	count := 0
+	for _, result := range results {
+		if result.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// writeBatchError reports a failed batch along with the result of every item.
+func writeBatchError(w http.ResponseWriter, status int, message string, results []models.BatchItemResult) {
+	utils.WriteJSON(w, status, utils.Response{
+		Success: false,
+		Error:   message,
+		Data:    map[string]interface{}{"results": results},
+	})
+}
+
+// processErrorStatus maps an error from the engine to a status: 503 while
+// trading is halted or the engine is in maintenance, else 500.
+func processErrorStatus(err error) int {
+	if errors.Is(err, utils.ErrSymbolHalted) || errors.Is(err, utils.ErrMaintenanceMode) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// parseDepth reads the number of orders per side to return, defaulting to
+// the configured depth.
+func (h *OrderHandler) parseDepth(value string) (int, error) {
+	if value == "" {
+		return h.depth.DefaultDepth, nil
+	}
+	depth, err := strconv.Atoi(value)
+	if err != nil || depth <= 0 || depth > h.depth.MaxDepth {
+		return 0, fmt.Errorf("depth must be between 1 and %d", h.depth.MaxDepth)
+	}
+	return depth, nil
+}
+
+func parseOptionalPrice(value string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	price, err := strconv.ParseFloat(value, 64)
+	if err != nil || price <= 0 {
+		return nil, errors.New("invalid price")
+	}
+	return &price, nil
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"order-matching-engine/orderstream"
+	"order-matching-engine/utils"
+	"time"
+)
+
+const (
+	sseKeepAliveInterval = 15 * time.Second
+	sseBufferSize        = 1024
+)
+
+type StreamHandler struct {
+	hub *orderstream.Hub
+}
+
+func NewStreamHandler(hub *orderstream.Hub) *StreamHandler {
+	return &StreamHandler{hub: hub}
+}
+
+// StreamEvents serves order status transitions and trades as Server-Sent
+// Events, optionally filtered by symbol and account. Clients that reconnect
+// with Last-Event-ID receive the events they missed; if that is no longer
+// possible they get a "resync" event and should reload state over REST.
+func (h *StreamHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.WriteError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	filter := orderstream.Filter{
+		Symbol:  r.URL.Query().Get("symbol"),
+		Account: r.URL.Query().Get("account"),
+	}
+	if err := restrictAccount(r, &filter.Account); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		// EventSource cannot set headers on the first connection
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	sub, backlog, resumed := h.hub.Subscribe(filter, lastEventID, sseBufferSize)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if !resumed {
+		fmt.Fprint(w, "event: resync\ndata: {}\n\n")
+	}
+	for _, event := range backlog {
+		if err := writeSSE(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Dropped for falling behind; the client reconnects and resumes
+				return
+			}
+			if err := writeSSE(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, event orderstream.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"order-matching-engine/models"
+	"order-matching-engine/services"
+	"order-matching-engine/utils"
+	"sort"
+)
+
+type TickerHandler struct {
+	engine  *services.MatchingEngine
+	tickers *services.TickerService
+}
+
+func NewTickerHandler(engine *services.MatchingEngine, tickers *services.TickerService) *TickerHandler {
+	return &TickerHandler{engine: engine, tickers: tickers}
+}
+
+// GetTicker returns the ticker for one symbol, or for every symbol that has
+// a book or has traded when no symbol is given.
+func (h *TickerHandler) GetTicker(w http.ResponseWriter, r *http.Request) {
+	if symbol := r.URL.Query().Get("symbol"); symbol != "" {
+		utils.WriteSuccess(w, h.ticker(symbol))
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, symbol := range append(h.engine.Symbols(), h.tickers.Symbols()...) {
+		seen[symbol] = true
+	}
+	symbols := make([]string, 0, len(seen))
+	for symbol := range seen {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	tickers := make([]models.Ticker, 0, len(symbols))
+	for _, symbol := range symbols {
+		tickers = append(tickers, h.ticker(symbol))
+	}
+	utils.WriteSuccess(w, tickers)
+}
+
+func (h *TickerHandler) ticker(symbol string) models.Ticker {
+	ticker := h.tickers.Ticker(symbol)
+	bid, ask := h.engine.BestPrices(symbol)
+	if bid != nil {
+		ticker.BidPrice, ticker.BidQuantity = &bid.Price, bid.Quantity
+	}
+	if ask != nil {
+		ticker.AskPrice, ticker.AskQuantity = &ask.Price, ask.Quantity
+	}
+	return ticker
+}
@@ -1,9 +1,18 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"order-matching-engine/auth"
 	"order-matching-engine/database"
+	"order-matching-engine/models"
 	"order-matching-engine/utils"
+	"strconv"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
 )
 
 type TradeHandler struct{}
@@ -12,24 +21,71 @@ func NewTradeHandler() *TradeHandler {
 	return &TradeHandler{}
 }
 
+// GetTrades returns trade history newest first, one page at a time. Pass the
+// returned next_cursor as cursor to fetch the following page.
 func (h *TradeHandler) GetTrades(w http.ResponseWriter, r *http.Request) {
-	symbol := r.URL.Query().Get("symbol")
-	
-	var trades interface{}
+	query := r.URL.Query()
+
+	q := models.TradeQuery{
+		Symbol:  query.Get("symbol"),
+		OrderID: query.Get("order_id"),
+		Account: query.Get("account"),
+	}
+
+	// API keys only see their own account's fills
+	if err := restrictAccount(r, &q.Account); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if q.OrderID != "" && auth.KeyFromContext(r.Context()) != nil {
+		order, err := database.GetOrderByID(r.Context(), q.OrderID)
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, "Failed to get trades")
+			return
+		}
+		if order == nil || !ownsOrder(r, order) {
+			utils.WriteError(w, http.StatusNotFound, "Order not found")
+			return
+		}
+	}
+
 	var err error
-	
-	if symbol == "" {
-		// If no symbol provided, get all trades
-		trades, err = database.GetAllTrades()
-	} else {
-		// Get trades for specific symbol
-		trades, err = database.GetTradesBySymbol(symbol)
-	}
-	
+	if q.Limit, err = parseLimit(query.Get("limit")); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if q.From, err = parseTimeParam(query.Get("from")); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "from must be an RFC 3339 time or Unix seconds")
+		return
+	}
+	if q.To, err = parseTimeParam(query.Get("to")); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "to must be an RFC 3339 time or Unix seconds")
+		return
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		if q.After, err = models.ParseCursor(cursor); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+	}
+
+	page, err := database.ListTrades(r.Context(), q)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, "Failed to get trades")
 		return
 	}
 
-	utils.WriteSuccess(w, trades)
-}
\ No newline at end of file
+	utils.WriteSuccess(w, page)
+}
+
+// parseLimit reads a page size, defaulting to defaultPageLimit.
+func parseLimit(value string) (int, error) {
+	if value == "" {
+		return defaultPageLimit, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 || limit > maxPageLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+	}
+	return limit, nil
+}
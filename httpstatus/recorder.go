@@ -0,0 +1,52 @@
+// Package httpstatus captures the status code of HTTP responses for the
+// logging and metrics middleware.
+package httpstatus
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// Recorder captures the response status. It passes Flush and Hijack through
+// so the event stream and WebSocket endpoints keep working.
+type Recorder struct {
+	http.ResponseWriter
+	// 200 until the handler writes a header, 101 once the connection is
+	// hijacked
+	Status      int
+	wroteHeader bool
+}
+
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (r *Recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.Status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *Recorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *Recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not support hijacking")
+	}
+	r.Status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
@@ -0,0 +1,54 @@
+package idgen
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+// Generator supplies IDs for new orders and trades.
+type Generator interface {
+	NewID() string
+}
+
+// UUID generates random version 4 UUIDs.
+type UUID struct{}
+
+func (UUID) NewID() string {
+	return uuid.New().String()
+}
+
+// sequenceDigits keeps sequence IDs fixed width so they sort lexically in the
+// same order they were issued.
+const sequenceDigits = 16
+
+// Sequence generates monotonically increasing IDs of the form prefix followed
+// by a zero-padded counter, e.g. "O0000000000000042".
+type Sequence struct {
+	prefix string
+	last   atomic.Uint64
+}
+
+// NewSequence returns a generator whose first ID is last+1. Seed last with
+// the highest ID already issued so numbering continues across restarts.
+func NewSequence(prefix string, last uint64) *Sequence {
+	s := &Sequence{prefix: prefix}
+	s.last.Store(last)
+	return s
+}
+
+func (s *Sequence) NewID() string {
+	return fmt.Sprintf("%s%0*d", s.prefix, sequenceDigits, s.last.Add(1))
+}
+
+// ParseSequence extracts the counter from an ID issued by a Sequence with the
+// given prefix.
+func ParseSequence(prefix, id string) (uint64, error) {
+	if !strings.HasPrefix(id, prefix) {
+		return 0, fmt.Errorf("id %q does not have prefix %q", id, prefix)
+	}
+	return strconv.ParseUint(strings.TrimPrefix(id, prefix), 10, 64)
+}
@@ -0,0 +1,172 @@
+// Package itch publishes engine market data as a compact binary,
+// ITCH-style protocol: fixed-width, big-endian messages for order adds,
+// executions, cancels, deletes and trades, sequenced and framed in
+// MoldUDP64-style packets sent over UDP multicast, with a TCP server for
+// retransmitting missed messages.
+package itch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Message types
+const (
+	MsgSymbolDirectory = 'R'
+	MsgAddOrder        = 'A'
+	MsgOrderExecuted   = 'E'
+	MsgOrderCancel     = 'X'
+	MsgOrderDelete     = 'D'
+	MsgTrade           = 'P'
+)
+
+const (
+	SideBuy  = 'B'
+	SideSell = 'S'
+
+	// Prices are sent as integers in units of 1/PriceScale
+	PriceScale = 100_000_000
+
+	// Width of the space-padded symbol in a symbol directory message
+	SymbolLength = 50
+
+	// Every message starts with its type, stock locate and timestamp
+	headerLength = 1 + 2 + 8
+)
+
+// Message lengths by type, including the common header
+var messageLengths = map[byte]int{
+	MsgSymbolDirectory: headerLength + SymbolLength,
+	MsgAddOrder:        headerLength + 8 + 1 + 4 + 8,
+	MsgOrderExecuted:   headerLength + 8 + 4 + 8,
+	MsgOrderCancel:     headerLength + 8 + 4,
+	MsgOrderDelete:     headerLength + 8,
+	MsgTrade:           headerLength + 1 + 4 + 8 + 8,
+}
+
+var ErrInvalidMessage = errors.New("invalid ITCH message")
+
+// Message is a decoded ITCH message. Which fields are set depends on Type:
+//
+//	R symbol directory: Symbol
+//	A add order:        OrderRef, Side, Shares, Price
+//	E order executed:   OrderRef, Shares (executed), MatchNumber
+//	X order cancel:     OrderRef, Shares (cancelled)
+//	D order delete:     OrderRef
+//	P trade:            Side (of the aggressor), Shares, Price, MatchNumber
+//
+// Every message carries the stock locate code assigned to its symbol by an
+// earlier symbol directory message, and a timestamp in Unix nanoseconds.
+type Message struct {
+	Type        byte
+	Locate      uint16
+	Timestamp   uint64
+	Symbol      string
+	OrderRef    uint64
+	Side        byte
+	Shares      uint32
+	Price       int64
+	MatchNumber uint64
+}
+
+// Encode returns the fixed-width binary form of m.
+func (m *Message) Encode() []byte {
+	length, known := messageLengths[m.Type]
+	if !known {
+		panic(fmt.Sprintf("itch: unknown message type %q", m.Type))
+	}
+	b := make([]byte, 0, length)
+	b = append(b, m.Type)
+	b = binary.BigEndian.AppendUint16(b, m.Locate)
+	b = binary.BigEndian.AppendUint64(b, m.Timestamp)
+
+	switch m.Type {
+	case MsgSymbolDirectory:
+		b = append(b, fmt.Sprintf("%-*s", SymbolLength, m.Symbol)...)
+	case MsgAddOrder:
+		b = binary.BigEndian.AppendUint64(b, m.OrderRef)
+		b = append(b, m.Side)
+		b = binary.BigEndian.AppendUint32(b, m.Shares)
+		b = binary.BigEndian.AppendUint64(b, uint64(m.Price))
+	case MsgOrderExecuted:
+		b = binary.BigEndian.AppendUint64(b, m.OrderRef)
+		b = binary.BigEndian.AppendUint32(b, m.Shares)
+		b = binary.BigEndian.AppendUint64(b, m.MatchNumber)
+	case MsgOrderCancel:
+		b = binary.BigEndian.AppendUint64(b, m.OrderRef)
+		b = binary.BigEndian.AppendUint32(b, m.Shares)
+	case MsgOrderDelete:
+		b = binary.BigEndian.AppendUint64(b, m.OrderRef)
+	case MsgTrade:
+		b = append(b, m.Side)
+		b = binary.BigEndian.AppendUint32(b, m.Shares)
+		b = binary.BigEndian.AppendUint64(b, uint64(m.Price))
+		b = binary.BigEndian.AppendUint64(b, m.MatchNumber)
+	}
+	return b
+}
+
+// Decode parses one message.
+func Decode(b []byte) (*Message, error) {
+	if len(b) == 0 {
+		return nil, fmt.Errorf("%w: empty", ErrInvalidMessage)
+	}
+	length, known := messageLengths[b[0]]
+	if !known {
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidMessage, b[0])
+	}
+	if len(b) != length {
+		return nil, fmt.Errorf("%w: type %q is %d bytes, got %d", ErrInvalidMessage, b[0], length, len(b))
+	}
+
+	m := &Message{
+		Type:      b[0],
+		Locate:    binary.BigEndian.Uint16(b[1:]),
+		Timestamp: binary.BigEndian.Uint64(b[3:]),
+	}
+	body := b[headerLength:]
+	switch m.Type {
+	case MsgSymbolDirectory:
+		m.Symbol = strings.TrimRight(string(body), " ")
+	case MsgAddOrder:
+		m.OrderRef = binary.BigEndian.Uint64(body)
+		m.Side = body[8]
+		m.Shares = binary.BigEndian.Uint32(body[9:])
+		m.Price = int64(binary.BigEndian.Uint64(body[13:]))
+	case MsgOrderExecuted:
+		m.OrderRef = binary.BigEndian.Uint64(body)
+		m.Shares = binary.BigEndian.Uint32(body[8:])
+		m.MatchNumber = binary.BigEndian.Uint64(body[12:])
+	case MsgOrderCancel:
+		m.OrderRef = binary.BigEndian.Uint64(body)
+		m.Shares = binary.BigEndian.Uint32(body[8:])
+	case MsgOrderDelete:
+		m.OrderRef = binary.BigEndian.Uint64(body)
+	case MsgTrade:
+		m.Side = body[0]
+		m.Shares = binary.BigEndian.Uint32(body[1:])
+		m.Price = int64(binary.BigEndian.Uint64(body[5:]))
+		m.MatchNumber = binary.BigEndian.Uint64(body[13:])
+	}
+	return m, nil
+}
+
+// EncodePrice converts a price to fixed point.
+func EncodePrice(price float64) int64 {
+	return int64(math.Round(price * PriceScale))
+}
+
+// DecodePrice converts a fixed-point price back to a float.
+func DecodePrice(price int64) float64 {
+	return float64(price) / PriceScale
+}
+
+func encodeSide(side string) byte {
+	if side == "buy" {
+		return SideBuy
+	}
+	return SideSell
+}
@@ -0,0 +1,57 @@
+package itch
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestMessageLayouts(t *testing.T) {
+	header := "\x00\x07\x00\x00\x00\x00\x00\x00\x00\x09"
+	for _, tc := range []struct {
+		msg  *Message
+		body string
+	}{
+		{&Message{Type: MsgSymbolDirectory, Symbol: "AAPL"}, "AAPL" + string(bytes.Repeat([]byte(" "), SymbolLength-4))},
+		{&Message{Type: MsgAddOrder, OrderRef: 1, Side: SideBuy, Shares: 258, Price: 2},
+			"\x00\x00\x00\x00\x00\x00\x00\x01B\x00\x00\x01\x02\x00\x00\x00\x00\x00\x00\x00\x02"},
+		{&Message{Type: MsgOrderExecuted, OrderRef: 1, Shares: 3, MatchNumber: 4},
+			"\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x03\x00\x00\x00\x00\x00\x00\x00\x04"},
+		{&Message{Type: MsgOrderCancel, OrderRef: 1, Shares: 3}, "\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x03"},
+		{&Message{Type: MsgOrderDelete, OrderRef: 1}, "\x00\x00\x00\x00\x00\x00\x00\x01"},
+		{&Message{Type: MsgTrade, Side: SideSell, Shares: 3, Price: -1, MatchNumber: 4},
+			"S\x00\x00\x00\x03\xff\xff\xff\xff\xff\xff\xff\xff\x00\x00\x00\x00\x00\x00\x00\x04"},
+	} {
+		tc.msg.Locate, tc.msg.Timestamp = 7, 9
+		want := string(tc.msg.Type) + header + tc.body
+		encoded := tc.msg.Encode()
+		if string(encoded) != want {
+			t.Fatalf("%c encodes to %q, want %q", tc.msg.Type, encoded, want)
+		}
+		if len(encoded) != messageLengths[tc.msg.Type] {
+			t.Fatalf("%c is %d bytes, want %d", tc.msg.Type, len(encoded), messageLengths[tc.msg.Type])
+		}
+
+		decoded, err := Decode(encoded)
+		if err != nil {
+			t.Fatalf("Decode %c: %v", tc.msg.Type, err)
+		}
+		if *decoded != *tc.msg {
+			t.Fatalf("decoded %+v, want %+v", decoded, tc.msg)
+		}
+	}
+}
+
+func TestDecodeRejectsBadMessages(t *testing.T) {
+	valid := (&Message{Type: MsgOrderDelete, OrderRef: 1}).Encode()
+	for name, b := range map[string][]byte{
+		"empty":        nil,
+		"unknown type": append([]byte{'U'}, valid[1:]...),
+		"short":        valid[:len(valid)-1],
+		"long":         append(valid, 0),
+	} {
+		if _, err := Decode(b); !errors.Is(err, ErrInvalidMessage) {
+			t.Fatalf("%s: Decode error = %v, want ErrInvalidMessage", name, err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package itch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// SessionLength is the width of the space-padded session name
+	SessionLength = 10
+	// PacketHeaderLength is session, sequence number and message count
+	PacketHeaderLength = SessionLength + 8 + 2
+
+	// MaxPacketSize keeps multicast packets inside a typical Ethernet MTU
+	MaxPacketSize = 1400
+)
+
+var ErrInvalidPacket = errors.New("invalid ITCH packet")
+
+// Packet is a MoldUDP64-style frame: the session, the sequence number of
+// its first message and the messages themselves, each prefixed with its
+// length. A packet without messages is a heartbeat whose sequence number is
+// that of the next message to be sent, so receivers can detect gaps while
+// the market is quiet.
+//
+// A retransmission request is a packet header on its own, with the first
+// sequence number wanted and the number of messages.
+type Packet struct {
+	Session  string
+	Sequence uint64
+	Messages [][]byte
+}
+
+func (p *Packet) Encode() []byte {
+	size := PacketHeaderLength
+	for _, msg := range p.Messages {
+		size += 2 + len(msg)
+	}
+	b := make([]byte, 0, size)
+	b = appendHeader(b, p.Session, p.Sequence, uint16(len(p.Messages)))
+	for _, msg := range p.Messages {
+		b = binary.BigEndian.AppendUint16(b, uint16(len(msg)))
+		b = append(b, msg...)
+	}
+	return b
+}
+
+// DecodePacket parses a datagram.
+func DecodePacket(b []byte) (*Packet, error) {
+	if len(b) < PacketHeaderLength {
+		return nil, fmt.Errorf("%w: %d bytes is shorter than the header", ErrInvalidPacket, len(b))
+	}
+	p, count := decodeHeader(b)
+	rest := b[PacketHeaderLength:]
+	for i := 0; i < int(count); i++ {
+		if len(rest) < 2 {
+			return nil, fmt.Errorf("%w: truncated at message %d", ErrInvalidPacket, i)
+		}
+		length := int(binary.BigEndian.Uint16(rest))
+		if len(rest) < 2+length {
+			return nil, fmt.Errorf("%w: truncated at message %d", ErrInvalidPacket, i)
+		}
+		p.Messages = append(p.Messages, rest[2:2+length])
+		rest = rest[2+length:]
+	}
+	return p, nil
+}
+
+// ReadPacket reads one packet from a stream, as sent by the retransmission
+// server.
+func ReadPacket(r io.Reader) (*Packet, error) {
+	header := make([]byte, PacketHeaderLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	p, count := decodeHeader(header)
+	var lengthBuf [2]byte
+	for i := 0; i < int(count); i++ {
+		if _, err := io.ReadFull(r, lengthBuf[:]); err != nil {
+			return nil, err
+		}
+		msg := make([]byte, binary.BigEndian.Uint16(lengthBuf[:]))
+		if _, err := io.ReadFull(r, msg); err != nil {
+			return nil, err
+		}
+		p.Messages = append(p.Messages, msg)
+	}
+	return p, nil
+}
+
+// EncodeRequest builds a retransmission request for count messages starting
+// at sequence.
+func EncodeRequest(session string, sequence uint64, count uint16) []byte {
+	return appendHeader(nil, session, sequence, count)
+}
+
+func appendHeader(b []byte, session string, sequence uint64, count uint16) []byte {
+	b = append(b, fmt.Sprintf("%-*.*s", SessionLength, SessionLength, session)...)
+	b = binary.BigEndian.AppendUint64(b, sequence)
+	return binary.BigEndian.AppendUint16(b, count)
+}
+
+func decodeHeader(b []byte) (*Packet, uint16) {
+	return &Packet{
+		Session:  strings.TrimRight(string(b[:SessionLength]), " "),
+		Sequence: binary.BigEndian.Uint64(b[SessionLength:]),
+	}, binary.BigEndian.Uint16(b[SessionLength+8:])
+}
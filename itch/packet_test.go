@@ -0,0 +1,55 @@
+package itch
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPacketRoundTrip(t *testing.T) {
+	msgs := [][]byte{
+		(&Message{Type: MsgOrderDelete, Locate: 1, OrderRef: 5}).Encode(),
+		(&Message{Type: MsgOrderCancel, Locate: 1, OrderRef: 6, Shares: 2}).Encode(),
+	}
+	packet := &Packet{Session: "0101120000", Sequence: 42, Messages: msgs}
+	encoded := packet.Encode()
+	wantHeader := "0101120000\x00\x00\x00\x00\x00\x00\x00\x2a\x00\x02"
+	if string(encoded[:PacketHeaderLength]) != wantHeader {
+		t.Fatalf("header = %q, want %q", encoded[:PacketHeaderLength], wantHeader)
+	}
+	if len(encoded) != PacketHeaderLength+2+len(msgs[0])+2+len(msgs[1]) {
+		t.Fatalf("packet is %d bytes", len(encoded))
+	}
+
+	for name, decode := range map[string]func([]byte) (*Packet, error){
+		"datagram": DecodePacket,
+		"stream":   func(b []byte) (*Packet, error) { return ReadPacket(bytes.NewReader(b)) },
+	} {
+		decoded, err := decode(encoded)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if decoded.Session != packet.Session || decoded.Sequence != 42 || len(decoded.Messages) != 2 ||
+			!bytes.Equal(decoded.Messages[0], msgs[0]) || !bytes.Equal(decoded.Messages[1], msgs[1]) {
+			t.Fatalf("%s: decoded %+v", name, decoded)
+		}
+	}
+
+	if _, err := DecodePacket(encoded[:len(encoded)-1]); !errors.Is(err, ErrInvalidPacket) {
+		t.Fatalf("truncated packet error = %v, want ErrInvalidPacket", err)
+	}
+	if _, err := DecodePacket(encoded[:PacketHeaderLength-1]); !errors.Is(err, ErrInvalidPacket) {
+		t.Fatalf("short header error = %v, want ErrInvalidPacket", err)
+	}
+}
+
+func TestRetransmissionRequest(t *testing.T) {
+	request := EncodeRequest("0101120000", 7, 3)
+	if len(request) != PacketHeaderLength {
+		t.Fatalf("request is %d bytes, want %d", len(request), PacketHeaderLength)
+	}
+	packet, count := decodeHeader(request)
+	if packet.Session != "0101120000" || packet.Sequence != 7 || count != 3 {
+		t.Fatalf("request decodes to %s %d %d", packet.Session, packet.Sequence, count)
+	}
+}
@@ -0,0 +1,421 @@
+package itch
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"order-matching-engine/clock"
+	"order-matching-engine/journal"
+	"order-matching-engine/models"
+	"order-matching-engine/services"
+	"sync"
+	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	heartbeatInterval = time.Second
+	// Packets queued for the multicast socket; beyond this packets are
+	// dropped and receivers recover them by retransmission
+	packetQueueSize = 8192
+	// Retransmission connections idle this long are closed
+	retransmitIdleTimeout = time.Minute
+	// Locate codes are 16 bits and start at 1, which limits a session to
+	// this many symbols
+	maxLocates = math.MaxUint16
+)
+
+type Config struct {
+	// Multicast group and port, e.g. 239.1.1.1:30001
+	MulticastAddr string
+	// Network interface to send multicast on, e.g. "lo"; empty uses the
+	// system default
+	Interface string
+	TTL       int
+	// TCP address of the retransmission server
+	RetransmitAddr string
+	// Number of most recent messages kept for retransmission
+	Retention int
+}
+
+// restingOrder is the publisher's view of an order on the book, enough to
+// turn the engine's order-level events into ITCH messages.
+type restingOrder struct {
+	ref      uint64
+	locate   uint16
+	quantity int
+}
+
+// Publisher turns engine events into sequenced ITCH messages. Sequence
+// numbers start at 1 for each session, and a session starts every time the
+// process does, named after its start time.
+type Publisher struct {
+	cfg     Config
+	session string
+	packets chan []byte
+
+	mu            sync.Mutex
+	nextSeq       uint64
+	retained      [][]byte
+	firstRetained uint64
+	locates       map[string]uint16
+	// Symbols seen after the locate codes ran out; they are not published
+	refused   map[string]bool
+	orders    map[string]*restingOrder
+	matches   map[string]uint64
+	nextRef   uint64
+	nextMatch uint64
+}
+
+func NewPublisher(cfg Config, clk clock.Clock) (*Publisher, error) {
+	if cfg.MulticastAddr == "" {
+		return nil, errors.New("ITCH multicast address is required")
+	}
+	if cfg.Retention <= 0 {
+		return nil, errors.New("ITCH retention must be positive")
+	}
+	return &Publisher{
+		cfg:           cfg,
+		session:       clk.Now().UTC().Format("0102150405"),
+		packets:       make(chan []byte, packetQueueSize),
+		nextSeq:       1,
+		firstRetained: 1,
+		locates:       make(map[string]uint16),
+		refused:       make(map[string]bool),
+		orders:        make(map[string]*restingOrder),
+		matches:       make(map[string]uint64),
+	}, nil
+}
+
+// Session returns the session name carried in every packet.
+func (p *Publisher) Session() string {
+	return p.session
+}
+
+// Seed publishes an add for every resting order in an engine snapshot, so
+// a receiver that recovers the session from sequence 1 can build the
+// complete book. Call it after the engine has recovered and before it
+// accepts orders.
+func (p *Publisher) Seed(books []journal.BookSnapshot) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var msgs [][]byte
+	for _, book := range books {
+		for _, resting := range append(append([]journal.RestingOrder(nil), book.Bids...), book.Asks...) {
+			order := resting.Order
+			if order.Price == nil {
+				continue
+			}
+			msgs = p.addOrder(msgs, models.OrderBookEvent{
+				Type:      models.BookEventAdd,
+				Symbol:    book.Symbol,
+				OrderID:   order.ID,
+				Side:      order.Side,
+				Price:     *order.Price,
+				Quantity:  order.RemainingQuantity,
+				Timestamp: order.CreatedAt,
+			})
+		}
+	}
+	p.publish(msgs)
+}
+
+// OnEvent implements services.Listener.
+func (p *Publisher) OnEvent(event services.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var msgs [][]byte
+	switch event.Type {
+	case services.EventTrade:
+		trade := event.Trade
+		var locate uint16
+		var ok bool
+		if msgs, locate, ok = p.locate(msgs, trade.Symbol, trade.ExecutedAt); !ok {
+			return
+		}
+		p.nextMatch++
+		p.matches[trade.ID] = p.nextMatch
+		msgs = append(msgs, (&Message{
+			Type:        MsgTrade,
+			Locate:      locate,
+			Timestamp:   uint64(trade.ExecutedAt.UnixNano()),
+			Side:        encodeSide(event.Aggressor),
+			Shares:      uint32(trade.Quantity),
+			Price:       EncodePrice(trade.Price),
+			MatchNumber: p.nextMatch,
+		}).Encode())
+	case services.EventOrderBook:
+		for _, change := range event.Orders {
+			msgs = p.orderMessages(msgs, change)
+		}
+	default:
+		return
+	}
+	p.publish(msgs)
+}
+
+// orderMessages appends the messages for one order-level book change.
+// It must be called with p.mu held, as must the helpers below.
+func (p *Publisher) orderMessages(msgs [][]byte, change models.OrderBookEvent) [][]byte {
+	if change.Type == models.BookEventAdd {
+		return p.addOrder(msgs, change)
+	}
+
+	order, exists := p.orders[change.OrderID]
+	if !exists && p.refused[change.Symbol] {
+		return msgs
+	}
+	if !exists {
+		slog.Error("ITCH publisher got book event for unknown order", "type", change.Type, "order_id", change.OrderID)
+		return msgs
+	}
+	msg := &Message{Locate: order.locate, Timestamp: uint64(change.Timestamp.UnixNano()), OrderRef: order.ref}
+
+	switch change.Type {
+	case models.BookEventExecute:
+		msg.Type = MsgOrderExecuted
+		msg.Shares = uint32(change.ExecutedQuantity)
+		msg.MatchNumber = p.matches[change.TradeID]
+		delete(p.matches, change.TradeID)
+		order.quantity = change.Quantity
+	case models.BookEventModify:
+		// Only reductions in place are modifies; a requeued order is
+		// removed and added again
+		if change.Quantity == order.quantity {
+			return msgs
+		}
+		msg.Type = MsgOrderCancel
+		msg.Shares = uint32(order.quantity - change.Quantity)
+		order.quantity = change.Quantity
+	case models.BookEventDelete:
+		msg.Type = MsgOrderDelete
+		order.quantity = 0
+	default:
+		slog.Error("ITCH publisher got unknown book event", "type", change.Type)
+		return msgs
+	}
+
+	if order.quantity == 0 {
+		delete(p.orders, change.OrderID)
+	}
+	return append(msgs, msg.Encode())
+}
+
+func (p *Publisher) addOrder(msgs [][]byte, change models.OrderBookEvent) [][]byte {
+	msgs, locate, ok := p.locate(msgs, change.Symbol, change.Timestamp)
+	if !ok {
+		return msgs
+	}
+	p.nextRef++
+	p.orders[change.OrderID] = &restingOrder{
+		ref:      p.nextRef,
+		locate:   locate,
+		quantity: change.Quantity,
+	}
+	return append(msgs, (&Message{
+		Type:      MsgAddOrder,
+		Locate:    locate,
+		Timestamp: uint64(change.Timestamp.UnixNano()),
+		OrderRef:  p.nextRef,
+		Side:      encodeSide(change.Side),
+		Shares:    uint32(change.Quantity),
+		Price:     EncodePrice(change.Price),
+	}).Encode())
+}
+
+// locate returns the stock locate code for symbol, appending a symbol
+// directory message the first time the symbol is seen. Once every locate
+// code is in use it refuses new symbols, returning false, and nothing is
+// published for them until the next session.
+func (p *Publisher) locate(msgs [][]byte, symbol string, at time.Time) ([][]byte, uint16, bool) {
+	if locate, exists := p.locates[symbol]; exists {
+		return msgs, locate, true
+	}
+	if len(p.locates) >= maxLocates {
+		if !p.refused[symbol] {
+			p.refused[symbol] = true
+			slog.Error("ITCH publisher is out of locate codes, not publishing symbol", "symbol", symbol, "symbols", len(p.locates))
+		}
+		return msgs, 0, false
+	}
+	locate := uint16(len(p.locates) + 1)
+	p.locates[symbol] = locate
+	return append(msgs, (&Message{
+		Type:      MsgSymbolDirectory,
+		Locate:    locate,
+		Timestamp: uint64(at.UnixNano()),
+		Symbol:    symbol,
+	}).Encode()), locate, true
+}
+
+// publish numbers msgs, keeps them for retransmission and queues them for
+// multicast in as few packets as fit.
+func (p *Publisher) publish(msgs [][]byte) {
+	if len(msgs) == 0 {
+		return
+	}
+
+	packet := &Packet{Session: p.session, Sequence: p.nextSeq}
+	size := PacketHeaderLength
+	for _, msg := range msgs {
+		if len(packet.Messages) > 0 && size+2+len(msg) > MaxPacketSize {
+			p.queue(packet)
+			packet = &Packet{Session: p.session, Sequence: p.nextSeq}
+			size = PacketHeaderLength
+		}
+		packet.Messages = append(packet.Messages, msg)
+		size += 2 + len(msg)
+		p.retain(msg)
+	}
+	p.queue(packet)
+}
+
+func (p *Publisher) retain(msg []byte) {
+	p.retained = append(p.retained, msg)
+	p.nextSeq++
+	// Trim in batches so the backing array is not copied on every message
+	if excess := len(p.retained) - p.cfg.Retention; excess > p.cfg.Retention/4 {
+		p.retained = append([][]byte(nil), p.retained[excess:]...)
+		p.firstRetained += uint64(excess)
+	}
+}
+
+func (p *Publisher) queue(packet *Packet) {
+	select {
+	case p.packets <- packet.Encode():
+	default:
+		slog.Warn("ITCH send queue full, dropped packet", "sequence", packet.Sequence)
+	}
+}
+
+// ListenAndServe sends the multicast feed and serves retransmission
+// requests. It only returns if either socket fails.
+func (p *Publisher) ListenAndServe() error {
+	group, err := net.ResolveUDPAddr("udp4", p.cfg.MulticastAddr)
+	if err != nil {
+		return fmt.Errorf("invalid ITCH multicast address: %w", err)
+	}
+	if !group.IP.IsMulticast() {
+		return fmt.Errorf("ITCH address %s is not a multicast group", group.IP)
+	}
+	conn, err := net.ListenPacket("udp4", "0.0.0.0:0")
+	if err != nil {
+		return fmt.Errorf("failed to open ITCH multicast socket: %w", err)
+	}
+	defer conn.Close()
+
+	pc := ipv4.NewPacketConn(conn)
+	if p.cfg.Interface != "" {
+		ifi, err := net.InterfaceByName(p.cfg.Interface)
+		if err != nil {
+			return fmt.Errorf("failed to find ITCH multicast interface: %w", err)
+		}
+		if err := pc.SetMulticastInterface(ifi); err != nil {
+			return fmt.Errorf("failed to set ITCH multicast interface: %w", err)
+		}
+	}
+	if err := pc.SetMulticastTTL(max(p.cfg.TTL, 1)); err != nil {
+		return fmt.Errorf("failed to set ITCH multicast TTL: %w", err)
+	}
+	if err := pc.SetMulticastLoopback(true); err != nil {
+		return fmt.Errorf("failed to enable ITCH multicast loopback: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", p.cfg.RetransmitAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for ITCH retransmission requests: %w", err)
+	}
+	defer listener.Close()
+	slog.Info("ITCH publisher started", "session", p.session, "group", group.String(), "retransmit_addr", listener.Addr().String())
+
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- p.send(conn, group)
+	}()
+	go func() {
+		for {
+			client, err := listener.Accept()
+			if err != nil {
+				sendErr <- fmt.Errorf("failed to accept ITCH retransmission connection: %w", err)
+				return
+			}
+			go p.serveRetransmit(client)
+		}
+	}()
+	return <-sendErr
+}
+
+// send writes queued packets to the group, and a heartbeat after every
+// second without one.
+func (p *Publisher) send(conn net.PacketConn, group net.Addr) error {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	lastSent := time.Now()
+	for {
+		var packet []byte
+		select {
+		case packet = <-p.packets:
+		case <-ticker.C:
+			if time.Since(lastSent) < heartbeatInterval {
+				continue
+			}
+			p.mu.Lock()
+			packet = (&Packet{Session: p.session, Sequence: p.nextSeq}).Encode()
+			p.mu.Unlock()
+		}
+		if _, err := conn.WriteTo(packet, group); err != nil {
+			return fmt.Errorf("failed to send ITCH packet: %w", err)
+		}
+		lastSent = time.Now()
+	}
+}
+
+// serveRetransmit answers retransmission requests on one connection until
+// the client closes it or goes idle. Each request is a packet header naming
+// the first sequence number and message count wanted; the reply is a packet
+// holding as many of those messages as are still retained, starting at the
+// sequence number in its header.
+func (p *Publisher) serveRetransmit(conn net.Conn) {
+	defer conn.Close()
+
+	header := make([]byte, PacketHeaderLength)
+	for {
+		conn.SetReadDeadline(time.Now().Add(retransmitIdleTimeout))
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		request, count := decodeHeader(header)
+
+		reply := p.retransmission(request.Session, request.Sequence, count)
+		conn.SetWriteDeadline(time.Now().Add(retransmitIdleTimeout))
+		if _, err := conn.Write(reply.Encode()); err != nil {
+			return
+		}
+	}
+}
+
+// retransmission returns up to count retained messages from sequence. A
+// request for another session gets an empty reply carrying this session's
+// name.
+func (p *Publisher) retransmission(session string, sequence uint64, count uint16) *Packet {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	reply := &Packet{Session: p.session, Sequence: p.nextSeq}
+	if session != p.session || sequence >= p.nextSeq {
+		return reply
+	}
+
+	reply.Sequence = max(sequence, p.firstRetained)
+	start := reply.Sequence - p.firstRetained
+	end := min(start+uint64(count), uint64(len(p.retained)))
+	reply.Messages = append(reply.Messages, p.retained[start:end]...)
+	return reply
+}
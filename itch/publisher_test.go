@@ -0,0 +1,141 @@
+package itch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"order-matching-engine/clock"
+	"order-matching-engine/models"
+	"order-matching-engine/services"
+)
+
+func newTestPublisher(t *testing.T) (*Publisher, *services.MatchingEngine) {
+	t.Helper()
+	p, err := NewPublisher(Config{MulticastAddr: "239.1.1.1:30001", Retention: 100}, clock.System{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := services.NewMatchingEngine(services.WithStore(services.NewMemoryStore()), services.WithListener(p))
+	return p, engine
+}
+
+func place(t *testing.T, engine *services.MatchingEngine, id, symbol, side string, price float64, quantity int) {
+	t.Helper()
+	order := &models.Order{ID: id, Symbol: symbol, Side: side, Type: "limit", Price: &price,
+		InitialQuantity: quantity, RemainingQuantity: quantity, Status: "open"}
+	if _, err := engine.ProcessOrder(context.Background(), order); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// published describes the retained messages from sequence from onwards,
+// one "type:locate:ref:shares" per message.
+func published(t *testing.T, p *Publisher, from uint64) []string {
+	t.Helper()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var described []string
+	for _, raw := range p.retained[from-p.firstRetained:] {
+		msg, err := Decode(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch msg.Type {
+		case MsgSymbolDirectory:
+			described = append(described, fmt.Sprintf("R:%d:%s", msg.Locate, msg.Symbol))
+		case MsgTrade:
+			described = append(described, fmt.Sprintf("P:%d:%d:%d", msg.Locate, msg.Shares, msg.MatchNumber))
+		default:
+			described = append(described, fmt.Sprintf("%c:%d:%d:%d", msg.Type, msg.Locate, msg.OrderRef, msg.Shares))
+		}
+	}
+	return described
+}
+
+func assertPublished(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("published %v, want %v", got, want)
+	}
+}
+
+func TestPublisherMessagesForBookChanges(t *testing.T) {
+	p, engine := newTestPublisher(t)
+	place(t, engine, "b1", "AAPL", "buy", 100, 10)
+	place(t, engine, "b2", "AAPL", "buy", 100, 10)
+	assertPublished(t, published(t, p, 1), "R:1:AAPL", "A:1:1:10", "A:1:2:10")
+
+	place(t, engine, "s1", "AAPL", "sell", 100, 4)
+	assertPublished(t, published(t, p, 4), "P:1:4:1", "E:1:1:4")
+
+	// A reduction keeps the reference; a requeue is a delete and a new add
+	if _, _, err := engine.AmendOrder("b1", 8, nil); err != nil {
+		t.Fatal(err)
+	}
+	assertPublished(t, published(t, p, 6), "X:1:1:2")
+	if _, _, err := engine.AmendOrder("b2", 12, nil); err != nil {
+		t.Fatal(err)
+	}
+	assertPublished(t, published(t, p, 7), "D:1:2:0", "A:1:3:12")
+
+	if err := engine.CancelOrder("b1"); err != nil {
+		t.Fatal(err)
+	}
+	place(t, engine, "m1", "MSFT", "sell", 200, 1)
+	assertPublished(t, published(t, p, 9), "D:1:1:0", "R:2:MSFT", "A:2:4:1")
+}
+
+func TestPublisherRefusesSymbolsPastLocateLimit(t *testing.T) {
+	p, engine := newTestPublisher(t)
+	place(t, engine, "b1", "AAPL", "buy", 100, 10)
+	p.mu.Lock()
+	for i := len(p.locates); i < maxLocates; i++ {
+		p.locates[fmt.Sprintf("SYM%d", i)] = uint16(i + 1)
+	}
+	next := p.nextSeq
+	p.mu.Unlock()
+
+	// Nothing is published for a new symbol, including its trades
+	place(t, engine, "m1", "MSFT", "buy", 200, 5)
+	place(t, engine, "m2", "MSFT", "sell", 200, 2)
+	if err := engine.CancelOrder("m1"); err != nil {
+		t.Fatal(err)
+	}
+	assertPublished(t, published(t, p, next))
+
+	// Symbols that already have a locate code carry on
+	place(t, engine, "b2", "AAPL", "buy", 100, 3)
+	assertPublished(t, published(t, p, next), "A:1:2:3")
+	if p.locates["MSFT"] != 0 || !p.refused["MSFT"] {
+		t.Fatalf("MSFT locate %d, refused %v", p.locates["MSFT"], p.refused["MSFT"])
+	}
+}
+
+func TestRetransmissionFromRetainedMessages(t *testing.T) {
+	p, engine := newTestPublisher(t)
+	p.cfg.Retention = 4
+	for i := 0; i < 8; i++ {
+		place(t, engine, fmt.Sprintf("b%d", i), "AAPL", "buy", 100, 1)
+	}
+	// Nine messages, trimmed to the last five
+	p.mu.Lock()
+	first := p.firstRetained
+	p.mu.Unlock()
+	if first != 5 {
+		t.Fatalf("first retained = %d, want 5", first)
+	}
+
+	reply := p.retransmission(p.Session(), 2, 3)
+	if reply.Sequence != 5 || len(reply.Messages) != 3 {
+		t.Fatalf("reply from %d with %d messages, want 3 from 5", reply.Sequence, len(reply.Messages))
+	}
+	if msg, _ := Decode(reply.Messages[0]); msg.Type != MsgAddOrder || msg.OrderRef != 4 {
+		t.Fatalf("first message resent = %+v, want add of reference 4", msg)
+	}
+	if reply := p.retransmission("OTHER", 5, 3); len(reply.Messages) != 0 || reply.Sequence != 10 {
+		t.Fatalf("reply to another session = %+v", reply)
+	}
+}
@@ -0,0 +1,47 @@
+package journal
+
+import (
+	"order-matching-engine/models"
+	"time"
+)
+
+type EntryType string
+
+const (
+	// EntryOrderAccepted records an order as it entered the engine, before
+	// matching. Replaying these in sequence reproduces every trade.
+	EntryOrderAccepted EntryType = "order_accepted"
+	// EntryTrade records a trade produced by matching.
+	EntryTrade EntryType = "trade"
+	// EntryOrderCancelled records an order leaving the book by cancel request,
+	// mass cancel, or an unfilled market order remainder.
+	EntryOrderCancelled EntryType = "order_cancelled"
+	// EntryOrderRejected records an order the engine refused to accept.
+	EntryOrderRejected EntryType = "order_rejected"
+	// EntryOrderAmended records a resting order's new quantity and price,
+	// followed by any trades the amendment caused.
+	EntryOrderAmended EntryType = "order_amended"
+	// EntryRolledBack withdraws the entries From through To: the operation
+	// they record failed to commit to the database and was undone.
+	EntryRolledBack EntryType = "rolled_back"
+)
+
+// Reasons recorded on cancel and reject entries
+const (
+	ReasonCancelRequested = "cancel_requested"
+	ReasonMassCancel      = "mass_cancel"
+	ReasonAdminCancel     = "admin_cancel"
+	ReasonNoLiquidity     = "no_liquidity"
+)
+
+type Entry struct {
+	Sequence  uint64        `json:"seq"`
+	Type      EntryType     `json:"type"`
+	Timestamp time.Time     `json:"timestamp"`
+	Order     *models.Order `json:"order,omitempty"`
+	Trade     *models.Trade `json:"trade,omitempty"`
+	OrderID   string        `json:"order_id,omitempty"`
+	Reason    string        `json:"reason,omitempty"`
+	From      uint64        `json:"from,omitempty"`
+	To        uint64        `json:"to,omitempty"`
+}
@@ -0,0 +1,317 @@
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrCorrupt is returned when an entry in the middle of a journal fails its
+// checksum or cannot be decoded.
+var ErrCorrupt = errors.New("journal corrupt")
+
+// Journal is an append-only, sequenced log of engine inputs and outputs.
+//
+// Each entry is written as a single line: the CRC-32 (IEEE) of the JSON
+// payload as eight hex digits, a space, then the JSON payload itself.
+type Journal struct {
+	path    string
+	file    *os.File
+	writer  *bufio.Writer
+	lastSeq uint64
+	// The first failed write; see Append
+	err error
+	mu  sync.Mutex
+}
+
+// Open opens the journal at path, creating it if needed. Existing entries are
+// verified; a torn final line left by a crash mid-write is truncated away so
+// appends resume after the last complete entry.
+func Open(path string) (*Journal, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create journal directory: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	lastSeq, validSize, err := scan(file, nil)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if err := file.Truncate(validSize); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to truncate torn journal tail: %w", err)
+	}
+	if _, err := file.Seek(validSize, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to seek journal: %w", err)
+	}
+
+	return &Journal{
+		path:    path,
+		file:    file,
+		writer:  bufio.NewWriter(file),
+		lastSeq: lastSeq,
+	}, nil
+}
+
+// Append assigns the next sequence numbers to entries, writes them and syncs
+// the file before returning. A failed write may leave part of an entry
+// behind, so once one fails every later Append returns the same error.
+func (j *Journal) Append(entries ...*Entry) (err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.err != nil {
+		return j.err
+	}
+	defer func() { j.err = err }()
+
+	seq := j.lastSeq
+	for _, entry := range entries {
+		seq++
+		entry.Sequence = seq
+		if entry.Timestamp.IsZero() {
+			entry.Timestamp = time.Now()
+		}
+
+		line, err := encode(entry)
+		if err != nil {
+			return fmt.Errorf("failed to encode journal entry %d: %w", seq, err)
+		}
+		if _, err := j.writer.Write(line); err != nil {
+			return fmt.Errorf("failed to write journal entry %d: %w", seq, err)
+		}
+	}
+
+	if err := j.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush journal: %w", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+
+	j.lastSeq = seq
+	return nil
+}
+
+// LastSequence returns the sequence number of the last entry written.
+func (j *Journal) LastSequence() uint64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.lastSeq
+}
+
+// AdvanceTo moves the sequence counter forward to seq if it is behind, so a
+// journal emptied by truncation continues numbering after the snapshot that
+// replaced it.
+func (j *Journal) AdvanceTo(seq uint64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if seq > j.lastSeq {
+		j.lastSeq = seq
+	}
+}
+
+// Path returns the file the journal writes to.
+func (j *Journal) Path() string {
+	return j.path
+}
+
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.writer.Flush(); err != nil {
+		j.file.Close()
+		return fmt.Errorf("failed to flush journal: %w", err)
+	}
+	return j.file.Close()
+}
+
+// Read calls fn for every entry in the journal at path, in sequence order.
+// A torn final line is ignored; any other damage returns ErrCorrupt.
+func Read(path string, fn func(*Entry) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+
+	_, _, err = scan(file, fn)
+	return err
+}
+
+// ReadAfter calls fn for every entry in the journal at path with a sequence
+// number greater than seq.
+func ReadAfter(path string, seq uint64, fn func(*Entry) error) error {
+	return Read(path, func(entry *Entry) error {
+		if entry.Sequence <= seq {
+			return nil
+		}
+		return fn(entry)
+	})
+}
+
+// RolledBack returns the sequence numbers after seq, in the journal at path,
+// of the entries withdrawn by a later EntryRolledBack. Replay skips them and
+// the rolled_back entries themselves.
+func RolledBack(path string, seq uint64) (map[uint64]bool, error) {
+	withdrawn := make(map[uint64]bool)
+	err := ReadAfter(path, seq, func(entry *Entry) error {
+		if entry.Type == EntryRolledBack {
+			for s := max(entry.From, seq+1); s <= entry.To; s++ {
+				withdrawn[s] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return withdrawn, nil
+}
+
+// TruncateThrough drops every entry up to and including seq, typically once a
+// snapshot covering them has been saved. The remaining entries are copied to a
+// new file which atomically replaces the journal.
+func (j *Journal) TruncateThrough(seq uint64) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush journal: %w", err)
+	}
+
+	source, err := os.Open(j.path)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer source.Close()
+
+	var kept bytes.Buffer
+	reader := bufio.NewReader(source)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read journal: %w", err)
+		}
+		entry, err := decode(line)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+		if entry.Sequence > seq {
+			kept.Write(line)
+		}
+	}
+
+	if err := writeFileAtomic(j.path, kept.Bytes()); err != nil {
+		return fmt.Errorf("failed to rewrite journal: %w", err)
+	}
+
+	file, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen journal: %w", err)
+	}
+	j.file.Close()
+	j.file = file
+	j.writer = bufio.NewWriter(file)
+	return nil
+}
+
+// scan reads entries from r, verifying checksums and sequence continuity. It
+// returns the last sequence number seen and the byte length of the valid
+// prefix of the file.
+func scan(r io.Reader, fn func(*Entry) error) (uint64, int64, error) {
+	reader := bufio.NewReader(r)
+	var lastSeq uint64
+	var offset int64
+
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// Anything after the last newline is a partial write
+			return lastSeq, offset, nil
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to read journal: %w", err)
+		}
+
+		entry, decodeErr := decode(line)
+		if decodeErr != nil {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				// Corrupt final line from a crash mid-write
+				return lastSeq, offset, nil
+			}
+			return 0, 0, fmt.Errorf("%w: line %d: %v", ErrCorrupt, lineNo, decodeErr)
+		}
+		if entry.Sequence != lastSeq+1 && lastSeq != 0 {
+			return 0, 0, fmt.Errorf("%w: line %d: expected sequence %d, got %d",
+				ErrCorrupt, lineNo, lastSeq+1, entry.Sequence)
+		}
+
+		if fn != nil {
+			if err := fn(entry); err != nil {
+				return 0, 0, err
+			}
+		}
+
+		lastSeq = entry.Sequence
+		offset += int64(len(line))
+	}
+}
+
+func encode(entry *Entry) ([]byte, error) {
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	line := make([]byte, 0, len(payload)+10)
+	line = fmt.Appendf(line, "%08x ", crc32.ChecksumIEEE(payload))
+	line = append(line, payload...)
+	return append(line, '\n'), nil
+}
+
+func decode(line []byte) (*Entry, error) {
+	line = bytes.TrimRight(line, "\n")
+	if len(line) < 10 || line[8] != ' ' {
+		return nil, errors.New("malformed entry")
+	}
+
+	var checksum uint32
+	if _, err := fmt.Sscanf(string(line[:8]), "%08x", &checksum); err != nil {
+		return nil, fmt.Errorf("malformed checksum: %w", err)
+	}
+
+	payload := line[9:]
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, errors.New("checksum mismatch")
+	}
+
+	entry := &Entry{}
+	if err := json.Unmarshal(payload, entry); err != nil {
+		return nil, fmt.Errorf("malformed payload: %w", err)
+	}
+	return entry, nil
+}
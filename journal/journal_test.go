@@ -0,0 +1,200 @@
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTemp(t *testing.T) (*Journal, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "engine.journal")
+	j, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { j.Close() })
+	return j, path
+}
+
+func appendCancels(t *testing.T, j *Journal, orderIDs ...string) {
+	t.Helper()
+	for _, id := range orderIDs {
+		if err := j.Append(&Entry{Type: EntryOrderCancelled, OrderID: id}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+}
+
+func readAll(t *testing.T, path string) ([]*Entry, error) {
+	t.Helper()
+	var entries []*Entry
+	err := Read(path, func(entry *Entry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+func TestAppendNumbersAndReadsBack(t *testing.T) {
+	j, path := openTemp(t)
+	appendCancels(t, j, "a", "b")
+	batch := []*Entry{{Type: EntryOrderCancelled, OrderID: "c"}, {Type: EntryOrderCancelled, OrderID: "d"}}
+	if err := j.Append(batch...); err != nil {
+		t.Fatal(err)
+	}
+	if batch[0].Sequence != 3 || batch[1].Sequence != 4 || j.LastSequence() != 4 {
+		t.Fatalf("sequences %d, %d, last %d; want 3, 4, 4", batch[0].Sequence, batch[1].Sequence, j.LastSequence())
+	}
+
+	entries, err := readAll(t, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"a", "b", "c", "d"} {
+		if entries[i].Sequence != uint64(i+1) || entries[i].OrderID != want {
+			t.Fatalf("entry %d = %d %s, want %d %s", i, entries[i].Sequence, entries[i].OrderID, i+1, want)
+		}
+	}
+}
+
+func TestOpenTruncatesTornTail(t *testing.T) {
+	for name, tail := range map[string]string{
+		"partial line":      `0badc0de {"seq":3,"ty`,
+		"bad checksum line": "00000000 {\"seq\":3,\"type\":\"order_cancelled\"}\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			j, path := openTemp(t)
+			appendCancels(t, j, "a", "b")
+			j.Close()
+			valid, _ := os.ReadFile(path)
+			writeFile(t, path, append(valid, tail...))
+
+			j, err := Open(path)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			defer j.Close()
+			if j.LastSequence() != 2 {
+				t.Fatalf("LastSequence = %d, want 2", j.LastSequence())
+			}
+			if content, _ := os.ReadFile(path); !bytes.Equal(content, valid) {
+				t.Fatal("torn tail was not truncated")
+			}
+
+			appendCancels(t, j, "c")
+			entries, err := readAll(t, path)
+			if err != nil || len(entries) != 3 || entries[2].Sequence != 3 {
+				t.Fatalf("after append: %d entries, err %v", len(entries), err)
+			}
+		})
+	}
+}
+
+func TestBadChecksumMidJournalIsCorrupt(t *testing.T) {
+	j, path := openTemp(t)
+	appendCancels(t, j, "a", "b", "c")
+	j.Close()
+
+	content, _ := os.ReadFile(path)
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	// Change the order ID in the second entry without fixing its checksum
+	lines[1] = bytes.Replace(lines[1], []byte(`"b"`), []byte(`"x"`), 1)
+	writeFile(t, path, bytes.Join(lines, nil))
+
+	if _, err := readAll(t, path); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("Read error = %v, want ErrCorrupt", err)
+	}
+	if _, err := Open(path); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("Open error = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestSequenceGapIsCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "engine.journal")
+	var content []byte
+	for _, seq := range []uint64{1, 2, 4} {
+		line, err := encode(&Entry{Sequence: seq, Type: EntryOrderCancelled, OrderID: "a"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		content = append(content, line...)
+	}
+	writeFile(t, path, content)
+
+	if _, err := readAll(t, path); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("Read error = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestAppendErrorIsSticky(t *testing.T) {
+	j, path := openTemp(t)
+	appendCancels(t, j, "a")
+
+	j.file.Close()
+	first := j.Append(&Entry{Type: EntryOrderCancelled, OrderID: "b"})
+	if first == nil {
+		t.Fatal("Append to a closed file succeeded")
+	}
+
+	// Even once the file could be written again, the journal stays failed
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.file = file
+	j.writer = bufio.NewWriter(file)
+	if err := j.Append(&Entry{Type: EntryOrderCancelled, OrderID: "c"}); err != first {
+		t.Fatalf("second Append error = %v, want %v", err, first)
+	}
+	if j.LastSequence() != 1 {
+		t.Fatalf("LastSequence = %d, want 1", j.LastSequence())
+	}
+}
+
+func TestRolledBack(t *testing.T) {
+	j, path := openTemp(t)
+	appendCancels(t, j, "a", "b", "c")
+	if err := j.Append(&Entry{Type: EntryRolledBack, From: 2, To: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	withdrawn, err := RolledBack(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(withdrawn) != 2 || !withdrawn[2] || !withdrawn[3] {
+		t.Fatalf("RolledBack = %v, want 2 and 3", withdrawn)
+	}
+	// Only entries after the given sequence are reported
+	if withdrawn, _ := RolledBack(path, 2); len(withdrawn) != 1 || !withdrawn[3] {
+		t.Fatalf("RolledBack after 2 = %v, want 3", withdrawn)
+	}
+}
+
+func TestTruncateThroughKeepsNumbering(t *testing.T) {
+	j, path := openTemp(t)
+	appendCancels(t, j, "a", "b", "c")
+	if err := j.TruncateThrough(2); err != nil {
+		t.Fatal(err)
+	}
+	appendCancels(t, j, "d")
+
+	entries, err := readAll(t, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Sequence != 3 || entries[1].Sequence != 4 {
+		t.Fatalf("entries after truncation = %d, want sequences 3 and 4", len(entries))
+	}
+}
+
+func writeFile(t *testing.T, path string, content []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,164 @@
+package journal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"order-matching-engine/models"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const snapshotPrefix = "snapshot-"
+const snapshotSuffix = ".json"
+
+// Snapshot is the state of every order book as of journal entry Sequence.
+// Recovery loads it and replays only the entries after Sequence.
+type Snapshot struct {
+	Sequence  uint64         `json:"seq"`
+	CreatedAt time.Time      `json:"created_at"`
+	Books     []BookSnapshot `json:"books"`
+}
+
+type BookSnapshot struct {
+	Symbol string         `json:"symbol"`
+	Bids   []RestingOrder `json:"bids"`
+	Asks   []RestingOrder `json:"asks"`
+}
+
+// RestingOrder is an order in the book with its queue priority on its side,
+// starting at 1 for the best bid or ask.
+type RestingOrder struct {
+	Priority int           `json:"priority"`
+	Order    *models.Order `json:"order"`
+}
+
+// SnapshotStore keeps snapshots as files in a directory, named by sequence.
+type SnapshotStore struct {
+	dir  string
+	keep int
+}
+
+// NewSnapshotStore stores snapshots under dir, retaining the newest keep files.
+func NewSnapshotStore(dir string, keep int) *SnapshotStore {
+	if keep < 1 {
+		keep = 1
+	}
+	return &SnapshotStore{dir: dir, keep: keep}
+}
+
+// Save writes snap atomically and prunes snapshots beyond the retention count.
+func (s *SnapshotStore) Save(snap *Snapshot) (string, error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	payload, err := json.Marshal(snap)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	path := filepath.Join(s.dir, fmt.Sprintf("%s%020d%s", snapshotPrefix, snap.Sequence, snapshotSuffix))
+	content := fmt.Appendf(nil, "%08x ", crc32.ChecksumIEEE(payload))
+	content = append(content, payload...)
+	if err := writeFileAtomic(path, append(content, '\n')); err != nil {
+		return "", fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	if err := s.prune(); err != nil {
+		return path, fmt.Errorf("failed to prune snapshots: %w", err)
+	}
+	return path, nil
+}
+
+// Latest loads the newest snapshot, or returns nil if there is none.
+func (s *SnapshotStore) Latest() (*Snapshot, error) {
+	paths, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(paths[len(paths)-1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	content = bytes.TrimRight(content, "\n")
+	if len(content) < 10 || content[8] != ' ' {
+		return nil, fmt.Errorf("%w: malformed snapshot %s", ErrCorrupt, paths[len(paths)-1])
+	}
+	payload := content[9:]
+	if fmt.Sprintf("%08x", crc32.ChecksumIEEE(payload)) != string(content[:8]) {
+		return nil, fmt.Errorf("%w: checksum mismatch in snapshot %s", ErrCorrupt, paths[len(paths)-1])
+	}
+
+	snap := &Snapshot{}
+	if err := json.Unmarshal(payload, snap); err != nil {
+		return nil, fmt.Errorf("%w: malformed snapshot %s: %v", ErrCorrupt, paths[len(paths)-1], err)
+	}
+	return snap, nil
+}
+
+// list returns snapshot paths ordered oldest first.
+func (s *SnapshotStore) list() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, snapshotPrefix) && strings.HasSuffix(name, snapshotSuffix) {
+			paths = append(paths, filepath.Join(s.dir, name))
+		}
+	}
+	// Zero-padded sequence numbers sort lexically
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (s *SnapshotStore) prune() error {
+	paths, err := s.list()
+	if err != nil {
+		return err
+	}
+	for len(paths) > s.keep {
+		if err := os.Remove(paths[0]); err != nil {
+			return err
+		}
+		paths = paths[1:]
+	}
+	return nil
+}
+
+func writeFileAtomic(path string, content []byte) error {
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"order-matching-engine/httpstatus"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request ID on requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// Longest request ID accepted from a client
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID in ctx, or "" if it has none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Middleware gives every request an ID, keeping the client's X-Request-ID
+// if it sent a usable one. The ID is echoed in the response header, added to
+// the request's trace span and logged with every record written with the
+// request's context, including the access log line written once the
+// response is complete.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := NewRequestID(r.Header.Get(RequestIDHeader))
+		w.Header().Set(RequestIDHeader, id)
+		ctx := WithRequestID(r.Context(), id)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("http.request_id", id))
+
+		start := time.Now()
+		recorder := httpstatus.NewRecorder(w)
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		// 503 means trading is halted or in maintenance, not a server fault
+		level := slog.LevelInfo
+		switch {
+		case recorder.Status == http.StatusServiceUnavailable:
+			level = slog.LevelWarn
+		case recorder.Status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		}
+		slog.Log(ctx, level, "request completed",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.Status,
+			"duration_ms", float64(time.Since(start).Microseconds())/1000,
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
+
+// NewRequestID returns the ID a client sent if it is usable, else a new one.
+func NewRequestID(clientID string) string {
+	if validRequestID(clientID) {
+		return clientID
+	}
+	return uuid.NewString()
+}
+
+// validRequestID accepts IDs of letters, digits and -_.: so a client cannot
+// inject arbitrary text into the logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -1,43 +1,282 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"order-matching-engine/auth"
+	"order-matching-engine/clock"
+	"order-matching-engine/config"
 	"order-matching-engine/database"
+	"order-matching-engine/enginepb"
+	"order-matching-engine/fix"
+	"order-matching-engine/grpcserver"
 	"order-matching-engine/handlers"
+	"order-matching-engine/idgen"
+	"order-matching-engine/itch"
+	"order-matching-engine/journal"
+	"order-matching-engine/logging"
+	"order-matching-engine/marketdata"
+	"order-matching-engine/metrics"
+	"order-matching-engine/orderstream"
+	"order-matching-engine/ouch"
+	"order-matching-engine/ratelimit"
 	"order-matching-engine/services"
+	"order-matching-engine/tracing"
+	"os"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/joho/godotenv"
+	"google.golang.org/grpc"
 )
 
 func main() {
+	forceSnapshot := flag.Bool("snapshot", false, "write an engine snapshot after recovery, truncate the journal and exit")
+
+	// Variables in .env count as environment variables
+	envErr := godotenv.Load()
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fatal("Failed to load configuration", err)
+	}
+	if err := logging.Init(logging.Config{Level: cfg.Logging.Level, Format: cfg.Logging.Format}, os.Stderr); err != nil {
+		fatal("Invalid logging configuration", err)
+	}
+	if envErr != nil {
+		slog.Info("No .env file found, using environment variables and configuration file")
+	}
+
+	// Export traces over OTLP if an endpoint is configured
+	shutdownTracing, err := tracing.Init(tracing.Config{
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("Failed to initialize tracing", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	clk := clock.System{}
+	limiter, err := newRateLimiter(cfg.RateLimit, clk)
+	if err != nil {
+		fatal("Failed to configure rate limits", err)
+	}
+
 	// Initialize database
-	if err := database.InitDB(); err != nil {
-		log.Fatal("Failed to initialize database:", err)
+	if err := database.InitDB(cfg.Database); err != nil {
+		fatal("Failed to initialize database", err)
 	}
+	metrics.RegisterDB(database.DB)
 
-	// Initialize matching engine
-	engine := services.NewMatchingEngine()
+	// Choose how order and trade IDs are generated
+	orderIDs, tradeIDs, err := newIDGenerators(cfg.IDScheme)
+	if err != nil {
+		fatal("Failed to initialize ID generators", err)
+	}
+
+	// Open the engine journal
+	engineJournal, err := journal.Open(cfg.Journal.Path)
+	if err != nil {
+		fatal("Failed to open journal", err)
+	}
+	defer engineJournal.Close()
+
+	// Initialize matching engine and rebuild its order books from the latest
+	// snapshot plus the journal entries written after it
+	feed := marketdata.NewFeed()
+	orderEvents := orderstream.NewHub(10000)
+	candles := services.NewCandleService(5000)
+	tickers := services.NewTickerService(clk)
+	engineOpts := []services.Option{
+		services.WithJournal(engineJournal),
+		services.WithClock(clk),
+		services.WithTradeIDs(tradeIDs),
+		services.WithListener(feed),
+		services.WithListener(orderEvents),
+		services.WithListener(candles),
+		services.WithListener(tickers),
+		services.WithMetrics(metrics.Engine{}),
+		services.WithListener(metrics.Engine{}),
+	}
+
+	// FIX order entry is enabled by setting fix.listen_addr
+	var fixAcceptor *fix.Acceptor
+	if cfg.FIX.ListenAddr != "" {
+		fixAcceptor, err = newFIXAcceptor(cfg.FIX, clk, orderIDs)
+		if err != nil {
+			fatal("Failed to initialize FIX acceptor", err)
+		}
+		engineOpts = append(engineOpts, services.WithListener(fixAcceptor))
+	}
+
+	// Binary OUCH order entry is enabled by setting ouch.listen_addr
+	var ouchGateway *ouch.Gateway
+	if cfg.OUCH.ListenAddr != "" {
+		ouchGateway, err = newOUCHGateway(cfg.OUCH, clk, orderIDs)
+		if err != nil {
+			fatal("Failed to initialize OUCH gateway", err)
+		}
+		engineOpts = append(engineOpts, services.WithListener(ouchGateway))
+	}
+
+	// Binary multicast market data is enabled by setting itch.multicast_addr
+	var itchPublisher *itch.Publisher
+	if cfg.ITCH.MulticastAddr != "" {
+		itchPublisher, err = newITCHPublisher(cfg.ITCH, clk)
+		if err != nil {
+			fatal("Failed to initialize ITCH publisher", err)
+		}
+		engineOpts = append(engineOpts, services.WithListener(itchPublisher))
+	}
+	engine := services.NewMatchingEngine(engineOpts...)
+	metrics.RegisterEngine(engine)
+	snapshots := journal.NewSnapshotStore(cfg.Journal.SnapshotDir, 3)
+	lastSeq, err := engine.Recover(snapshots, engineJournal.Path())
+	if err != nil {
+		fatal("Failed to recover engine state", err)
+	}
+	slog.Info("Recovered engine state", "sequence", lastSeq)
+	recovered := engine.Snapshot()
+	feed.Seed(recovered.Books)
+	if itchPublisher != nil {
+		itchPublisher.Seed(recovered.Books)
+	}
+
+	// Rebuild recent candles and 24h ticker statistics from stored trades
+	history, err := database.GetTradesSince(context.Background(), clk.Now().Add(-max(cfg.CandleBackfill, 24*time.Hour)))
+	if err != nil {
+		fatal("Failed to load trade history", err)
+	}
+	candles.Backfill(history)
+	tickers.Backfill(history)
+	slog.Info("Backfilled candles and tickers", "trades", len(history))
+
+	if *forceSnapshot {
+		snap, err := engine.TakeSnapshot(snapshots)
+		if err != nil {
+			fatal("Failed to write snapshot", err)
+		}
+		slog.Info("Wrote snapshot", "sequence", snap.Sequence)
+		return
+	}
+
+	go runSnapshots(engine, engineJournal, snapshots, cfg.Journal.SnapshotInterval)
+
+	if itchPublisher != nil {
+		go func() {
+			fatal("ITCH publisher stopped", itchPublisher.ListenAndServe())
+		}()
+	}
+	if fixAcceptor != nil {
+		go func() {
+			fatal("FIX acceptor stopped", fixAcceptor.ListenAndServe(engine))
+		}()
+	}
+	if ouchGateway != nil {
+		go func() {
+			fatal("OUCH gateway stopped", ouchGateway.ListenAndServe(engine))
+		}()
+	}
 
 	// Initialize handlers
-	orderHandler := handlers.NewOrderHandler(engine)
+	orderHandler := handlers.NewOrderHandler(engine, clk, orderIDs, cfg.Book)
 	tradeHandler := handlers.NewTradeHandler()
+	marketDataHandler := handlers.NewMarketDataHandler(feed)
+	streamHandler := handlers.NewStreamHandler(orderEvents)
+	candleHandler := handlers.NewCandleHandler(candles)
+	tickerHandler := handlers.NewTickerHandler(engine, tickers)
+	apiKeys := auth.NewKeys(nil, clk)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeys)
+	adminHandler := handlers.NewAdminHandler(engine, snapshots)
 
 	// Setup routes
 	router := mux.NewRouter()
+	router.Use(tracing.Middleware(), logging.Middleware, metrics.Middleware)
+
+	// API key management and engine controls, for operators holding the
+	// admin token
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(auth.RequireAdmin(cfg.Auth.AdminToken))
+	admin.HandleFunc("/api-keys", apiKeyHandler.CreateKey).Methods("POST")
+	admin.HandleFunc("/api-keys", apiKeyHandler.ListKeys).Methods("GET")
+	admin.HandleFunc("/api-keys", methodNotAllowed).Methods("PUT", "DELETE", "PATCH")
+	admin.HandleFunc("/api-keys/{id}", apiKeyHandler.RevokeKey).Methods("DELETE")
+	admin.HandleFunc("/api-keys/{id}", methodNotAllowed).Methods("GET", "POST", "PUT", "PATCH")
+	admin.HandleFunc("/symbols", adminHandler.ListSymbols).Methods("GET")
+	admin.HandleFunc("/symbols", methodNotAllowed).Methods("POST", "PUT", "DELETE", "PATCH")
+	admin.HandleFunc("/symbols/{symbol}/halt", adminHandler.HaltSymbol).Methods("POST")
+	admin.HandleFunc("/symbols/{symbol}/halt", methodNotAllowed).Methods("GET", "PUT", "DELETE", "PATCH")
+	admin.HandleFunc("/symbols/{symbol}/resume", adminHandler.ResumeSymbol).Methods("POST")
+	admin.HandleFunc("/symbols/{symbol}/resume", methodNotAllowed).Methods("GET", "PUT", "DELETE", "PATCH")
+	admin.HandleFunc("/orders/cancel", adminHandler.CancelOrders).Methods("POST")
+	admin.HandleFunc("/orders/cancel", methodNotAllowed).Methods("GET", "PUT", "DELETE", "PATCH")
+	admin.HandleFunc("/snapshots", adminHandler.TakeSnapshot).Methods("POST")
+	admin.HandleFunc("/snapshots", methodNotAllowed).Methods("GET", "PUT", "DELETE", "PATCH")
+	admin.HandleFunc("/engine", adminHandler.GetEngineStatus).Methods("GET")
+	admin.HandleFunc("/engine", methodNotAllowed).Methods("POST", "PUT", "DELETE", "PATCH")
+	admin.HandleFunc("/maintenance", adminHandler.SetMaintenance).Methods("PUT")
+	admin.HandleFunc("/maintenance", methodNotAllowed).Methods("GET", "POST", "DELETE", "PATCH")
+
+	// Public market data, for any client including browsers, which cannot
+	// sign the WebSocket handshake; rate limited per client IP only
+	public := router.NewRoute().Subrouter()
+	public.Use(limiter.PerIP)
+	if cfg.Features.MarketDataWS {
+		public.HandleFunc("/ws/marketdata", marketDataHandler.ServeWebSocket).Methods("GET")
+	}
+
+	// Every other endpoint but the health check takes requests signed with
+	// an API key, rate limited per client IP and per account
+	api := router.NewRoute().Subrouter()
+	api.Use(limiter.PerIP)
+	var authenticator *auth.Authenticator
+	if cfg.Auth.Disabled {
+		slog.Warn("API key authentication is disabled (auth.disabled)")
+	} else {
+		authenticator = auth.NewAuthenticator(apiKeys, clk, cfg.Auth.Window)
+		api.Use(authenticator.Middleware)
+	}
+	api.Use(limiter.PerAccount)
 	
 	// Order endpoints with method validation
-	router.HandleFunc("/orders", orderHandler.PlaceOrder).Methods("POST")
-	router.HandleFunc("/orders", methodNotAllowed).Methods("GET", "PUT", "DELETE", "PATCH")
-	router.HandleFunc("/orders/{id}", orderHandler.GetOrder).Methods("GET")
-	router.HandleFunc("/orders/{id}", orderHandler.CancelOrder).Methods("DELETE")
-	router.HandleFunc("/orders/{id}", methodNotAllowed).Methods("POST", "PUT", "PATCH")
-	router.HandleFunc("/orderbook", orderHandler.GetOrderBook).Methods("GET")
-	router.HandleFunc("/orderbook", methodNotAllowed).Methods("POST", "PUT", "DELETE", "PATCH")
+	api.HandleFunc("/orders", orderHandler.PlaceOrder).Methods("POST")
+	api.HandleFunc("/orders", orderHandler.MassCancel).Methods("DELETE")
+	api.HandleFunc("/orders", orderHandler.ListOrders).Methods("GET")
+	api.HandleFunc("/orders", methodNotAllowed).Methods("PUT", "PATCH")
+	api.HandleFunc("/orders/batch", orderHandler.PlaceOrderBatch).Methods("POST")
+	api.HandleFunc("/orders/batch", methodNotAllowed).Methods("GET", "PUT", "DELETE", "PATCH")
+	api.HandleFunc("/orders/batch/cancel", orderHandler.CancelOrderBatch).Methods("POST")
+	api.HandleFunc("/orders/batch/cancel", methodNotAllowed).Methods("GET", "PUT", "DELETE", "PATCH")
+	api.HandleFunc("/orders/{id}", orderHandler.GetOrder).Methods("GET")
+	api.HandleFunc("/orders/{id}", orderHandler.CancelOrder).Methods("DELETE")
+	api.HandleFunc("/orders/{id}", methodNotAllowed).Methods("POST", "PUT", "PATCH")
+	api.HandleFunc("/orders/{id}/events", orderHandler.GetOrderEvents).Methods("GET")
+	api.HandleFunc("/orders/{id}/events", methodNotAllowed).Methods("POST", "PUT", "DELETE", "PATCH")
+	api.HandleFunc("/orderbook", orderHandler.GetOrderBook).Methods("GET")
+	api.HandleFunc("/orderbook", methodNotAllowed).Methods("POST", "PUT", "DELETE", "PATCH")
 	
 	// Trade endpoints with method validation
-	router.HandleFunc("/trades", tradeHandler.GetTrades).Methods("GET")
-	router.HandleFunc("/trades", methodNotAllowed).Methods("POST", "PUT", "DELETE", "PATCH")
+	api.HandleFunc("/trades", tradeHandler.GetTrades).Methods("GET")
+	api.HandleFunc("/trades", methodNotAllowed).Methods("POST", "PUT", "DELETE", "PATCH")
+
+	// OHLCV candles
+	api.HandleFunc("/candles", candleHandler.GetCandles).Methods("GET")
+	api.HandleFunc("/candles", methodNotAllowed).Methods("POST", "PUT", "DELETE", "PATCH")
+
+	// Ticker and 24h statistics
+	api.HandleFunc("/ticker", tickerHandler.GetTicker).Methods("GET")
+	api.HandleFunc("/ticker", methodNotAllowed).Methods("POST", "PUT", "DELETE", "PATCH")
+
+	// Order status and trade event stream (Server-Sent Events)
+	if cfg.Features.EventStream {
+		api.HandleFunc("/stream", streamHandler.StreamEvents).Methods("GET")
+		api.HandleFunc("/stream", methodNotAllowed).Methods("POST", "PUT", "DELETE", "PATCH")
+	}
 
 	// Health check with method validation
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -46,12 +285,168 @@ func main() {
 	}).Methods("GET")
 	router.HandleFunc("/health", methodNotAllowed).Methods("POST", "PUT", "DELETE", "PATCH")
 
-	log.Println("Order Matching Engine starting on port 8080...")
-	log.Fatal(http.ListenAndServe(":8080", router))
+	// Prometheus metrics, behind the metrics.token bearer token
+	if cfg.Features.Metrics {
+		metricsHandler := auth.RequireMetricsToken(cfg.Metrics.Token)(metrics.Handler())
+		router.Handle("/metrics", metricsHandler).Methods("GET")
+		router.HandleFunc("/metrics", methodNotAllowed).Methods("POST", "PUT", "DELETE", "PATCH")
+	}
+
+	// gRPC API on the same engine and market data feed, with the same
+	// authentication and rate limits
+	if cfg.Features.GRPC {
+		grpcListener, err := net.Listen("tcp", cfg.GRPC.ListenAddr)
+		if err != nil {
+			fatal("Failed to listen for gRPC", err)
+		}
+		guard := grpcserver.NewGuard(authenticator, limiter)
+		grpcServer := grpc.NewServer(
+			grpc.ChainUnaryInterceptor(grpcserver.UnaryRequestID, guard.Unary),
+			grpc.ChainStreamInterceptor(grpcserver.StreamRequestID, guard.Stream),
+		)
+		enginepb.RegisterMatchingEngineServer(grpcServer, grpcserver.NewServer(engine, feed, orderIDs))
+		go func() {
+			slog.Info("gRPC API listening", "addr", grpcListener.Addr().String())
+			fatal("gRPC server stopped", grpcServer.Serve(grpcListener))
+		}()
+	}
+
+	server := &http.Server{
+		Addr:              cfg.HTTP.ListenAddr,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+	}
+	slog.Info("Order Matching Engine starting", "addr", cfg.HTTP.ListenAddr)
+	fatal("HTTP server stopped", server.ListenAndServe())
+}
+
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
 
 func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusMethodNotAllowed)
 	w.Write([]byte(`{"success":false,"error":"Method not allowed"}`))
-}
\ No newline at end of file
+}
+
+// runSnapshots periodically snapshots the engine, skipping intervals in which
+// nothing was journaled.
+func runSnapshots(engine *services.MatchingEngine, engineJournal *journal.Journal, store *journal.SnapshotStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastSeq uint64
+	for range ticker.C {
+		if engineJournal.LastSequence() == lastSeq {
+			continue
+		}
+		snap, err := engine.TakeSnapshot(store)
+		if err != nil {
+			slog.Error("Failed to write snapshot", "error", err)
+			continue
+		}
+		lastSeq = snap.Sequence
+		slog.Info("Wrote snapshot", "sequence", snap.Sequence)
+	}
+}
+
+// newFIXAcceptor configures the FIX gateway. cfg.Sessions lists the
+// counterparties allowed to log on.
+func newFIXAcceptor(cfg config.FIXConfig, clk clock.Clock, orderIDs idgen.Generator) (*fix.Acceptor, error) {
+	sessions, err := fix.ParseSessions(cfg.Sessions)
+	if err != nil {
+		return nil, err
+	}
+	return fix.NewAcceptor(fix.Config{
+		ListenAddr: cfg.ListenAddr,
+		CompID:     cfg.CompID,
+		StoreDir:   cfg.StoreDir,
+		Sessions:   sessions,
+	}, clk, orderIDs)
+}
+
+// newOUCHGateway configures binary order entry. cfg.Users lists the users
+// allowed to log in.
+func newOUCHGateway(cfg config.OUCHConfig, clk clock.Clock, orderIDs idgen.Generator) (*ouch.Gateway, error) {
+	users, err := ouch.ParseUsers(cfg.Users)
+	if err != nil {
+		return nil, err
+	}
+	return ouch.NewGateway(ouch.Config{ListenAddr: cfg.ListenAddr, Users: users}, clk, orderIDs)
+}
+
+// newRateLimiter configures REST and gRPC API rate limits.
+func newRateLimiter(cfg config.RateLimitConfig, clk clock.Clock) (*ratelimit.Limiter, error) {
+	tiers, err := ratelimit.ParseTiers(cfg.Tiers)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate_limit.tiers: %w", err)
+	}
+	accounts, err := ratelimit.ParseAccountTiers(cfg.Accounts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate_limit.accounts: %w", err)
+	}
+	perIP, err := ratelimit.ParseLimits(cfg.PerIP)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate_limit.per_ip: %w", err)
+	}
+	return ratelimit.New(ratelimit.Config{
+		Tiers:        tiers,
+		AccountTiers: accounts,
+		DefaultTier:  cfg.DefaultTier,
+		PerIP:        perIP,
+		TrustProxy:   cfg.TrustProxy,
+	}, clk)
+}
+
+// newITCHPublisher configures the multicast feed.
+func newITCHPublisher(cfg config.ITCHConfig, clk clock.Clock) (*itch.Publisher, error) {
+	return itch.NewPublisher(itch.Config{
+		MulticastAddr:  cfg.MulticastAddr,
+		Interface:      cfg.Interface,
+		TTL:            cfg.TTL,
+		RetransmitAddr: cfg.RetransmitAddr,
+		Retention:      cfg.Retention,
+	}, clk)
+}
+
+// newIDGenerators returns the order and trade ID generators for scheme,
+// either "uuid" or "sequence". Sequence generators resume after the highest
+// IDs already stored so numbering stays monotonic across restarts.
+func newIDGenerators(scheme string) (idgen.Generator, idgen.Generator, error) {
+	switch scheme {
+	case "uuid":
+		return idgen.UUID{}, idgen.UUID{}, nil
+	case "sequence":
+		orderIDs, err := resumeSequence("O", database.LastOrderIDWithPrefix)
+		if err != nil {
+			return nil, nil, err
+		}
+		tradeIDs, err := resumeSequence("T", database.LastTradeIDWithPrefix)
+		if err != nil {
+			return nil, nil, err
+		}
+		return orderIDs, tradeIDs, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown ID scheme %q (want uuid or sequence)", scheme)
+	}
+}
+
+func resumeSequence(prefix string, lastID func(context.Context, string) (string, error)) (*idgen.Sequence, error) {
+	id, err := lastID(context.Background(), prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find last %s ID: %w", prefix, err)
+	}
+
+	var last uint64
+	if id != "" {
+		if last, err = idgen.ParseSequence(prefix, id); err != nil {
+			return nil, err
+		}
+	}
+	return idgen.NewSequence(prefix, last), nil
+}
@@ -0,0 +1,344 @@
+package marketdata
+
+import (
+	"errors"
+	"log/slog"
+	"order-matching-engine/journal"
+	"order-matching-engine/models"
+	"order-matching-engine/services"
+	"sort"
+	"sync"
+)
+
+const (
+	ChannelBook   = "book"
+	ChannelTrades = "trades"
+	ChannelOrders = "orders"
+)
+
+// ErrUnknownChannel is returned when subscribing to a channel the feed does
+// not publish.
+var ErrUnknownChannel = errors.New("unknown channel")
+
+// Message is one market data update delivered to a subscriber.
+//
+// Book channels start with a "snapshot" of every price level, followed by
+// "update" messages carrying the new absolute state of each changed level.
+// Orders channels start with a "snapshot" listing every resting order as an
+// add event in queue priority, followed by one "update" per order-level
+// event. Seq increases by one per message for each symbol and channel, so a
+// gap means updates were lost and the client should resubscribe.
+type Message struct {
+	Channel  string                    `json:"channel"`
+	Type     string                    `json:"type"`
+	Symbol   string                    `json:"symbol"`
+	Sequence uint64                    `json:"seq"`
+	Bids     []models.PriceLevelUpdate `json:"bids,omitempty"`
+	Asks     []models.PriceLevelUpdate `json:"asks,omitempty"`
+	Changes  []models.PriceLevelUpdate `json:"changes,omitempty"`
+	Trade    *models.Trade             `json:"trade,omitempty"`
+	Orders   []models.OrderBookEvent   `json:"orders,omitempty"`
+	Event    *models.OrderBookEvent    `json:"event,omitempty"`
+}
+
+// Feed turns engine events into per-symbol L2 book, L3 order and trade
+// streams. It keeps its own copy of every price level and resting order so
+// new subscribers get a snapshot that is consistent with the sequence
+// numbers of the updates that follow.
+type Feed struct {
+	books       map[string]*levelBook
+	orderBooks  map[string]*orderBook
+	tradeSeq    map[string]uint64
+	subscribers map[*Subscription]struct{}
+	mu          sync.Mutex
+}
+
+type orderBook struct {
+	seq  uint64
+	book *L3Book
+}
+
+type levelBook struct {
+	seq  uint64
+	bids map[float64]models.PriceLevelUpdate
+	asks map[float64]models.PriceLevelUpdate
+}
+
+func NewFeed() *Feed {
+	return &Feed{
+		books:       make(map[string]*levelBook),
+		orderBooks:  make(map[string]*orderBook),
+		tradeSeq:    make(map[string]uint64),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Seed loads every resting order from an engine snapshot, typically right
+// after the engine has recovered and before it accepts orders.
+func (f *Feed) Seed(books []journal.BookSnapshot) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, snap := range books {
+		levels := f.book(snap.Symbol)
+		orders := f.orderBook(snap.Symbol)
+		for _, resting := range append(append([]journal.RestingOrder(nil), snap.Bids...), snap.Asks...) {
+			order := resting.Order
+			if order.Price == nil {
+				continue
+			}
+			orders.book.Apply(models.OrderBookEvent{
+				Type:      models.BookEventAdd,
+				Symbol:    snap.Symbol,
+				OrderID:   order.ID,
+				Side:      order.Side,
+				Price:     *order.Price,
+				Quantity:  order.RemainingQuantity,
+				Timestamp: order.CreatedAt,
+			})
+			levels.add(order.Side, *order.Price, order.RemainingQuantity)
+		}
+	}
+}
+
+// OnEvent implements services.Listener.
+func (f *Feed) OnEvent(event services.Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch event.Type {
+	case services.EventBookUpdate:
+		if len(event.Levels) == 0 {
+			return
+		}
+		book := f.book(event.Symbol)
+		book.apply(event.Levels)
+		book.seq++
+		f.publish(Message{
+			Channel:  ChannelBook,
+			Type:     "update",
+			Symbol:   event.Symbol,
+			Sequence: book.seq,
+			Changes:  event.Levels,
+		})
+	case services.EventOrderBook:
+		orders := f.orderBook(event.Symbol)
+		for i := range event.Orders {
+			change := event.Orders[i]
+			if err := orders.book.Apply(change); err != nil {
+				slog.Error("order book feed out of step with engine", "symbol", event.Symbol, "error", err)
+			}
+			orders.seq++
+			change.Sequence = orders.seq
+			f.publish(Message{
+				Channel:  ChannelOrders,
+				Type:     "update",
+				Symbol:   event.Symbol,
+				Sequence: orders.seq,
+				Event:    &change,
+			})
+		}
+	case services.EventTrade:
+		f.tradeSeq[event.Symbol]++
+		f.publish(Message{
+			Channel:  ChannelTrades,
+			Type:     "trade",
+			Symbol:   event.Symbol,
+			Sequence: f.tradeSeq[event.Symbol],
+			Trade:    event.Trade,
+		})
+	}
+}
+
+// Subscribe registers a new subscriber with room for buffer undelivered
+// messages. A subscriber that falls further behind is dropped and its
+// channel closed rather than slowing down the engine.
+func (f *Feed) Subscribe(buffer int) *Subscription {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sub := &Subscription{
+		feed:     f,
+		messages: make(chan Message, buffer),
+		topics:   make(map[topic]bool),
+	}
+	f.subscribers[sub] = struct{}{}
+	return sub
+}
+
+func (f *Feed) book(symbol string) *levelBook {
+	book, exists := f.books[symbol]
+	if !exists {
+		book = &levelBook{
+			bids: make(map[float64]models.PriceLevelUpdate),
+			asks: make(map[float64]models.PriceLevelUpdate),
+		}
+		f.books[symbol] = book
+	}
+	return book
+}
+
+func (f *Feed) orderBook(symbol string) *orderBook {
+	orders, exists := f.orderBooks[symbol]
+	if !exists {
+		orders = &orderBook{book: NewL3Book(symbol)}
+		f.orderBooks[symbol] = orders
+	}
+	return orders
+}
+
+func (f *Feed) publish(msg Message) {
+	key := topic{channel: msg.Channel, symbol: msg.Symbol}
+	for sub := range f.subscribers {
+		if sub.topics[key] {
+			f.deliver(sub, msg)
+		}
+	}
+}
+
+// deliver sends without blocking; must be called with f.mu held.
+func (f *Feed) deliver(sub *Subscription, msg Message) {
+	select {
+	case sub.messages <- msg:
+	default:
+		f.drop(sub)
+	}
+}
+
+// drop removes sub and closes its channel; must be called with f.mu held.
+func (f *Feed) drop(sub *Subscription) {
+	if _, exists := f.subscribers[sub]; !exists {
+		return
+	}
+	delete(f.subscribers, sub)
+	close(sub.messages)
+}
+
+func (b *levelBook) apply(levels []models.PriceLevelUpdate) {
+	for _, level := range levels {
+		side := b.asks
+		if level.Side == "buy" {
+			side = b.bids
+		}
+		if level.Quantity == 0 {
+			delete(side, level.Price)
+		} else {
+			side[level.Price] = level
+		}
+	}
+}
+
+func (b *levelBook) add(side string, price float64, quantity int) {
+	levels := b.asks
+	if side == "buy" {
+		levels = b.bids
+	}
+	level := levels[price]
+	level.Side = side
+	level.Price = price
+	level.Quantity += quantity
+	level.Orders++
+	levels[price] = level
+}
+
+func (b *levelBook) snapshot(symbol string) Message {
+	return Message{
+		Channel:  ChannelBook,
+		Type:     "snapshot",
+		Symbol:   symbol,
+		Sequence: b.seq,
+		Bids:     sortedLevels(b.bids, func(a, b float64) bool { return a > b }),
+		Asks:     sortedLevels(b.asks, func(a, b float64) bool { return a < b }),
+	}
+}
+
+func (o *orderBook) snapshot(symbol string) Message {
+	resting := append(o.book.Bids(), o.book.Asks()...)
+	for i := range resting {
+		resting[i].Type = models.BookEventAdd
+		resting[i].Sequence = o.seq
+		resting[i].ExecutedQuantity = 0
+		resting[i].TradeID = ""
+	}
+	return Message{
+		Channel:  ChannelOrders,
+		Type:     "snapshot",
+		Symbol:   symbol,
+		Sequence: o.seq,
+		Orders:   resting,
+	}
+}
+
+func sortedLevels(levels map[float64]models.PriceLevelUpdate, better func(a, b float64) bool) []models.PriceLevelUpdate {
+	sorted := make([]models.PriceLevelUpdate, 0, len(levels))
+	for _, level := range levels {
+		sorted = append(sorted, level)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return better(sorted[i].Price, sorted[j].Price)
+	})
+	return sorted
+}
+
+type topic struct {
+	channel string
+	symbol  string
+}
+
+// Subscription is one consumer of the feed, subscribed to any number of
+// channel and symbol pairs.
+type Subscription struct {
+	feed     *Feed
+	messages chan Message
+	topics   map[topic]bool
+}
+
+// Messages returns the channel updates are delivered on. It is closed when
+// the subscription is closed or dropped for falling behind.
+func (s *Subscription) Messages() <-chan Message {
+	return s.messages
+}
+
+// Add subscribes to channel for symbol. Book and orders subscriptions
+// immediately receive a snapshot of the current book.
+func (s *Subscription) Add(channel, symbol string) error {
+	if channel != ChannelBook && channel != ChannelTrades && channel != ChannelOrders {
+		return ErrUnknownChannel
+	}
+
+	s.feed.mu.Lock()
+	defer s.feed.mu.Unlock()
+
+	if _, active := s.feed.subscribers[s]; !active {
+		return nil
+	}
+
+	key := topic{channel: channel, symbol: symbol}
+	if s.topics[key] {
+		return nil
+	}
+	s.topics[key] = true
+
+	switch channel {
+	case ChannelBook:
+		s.feed.deliver(s, s.feed.book(symbol).snapshot(symbol))
+	case ChannelOrders:
+		s.feed.deliver(s, s.feed.orderBook(symbol).snapshot(symbol))
+	}
+	return nil
+}
+
+// Remove unsubscribes from channel for symbol.
+func (s *Subscription) Remove(channel, symbol string) {
+	s.feed.mu.Lock()
+	defer s.feed.mu.Unlock()
+
+	delete(s.topics, topic{channel: channel, symbol: symbol})
+}
+
+func (s *Subscription) Close() {
+	s.feed.mu.Lock()
+	defer s.feed.mu.Unlock()
+
+	s.feed.drop(s)
+}
@@ -0,0 +1,109 @@
+package marketdata
+
+import (
+	"fmt"
+	"order-matching-engine/models"
+)
+
+// L3Book rebuilds an exact order book, every resting order in queue
+// priority, from an order-by-order feed. Apply the orders of a snapshot
+// message in sequence, then each update after it.
+type L3Book struct {
+	Symbol string
+	bids   []models.OrderBookEvent
+	asks   []models.OrderBookEvent
+}
+
+func NewL3Book(symbol string) *L3Book {
+	return &L3Book{Symbol: symbol}
+}
+
+// Apply updates the book with one order-level event.
+func (b *L3Book) Apply(event models.OrderBookEvent) error {
+	switch event.Type {
+	case models.BookEventAdd:
+		if _, _, found := b.find(event.OrderID); found {
+			return fmt.Errorf("add for order %s already in book", event.OrderID)
+		}
+		b.insert(event)
+	case models.BookEventModify:
+		side, i, found := b.find(event.OrderID)
+		if !found {
+			return fmt.Errorf("modify for unknown order %s", event.OrderID)
+		}
+		current := (*side)[i]
+		// Keeping priority is only allowed for a size reduction at the same price
+		if event.Price == current.Price && event.Quantity <= current.Quantity {
+			(*side)[i].Quantity = event.Quantity
+			return nil
+		}
+		*side = append((*side)[:i], (*side)[i+1:]...)
+		b.insert(event)
+	case models.BookEventExecute:
+		side, i, found := b.find(event.OrderID)
+		if !found {
+			return fmt.Errorf("execute for unknown order %s", event.OrderID)
+		}
+		if event.Quantity == 0 {
+			*side = append((*side)[:i], (*side)[i+1:]...)
+		} else {
+			(*side)[i].Quantity = event.Quantity
+		}
+	case models.BookEventDelete:
+		side, i, found := b.find(event.OrderID)
+		if !found {
+			return fmt.Errorf("delete for unknown order %s", event.OrderID)
+		}
+		*side = append((*side)[:i], (*side)[i+1:]...)
+	default:
+		return fmt.Errorf("unknown event type %q", event.Type)
+	}
+	return nil
+}
+
+// Bids returns resting buy orders, best price first and FIFO within a price.
+func (b *L3Book) Bids() []models.OrderBookEvent {
+	return append([]models.OrderBookEvent(nil), b.bids...)
+}
+
+// Asks returns resting sell orders, best price first and FIFO within a price.
+func (b *L3Book) Asks() []models.OrderBookEvent {
+	return append([]models.OrderBookEvent(nil), b.asks...)
+}
+
+// Len returns the number of resting orders on both sides.
+func (b *L3Book) Len() int {
+	return len(b.bids) + len(b.asks)
+}
+
+// insert places an order at the back of its price level.
+func (b *L3Book) insert(event models.OrderBookEvent) {
+	side := &b.asks
+	behind := func(resting float64) bool { return resting <= event.Price }
+	if event.Side == "buy" {
+		side = &b.bids
+		behind = func(resting float64) bool { return resting >= event.Price }
+	}
+
+	i := 0
+	for i < len(*side) && behind((*side)[i].Price) {
+		i++
+	}
+	*side = append(*side, models.OrderBookEvent{})
+	copy((*side)[i+1:], (*side)[i:])
+	(*side)[i] = event
+}
+
+func (b *L3Book) find(orderID string) (*[]models.OrderBookEvent, int, bool) {
+	for i, order := range b.bids {
+		if order.OrderID == orderID {
+			return &b.bids, i, true
+		}
+	}
+	for i, order := range b.asks {
+		if order.OrderID == orderID {
+			return &b.asks, i, true
+		}
+	}
+	return nil, 0, false
+}
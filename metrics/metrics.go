@@ -0,0 +1,168 @@
+// Package metrics exposes engine, database and HTTP measurements in the
+// Prometheus text format on /metrics.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"order-matching-engine/models"
+	"order-matching-engine/services"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "matching"
+
+// ReasonInvalid counts orders refused by an order entry interface before
+// they reach the engine, for failing validation or naming an account they
+// may not use.
+const ReasonInvalid = "invalid"
+
+var (
+	ordersAccepted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_accepted_total",
+		Help:      "Orders accepted by the engine, by order type.",
+	}, []string{"type"})
+
+	ordersRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_rejected_total",
+		Help:      "Orders rejected, by reason.",
+	}, []string{"reason"})
+
+	trades = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "trades_total",
+		Help:      "Trades executed, by symbol.",
+	}, []string{"symbol"})
+
+	volume = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "trade_volume_total",
+		Help:      "Quantity traded, by symbol.",
+	}, []string{"symbol"})
+
+	processOrder = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "process_order_duration_seconds",
+		Help:      "Time ProcessOrder spends matching in memory and persisting the result, by phase.",
+		// 1µs to about 1s
+		Buckets: prometheus.ExponentialBuckets(1e-6, 4, 11),
+	}, []string{"phase"})
+)
+
+// OrderRejected counts an order refused with reason.
+func OrderRejected(reason string) {
+	ordersRejected.WithLabelValues(reason).Inc()
+}
+
+// Engine implements services.Metrics and services.Listener to count the
+// orders and trades the engine processes.
+type Engine struct{}
+
+func (Engine) OrderAccepted(order *models.Order) {
+	ordersAccepted.WithLabelValues(order.Type).Inc()
+}
+
+func (Engine) OrderRejected(order *models.Order, reason string) {
+	OrderRejected(reason)
+}
+
+func (Engine) ObserveProcessOrder(matching, persistence time.Duration) {
+	processOrder.WithLabelValues("matching").Observe(matching.Seconds())
+	processOrder.WithLabelValues("persistence").Observe(persistence.Seconds())
+}
+
+func (Engine) OnEvent(event services.Event) {
+	if event.Type != services.EventTrade {
+		return
+	}
+	trades.WithLabelValues(event.Symbol).Inc()
+	volume.WithLabelValues(event.Symbol).Add(float64(event.Trade.Quantity))
+}
+
+// RegisterEngine exports the resting orders of every book and the engine's
+// lock statistics, read at scrape time.
+func RegisterEngine(engine *services.MatchingEngine) {
+	prometheus.MustRegister(&bookCollector{engine: engine})
+}
+
+// RegisterDB exports the connection pool statistics of db.
+func RegisterDB(db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, "order_matching"))
+}
+
+// Handler serves every registered metric.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+var (
+	restingOrdersDesc = prometheus.NewDesc(namespace+"_resting_orders",
+		"Orders resting on a book, by symbol and side.", []string{"symbol", "side"}, nil)
+	restingQuantityDesc = prometheus.NewDesc(namespace+"_resting_quantity",
+		"Open quantity resting on a book, by symbol and side.", []string{"symbol", "side"}, nil)
+	haltedDesc = prometheus.NewDesc(namespace+"_symbol_halted",
+		"Whether trading in a symbol is halted.", []string{"symbol"}, nil)
+	maintenanceDesc = prometheus.NewDesc(namespace+"_maintenance",
+		"Whether the engine accepts only cancels.", nil, nil)
+	lockAcquisitionsDesc = prometheus.NewDesc(namespace+"_engine_lock_acquisitions_total",
+		"Times the engine write lock was taken.", nil, nil)
+	lockContendedDesc = prometheus.NewDesc(namespace+"_engine_lock_contended_total",
+		"Times the engine write lock was taken after waiting.", nil, nil)
+	lockWaitingDesc = prometheus.NewDesc(namespace+"_engine_lock_waiting",
+		"Operations queued for the engine write lock.", nil, nil)
+	lockWaitDesc = prometheus.NewDesc(namespace+"_engine_lock_wait_seconds_total",
+		"Time spent waiting for the engine write lock.", nil, nil)
+	lockHoldDesc = prometheus.NewDesc(namespace+"_engine_lock_hold_seconds_total",
+		"Time the engine write lock was held.", nil, nil)
+)
+
+// bookCollector reads book sizes and lock statistics from the engine when
+// scraped rather than tracking every book change.
+type bookCollector struct {
+	engine *services.MatchingEngine
+}
+
+func (c *bookCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- restingOrdersDesc
+	ch <- restingQuantityDesc
+	ch <- haltedDesc
+	ch <- maintenanceDesc
+	ch <- lockAcquisitionsDesc
+	ch <- lockContendedDesc
+	ch <- lockWaitingDesc
+	ch <- lockWaitDesc
+	ch <- lockHoldDesc
+}
+
+func (c *bookCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, status := range c.engine.SymbolStatuses() {
+		ch <- prometheus.MustNewConstMetric(restingOrdersDesc, prometheus.GaugeValue, float64(status.BidOrders), status.Symbol, "buy")
+		ch <- prometheus.MustNewConstMetric(restingOrdersDesc, prometheus.GaugeValue, float64(status.AskOrders), status.Symbol, "sell")
+		ch <- prometheus.MustNewConstMetric(restingQuantityDesc, prometheus.GaugeValue, float64(status.BidQuantity), status.Symbol, "buy")
+		ch <- prometheus.MustNewConstMetric(restingQuantityDesc, prometheus.GaugeValue, float64(status.AskQuantity), status.Symbol, "sell")
+		ch <- prometheus.MustNewConstMetric(haltedDesc, prometheus.GaugeValue, boolValue(status.Halted), status.Symbol)
+	}
+
+	status := c.engine.Status()
+	lock := status.Lock
+	ch <- prometheus.MustNewConstMetric(maintenanceDesc, prometheus.GaugeValue, boolValue(status.Maintenance))
+	ch <- prometheus.MustNewConstMetric(lockAcquisitionsDesc, prometheus.CounterValue, float64(lock.Acquisitions))
+	ch <- prometheus.MustNewConstMetric(lockContendedDesc, prometheus.CounterValue, float64(lock.Contended))
+	ch <- prometheus.MustNewConstMetric(lockWaitingDesc, prometheus.GaugeValue, float64(lock.Waiting))
+	ch <- prometheus.MustNewConstMetric(lockWaitDesc, prometheus.CounterValue, lock.TotalWaitMs/1000)
+	ch <- prometheus.MustNewConstMetric(lockHoldDesc, prometheus.CounterValue, lock.TotalHoldMs/1000)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,62 @@
+package models
+
+// SymbolStatus summarizes one order book for operators.
+type SymbolStatus struct {
+	Symbol      string `json:"symbol"`
+	Halted      bool   `json:"halted"`
+	BidOrders   int    `json:"bid_orders"`
+	AskOrders   int    `json:"ask_orders"`
+	BidQuantity int    `json:"bid_quantity"`
+	AskQuantity int    `json:"ask_quantity"`
+}
+
+// LockStats describes contention on the engine's write lock since startup.
+// Waiting is the number of operations queued for the lock right now.
+type LockStats struct {
+	Acquisitions int64   `json:"acquisitions"`
+	Contended    int64   `json:"contended"`
+	Waiting      int64   `json:"waiting"`
+	TotalWaitMs  float64 `json:"total_wait_ms"`
+	MaxWaitMs    float64 `json:"max_wait_ms"`
+	TotalHoldMs  float64 `json:"total_hold_ms"`
+	MaxHoldMs    float64 `json:"max_hold_ms"`
+}
+
+// EngineStatus is the engine's trading state and lock statistics.
+type EngineStatus struct {
+	Maintenance   bool      `json:"maintenance"`
+	JournalFailed bool      `json:"journal_failed"`
+	HaltedSymbols []string  `json:"halted_symbols"`
+	Symbols       int       `json:"symbols"`
+	RestingOrders int       `json:"resting_orders"`
+	Lock          LockStats `json:"lock"`
+}
+
+// AdminCancelRequest selects resting orders for an operator to cancel,
+// whatever account they belong to: the listed OrderIDs or, without any, the
+// orders matching the mass cancel filter. A request with neither cancels
+// nothing.
+type AdminCancelRequest struct {
+	OrderIDs []string `json:"order_ids,omitempty"`
+	MassCancelRequest
+}
+
+func (r *AdminCancelRequest) Empty() bool {
+	return len(r.OrderIDs) == 0 && r.MassCancelRequest == (MassCancelRequest{})
+}
+
+func (r *AdminCancelRequest) Matches(order *Order) bool {
+	if len(r.OrderIDs) == 0 {
+		return r.MassCancelRequest.Matches(order)
+	}
+	for _, id := range r.OrderIDs {
+		if id == order.ID {
+			return true
+		}
+	}
+	return false
+}
+
+type MaintenanceRequest struct {
+	Enabled bool `json:"enabled"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// API key scopes. Read keys may only use GET endpoints; trade keys may also
+// place, amend and cancel orders.
+const (
+	ScopeRead  = "read"
+	ScopeTrade = "trade"
+)
+
+// APIKey identifies a client and the account it acts for. Secret signs
+// requests and is only returned when the key is created.
+type APIKey struct {
+	ID        string     `json:"id" db:"id"`
+	Secret    string     `json:"secret,omitempty" db:"secret"`
+	Account   string     `json:"account" db:"account"`
+	Scope     string     `json:"scope" db:"scope"`
+	Label     string     `json:"label,omitempty" db:"label"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// Allows reports whether the key's scope covers requests with method.
+func (k *APIKey) Allows(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS":
+		return true
+	}
+	return k.CanTrade()
+}
+
+// CanTrade reports whether the key may place, amend and cancel orders.
+func (k *APIKey) CanTrade() bool {
+	return k.Scope == ScopeTrade
+}
+
+type CreateAPIKeyRequest struct {
+	Account string `json:"account"`
+	Scope   string `json:"scope"`
+	Label   string `json:"label,omitempty"`
+}
+
+func (req *CreateAPIKeyRequest) Validate() error {
+	if req.Account == "" {
+		return errors.New("account is required")
+	}
+	if len(req.Account) > 64 {
+		return errors.New("account too long (max 64 characters)")
+	}
+	if req.Scope != ScopeRead && req.Scope != ScopeTrade {
+		return errors.New("scope must be 'read' or 'trade'")
+	}
+	if len(req.Label) > 255 {
+		return errors.New("label too long (max 255 characters)")
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Candle is an OHLCV bar of the trades executed in [OpenTime, CloseTime).
+type Candle struct {
+	Symbol      string    `json:"symbol"`
+	Interval    string    `json:"interval"`
+	OpenTime    time.Time `json:"open_time"`
+	CloseTime   time.Time `json:"close_time"`
+	Open        float64   `json:"open"`
+	High        float64   `json:"high"`
+	Low         float64   `json:"low"`
+	Close       float64   `json:"close"`
+	Volume      int       `json:"volume"`
+	QuoteVolume float64   `json:"quote_volume"`
+	TradeCount  int       `json:"trade_count"`
+}
@@ -1,10 +1,14 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
 type Order struct {
 	ID                string    `json:"id" db:"id"`
 	Symbol            string    `json:"symbol" db:"symbol"`
+	Account           string    `json:"account,omitempty" db:"account"`
 	Side              string    `json:"side" db:"side"` // "buy" or "sell"
 	Type              string    `json:"type" db:"type"` // "limit" or "market"
 	Price             *float64  `json:"price,omitempty" db:"price"`
@@ -16,8 +20,151 @@ type Order struct {
 
 type PlaceOrderRequest struct {
 	Symbol   string   `json:"symbol"`
+	Account  string   `json:"account,omitempty"`
 	Side     string   `json:"side"`
 	Type     string   `json:"type"`
 	Price    *float64 `json:"price,omitempty"`
 	Quantity int      `json:"quantity"`
-}
\ No newline at end of file
+}
+
+// Validate checks a new order request. Every order entry gateway (REST, FIX,
+// gRPC) applies the same rules.
+func (req *PlaceOrderRequest) Validate() error {
+	// Validate required fields
+	if req.Symbol == "" {
+		return errors.New("symbol is required")
+	}
+	if len(req.Symbol) > 50 {
+		return errors.New("symbol too long (max 50 characters)")
+	}
+	if len(req.Account) > 64 {
+		return errors.New("account too long (max 64 characters)")
+	}
+	if req.Side == "" {
+		return errors.New("side is required")
+	}
+	if req.Type == "" {
+		return errors.New("type is required")
+	}
+	if req.Quantity <= 0 {
+		return errors.New("quantity must be positive")
+	}
+
+	// Validate side
+	if req.Side != "buy" && req.Side != "sell" {
+		return errors.New("side must be 'buy' or 'sell'")
+	}
+
+	// Validate type
+	if req.Type != "limit" && req.Type != "market" {
+		return errors.New("type must be 'limit' or 'market'")
+	}
+
+	// Validate price for limit orders
+	if req.Type == "limit" {
+		if req.Price == nil {
+			return errors.New("price required for limit orders")
+		}
+		if *req.Price <= 0 {
+			return errors.New("price must be positive")
+		}
+	}
+
+	// Market orders should not have price
+	if req.Type == "market" && req.Price != nil {
+		return errors.New("market orders should not have price")
+	}
+
+	return nil
+}
+
+// BatchPlaceRequest submits several orders at once. With AllOrNone every
+// order is validated, and its symbol checked for a halt or maintenance,
+// before any is placed; one failure rejects the whole batch. The orders are
+// not placed atomically: if the engine fails one after that, the batch
+// stops and the orders before it stay placed.
+type BatchPlaceRequest struct {
+	Orders    []PlaceOrderRequest `json:"orders"`
+	AllOrNone bool                `json:"all_or_none,omitempty"`
+}
+
+// BatchCancelRequest cancels several orders at once. With AllOrNone nothing
+// is cancelled unless every order can be. A non-empty Account only cancels
+// orders of that account; others are treated as not found.
+type BatchCancelRequest struct {
+	OrderIDs  []string `json:"order_ids"`
+	Account   string   `json:"account,omitempty"`
+	AllOrNone bool     `json:"all_or_none,omitempty"`
+}
+
+// Batch item statuses
+const (
+	BatchItemAccepted  = "accepted"
+	BatchItemCancelled = "cancelled"
+	BatchItemRejected  = "rejected"
+	// Not attempted because an all-or-none batch was abandoned
+	BatchItemSkipped = "skipped"
+)
+
+// BatchItemResult reports on one item of a batch, in request order.
+type BatchItemResult struct {
+	Index   int      `json:"index"`
+	Status  string   `json:"status"`
+	OrderID string   `json:"order_id,omitempty"`
+	Order   *Order   `json:"order,omitempty"`
+	Trades  []*Trade `json:"trades,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// MassCancelRequest selects resting orders to cancel. Empty fields match any
+// value; price bounds are inclusive.
+type MassCancelRequest struct {
+	Symbol   string   `json:"symbol,omitempty"`
+	Side     string   `json:"side,omitempty"`
+	Account  string   `json:"account,omitempty"`
+	MinPrice *float64 `json:"min_price,omitempty"`
+	MaxPrice *float64 `json:"max_price,omitempty"`
+}
+
+// Matches reports whether a resting order satisfies every filter set on the request.
+func (r *MassCancelRequest) Matches(order *Order) bool {
+	if r.Symbol != "" && order.Symbol != r.Symbol {
+		return false
+	}
+	if r.Side != "" && order.Side != r.Side {
+		return false
+	}
+	if r.Account != "" && order.Account != r.Account {
+		return false
+	}
+	if r.MinPrice != nil && (order.Price == nil || *order.Price < *r.MinPrice) {
+		return false
+	}
+	if r.MaxPrice != nil && (order.Price == nil || *order.Price > *r.MaxPrice) {
+		return false
+	}
+	return true
+}
+
+// OrderQuery filters and pages the order listing. Zero-valued fields are not
+// filtered on; Statuses matches any of the given statuses. Orders are sorted
+// by creation time, newest first unless Ascending is set.
+type OrderQuery struct {
+	Symbol    string
+	Side      string
+	Type      string
+	Account   string
+	Statuses  []string
+	From      time.Time
+	To        time.Time
+	Ascending bool
+	After     *Cursor
+	Limit     int
+}
+
+// OrderPage is one page of the order listing. NextCursor is empty on the
+// last page.
+type OrderPage struct {
+	Orders     []*Order `json:"orders"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Order event types, one per lifecycle transition
+const (
+	OrderEventAccepted    = "accepted"
+	OrderEventPartialFill = "partial_fill"
+	OrderEventFilled      = "filled"
+	OrderEventCancelled   = "cancelled"
+	OrderEventAmended     = "amended"
+	OrderEventExpired     = "expired"
+	OrderEventRejected    = "rejected"
+)
+
+// OrderEvent records one transition in an order's lifecycle. Fill events
+// carry the trade and the quantity it executed; RemainingQuantity is always
+// the order's remaining quantity after the transition. Price is the trade
+// price for fills and the limit price otherwise.
+type OrderEvent struct {
+	ID                int64     `json:"id" db:"id"`
+	OrderID           string    `json:"order_id" db:"order_id"`
+	Type              string    `json:"type" db:"type"`
+	Quantity          int       `json:"quantity" db:"quantity"`
+	RemainingQuantity int       `json:"remaining_quantity" db:"remaining_quantity"`
+	Price             *float64  `json:"price,omitempty" db:"price"`
+	TradeID           string    `json:"trade_id,omitempty" db:"trade_id"`
+	Reason            string    `json:"reason,omitempty" db:"reason"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+}
@@ -17,4 +17,38 @@ type OrderBookResponse struct {
 	Spread          *float64         `json:"spread,omitempty"`
 	TotalBidOrders  int              `json:"total_bid_orders"`
 	TotalAskOrders  int              `json:"total_ask_orders"`
-}
\ No newline at end of file
+}
+
+// PriceLevelUpdate is the aggregate state of one price level after a change.
+// Quantity and Orders are zero when the level has been emptied.
+type PriceLevelUpdate struct {
+	Side     string  `json:"side"`
+	Price    float64 `json:"price"`
+	Quantity int     `json:"quantity"`
+	Orders   int     `json:"orders"`
+}
+
+// Order book event types for the order-by-order (L3) feed
+const (
+	BookEventAdd     = "add"
+	BookEventModify  = "modify"
+	BookEventExecute = "execute"
+	BookEventDelete  = "delete"
+)
+
+// OrderBookEvent is an order-level change to a book. Quantity is the order's
+// remaining quantity after the change. Execute events also carry the executed
+// quantity and trade; an execute that leaves nothing remaining removes the
+// order from the book.
+type OrderBookEvent struct {
+	Sequence         uint64    `json:"seq"`
+	Type             string    `json:"type"`
+	Symbol           string    `json:"symbol"`
+	OrderID          string    `json:"order_id"`
+	Side             string    `json:"side"`
+	Price            float64   `json:"price"`
+	Quantity         int       `json:"quantity"`
+	ExecutedQuantity int       `json:"executed_quantity,omitempty"`
+	TradeID          string    `json:"trade_id,omitempty"`
+	Timestamp        time.Time `json:"timestamp"`
+}
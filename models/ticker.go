@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Ticker summarises a symbol's current market and its trading over the last
+// 24 hours. Price fields are nil when there is nothing to report, e.g. no
+// trades in the window or an empty side of the book.
+type Ticker struct {
+	Symbol             string     `json:"symbol"`
+	LastPrice          *float64   `json:"last_price"`
+	LastQuantity       int        `json:"last_quantity"`
+	LastTradeAt        *time.Time `json:"last_trade_at"`
+	BidPrice           *float64   `json:"bid_price"`
+	BidQuantity        int        `json:"bid_quantity"`
+	AskPrice           *float64   `json:"ask_price"`
+	AskQuantity        int        `json:"ask_quantity"`
+	Open               *float64   `json:"open_24h"`
+	High               *float64   `json:"high_24h"`
+	Low                *float64   `json:"low_24h"`
+	Volume             int        `json:"volume_24h"`
+	QuoteVolume        float64    `json:"quote_volume_24h"`
+	VWAP               *float64   `json:"vwap_24h"`
+	PriceChange        *float64   `json:"price_change_24h"`
+	PriceChangePercent *float64   `json:"price_change_percent_24h"`
+	TradeCount         int        `json:"trade_count_24h"`
+	Timestamp          time.Time  `json:"timestamp"`
+}
@@ -10,4 +10,23 @@ type Trade struct {
 	Price       float64   `json:"price" db:"price"`
 	Quantity    int       `json:"quantity" db:"quantity"`
 	ExecutedAt  time.Time `json:"executed_at" db:"executed_at"`
-}
\ No newline at end of file
+}
+
+// TradeQuery filters and pages the trade history. Zero-valued fields are
+// not filtered on. Trades are returned newest first.
+type TradeQuery struct {
+	Symbol  string
+	OrderID string // either side of the trade
+	Account string // owner of either side of the trade
+	From    time.Time
+	To      time.Time
+	After   *Cursor
+	Limit   int
+}
+
+// TradePage is one page of trade history. NextCursor is empty on the last
+// page.
+type TradePage struct {
+	Trades     []*Trade `json:"trades"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
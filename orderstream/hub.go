@@ -0,0 +1,174 @@
+package orderstream
+
+import (
+	"fmt"
+	"order-matching-engine/models"
+	"order-matching-engine/services"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	EventOrder = "order"
+	EventTrade = "trade"
+)
+
+// Event is an order status transition or trade, numbered so clients can
+// resume after a disconnect.
+type Event struct {
+	ID     string        `json:"-"`
+	Type   string        `json:"type"`
+	Symbol string        `json:"symbol"`
+	Order  *models.Order `json:"order,omitempty"`
+	Trade  *models.Trade `json:"trade,omitempty"`
+
+	seq      uint64
+	accounts []string
+}
+
+// Filter restricts a subscription to a symbol and/or account. Trades match
+// an account that owns either side.
+type Filter struct {
+	Symbol  string
+	Account string
+}
+
+func (f Filter) Matches(event *Event) bool {
+	if f.Symbol != "" && event.Symbol != f.Symbol {
+		return false
+	}
+	if f.Account == "" {
+		return true
+	}
+	for _, account := range event.accounts {
+		if account == f.Account {
+			return true
+		}
+	}
+	return false
+}
+
+// Hub fans engine order and trade events out to stream subscribers and
+// keeps the most recent ones so a reconnecting client can resume.
+//
+// Event IDs have the form "<epoch>-<seq>". The epoch changes every time the
+// process starts, so IDs from a previous run are recognised as unresumable.
+type Hub struct {
+	epoch       string
+	seq         uint64
+	history     []Event
+	historySize int
+	subscribers map[*Subscriber]struct{}
+	mu          sync.Mutex
+}
+
+func NewHub(historySize int) *Hub {
+	return &Hub{
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		historySize: historySize,
+		subscribers: make(map[*Subscriber]struct{}),
+	}
+}
+
+// OnEvent implements services.Listener.
+func (h *Hub) OnEvent(event services.Event) {
+	var streamEvent Event
+	switch event.Type {
+	case services.EventOrderStatus:
+		streamEvent = Event{Type: EventOrder, Symbol: event.Symbol, Order: event.Order, accounts: []string{event.Order.Account}}
+	case services.EventTrade:
+		streamEvent = Event{Type: EventTrade, Symbol: event.Symbol, Trade: event.Trade, accounts: []string{event.BuyAccount, event.SellAccount}}
+	default:
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	streamEvent.seq = h.seq
+	streamEvent.ID = fmt.Sprintf("%s-%d", h.epoch, h.seq)
+
+	h.history = append(h.history, streamEvent)
+	if len(h.history) > h.historySize {
+		h.history = h.history[len(h.history)-h.historySize:]
+	}
+
+	for sub := range h.subscribers {
+		if !sub.filter.Matches(&streamEvent) {
+			continue
+		}
+		select {
+		case sub.events <- streamEvent:
+		default:
+			h.drop(sub)
+		}
+	}
+}
+
+// Subscribe starts a live subscription. If lastEventID is set, the buffered
+// events after it are returned to be sent first; resumed is false when the
+// ID is unknown or too old, meaning the client may have missed events.
+func (h *Hub) Subscribe(filter Filter, lastEventID string, buffer int) (sub *Subscriber, backlog []Event, resumed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub = &Subscriber{hub: h, filter: filter, events: make(chan Event, buffer)}
+	h.subscribers[sub] = struct{}{}
+
+	if lastEventID == "" {
+		return sub, nil, true
+	}
+
+	lastSeq, ok := h.parseID(lastEventID)
+	// The oldest buffered event must directly follow the last one seen
+	if !ok || lastSeq > h.seq || (len(h.history) > 0 && h.history[0].seq > lastSeq+1) {
+		return sub, nil, false
+	}
+
+	for i := range h.history {
+		if h.history[i].seq > lastSeq && filter.Matches(&h.history[i]) {
+			backlog = append(backlog, h.history[i])
+		}
+	}
+	return sub, backlog, true
+}
+
+func (h *Hub) parseID(id string) (uint64, bool) {
+	epoch, seq, found := strings.Cut(id, "-")
+	if !found || epoch != h.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	return n, err == nil
+}
+
+// drop must be called with h.mu held.
+func (h *Hub) drop(sub *Subscriber) {
+	if _, exists := h.subscribers[sub]; !exists {
+		return
+	}
+	delete(h.subscribers, sub)
+	close(sub.events)
+}
+
+type Subscriber struct {
+	hub    *Hub
+	filter Filter
+	events chan Event
+}
+
+// Events returns the live event channel. It is closed when the subscriber
+// is closed or dropped for falling behind.
+func (s *Subscriber) Events() <-chan Event {
+	return s.events
+}
+
+func (s *Subscriber) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.drop(s)
+}
@@ -0,0 +1,725 @@
+package ouch
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"order-matching-engine/clock"
+	"order-matching-engine/idgen"
+	"order-matching-engine/metrics"
+	"order-matching-engine/models"
+	"order-matching-engine/services"
+	"order-matching-engine/utils"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	loginTimeout = 10 * time.Second
+	// The gateway sends a heartbeat after a second without other output and
+	// drops clients it has not heard from for idleTimeout
+	heartbeatInterval = time.Second
+	idleTimeout       = 15 * time.Second
+	writeTimeout      = 5 * time.Second
+
+	// Sequenced messages kept per user for replay
+	retainedMessages = 100000
+)
+
+type Config struct {
+	ListenAddr string
+	// Users maps every login username to its credentials
+	Users map[string]User
+}
+
+type User struct {
+	Password string
+	// Account the user's orders are placed for; may be empty
+	Account string
+}
+
+// ParseUsers reads a user list of the form "username:password[:account],...".
+func ParseUsers(spec string) (map[string]User, error) {
+	users := make(map[string]User)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid OUCH user %q", entry)
+		}
+		username, password := parts[0], parts[1]
+		if len(username) > UsernameLength || strings.Contains(username, " ") {
+			return nil, fmt.Errorf("OUCH username %q must be at most %d characters without spaces", username, UsernameLength)
+		}
+		if len(password) > PasswordLength || strings.Contains(password, " ") {
+			return nil, fmt.Errorf("password of OUCH user %q must be at most %d characters without spaces", username, PasswordLength)
+		}
+		if _, exists := users[username]; exists {
+			return nil, fmt.Errorf("duplicate OUCH user %q", username)
+		}
+		user := User{Password: password}
+		if len(parts) == 3 {
+			user.Account = parts[2]
+		}
+		users[username] = user
+	}
+	return users, nil
+}
+
+// userSession is everything the gateway sends a user during one session.
+// The latest sequenced messages are kept, so a client that reconnects can
+// replay them. Logging in from a sequence number acknowledges the messages
+// before it, which are dropped.
+type userSession struct {
+	name string
+	User
+	out [][]byte
+	// Sequence number of out[0]
+	first  uint64
+	notify chan struct{}
+	conn   net.Conn
+	tokens map[string]bool
+	live   map[string]*orderRecord
+}
+
+// orderRecord ties an engine order to the user and token that entered it.
+type orderRecord struct {
+	orderID   string
+	user      *userSession
+	token     string
+	side      string
+	orderType string
+	symbol    string
+	price     *float64
+	open      int
+	filled    int
+	accepted  bool
+
+	// A cancel or replace request waiting on the engine
+	pendingCancel  bool
+	pendingReplace *replaceRequest
+}
+
+type replaceRequest struct {
+	token    string
+	quantity int
+	price    float64
+}
+
+// Gateway accepts OUCH order entry sessions. It must be registered with the
+// engine as a listener so it sees the fills and cancels that happen to the
+// orders entered through it. Sessions last as long as the process and are
+// named after its start time.
+type Gateway struct {
+	cfg      Config
+	session  string
+	clock    clock.Clock
+	orderIDs idgen.Generator
+	engine   *services.MatchingEngine
+	// Most sequenced messages kept per user
+	retain int
+
+	mu     sync.Mutex
+	users  map[string]*userSession
+	orders map[string]*orderRecord
+}
+
+func NewGateway(cfg Config, clk clock.Clock, orderIDs idgen.Generator) (*Gateway, error) {
+	if len(cfg.Users) == 0 {
+		return nil, errors.New("at least one OUCH user must be configured")
+	}
+
+	g := &Gateway{
+		cfg:      cfg,
+		session:  clk.Now().UTC().Format("0102150405"),
+		clock:    clk,
+		orderIDs: orderIDs,
+		retain:   retainedMessages,
+		users:    make(map[string]*userSession),
+		orders:   make(map[string]*orderRecord),
+	}
+	for name, user := range cfg.Users {
+		g.users[name] = &userSession{
+			name:   name,
+			User:   user,
+			first:  1,
+			notify: make(chan struct{}, 1),
+			tokens: make(map[string]bool),
+			live:   make(map[string]*orderRecord),
+		}
+	}
+	return g, nil
+}
+
+// Session returns the name clients log in to.
+func (g *Gateway) Session() string {
+	return g.session
+}
+
+// ListenAndServe accepts connections on cfg.ListenAddr and routes orders to
+// engine. It only returns if the listener fails.
+func (g *Gateway) ListenAndServe(engine *services.MatchingEngine) error {
+	g.engine = engine
+
+	listener, err := net.Listen("tcp", g.cfg.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for OUCH connections: %w", err)
+	}
+	defer listener.Close()
+
+	names := make([]string, 0, len(g.users))
+	for name := range g.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	slog.Info("OUCH gateway listening", "session", g.session, "addr", listener.Addr().String(), "users", strings.Join(names, ","))
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return fmt.Errorf("failed to accept OUCH connection: %w", err)
+		}
+		go g.serveConn(conn)
+	}
+}
+
+// serveConn logs the client in, then replays and streams its sequenced
+// messages while handling its requests one at a time.
+func (g *Gateway) serveConn(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	conn.SetReadDeadline(time.Now().Add(loginTimeout))
+	packet, err := ReadPacket(r)
+	if err != nil || packet.Type != PacketLoginRequest {
+		slog.Warn("OUCH connection without login request", "remote_addr", conn.RemoteAddr().String())
+		return
+	}
+	login, err := DecodeLoginRequest(packet.Payload)
+	if err != nil {
+		slog.Warn("OUCH login request unreadable", "remote_addr", conn.RemoteAddr().String(), "error", err)
+		return
+	}
+
+	u, next, reason := g.login(conn, login)
+	if u == nil {
+		slog.Warn("OUCH login refused", "remote_addr", conn.RemoteAddr().String(), "user", login.Username, "reason", string(reason))
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		conn.Write((&Packet{Type: PacketLoginRejected, Payload: []byte{reason}}).Encode())
+		return
+	}
+	defer g.logout(u)
+	slog.Info("OUCH user logged in", "user", u.name, "remote_addr", conn.RemoteAddr().String(), "sequence", next)
+
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := conn.Write((&Packet{Type: PacketLoginAccepted, Payload: EncodeLoginAccepted(g.session, next)}).Encode()); err != nil {
+		return
+	}
+
+	done := make(chan struct{})
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		g.writeLoop(conn, u, next, done)
+	}()
+	defer func() {
+		close(done)
+		<-writerDone
+	}()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		packet, err := ReadPacket(r)
+		if err != nil {
+			slog.Info("OUCH user disconnected", "user", u.name, "error", err)
+			return
+		}
+		switch packet.Type {
+		case PacketUnsequenced:
+			req, err := DecodeRequest(packet.Payload)
+			if err != nil {
+				slog.Warn("OUCH request unreadable, disconnecting", "user", u.name, "error", err)
+				return
+			}
+			g.handleRequest(u, req)
+		case PacketClientHeartbeat, PacketDebug:
+		case PacketLogoutRequest:
+			slog.Info("OUCH user logged out", "user", u.name)
+			return
+		default:
+			slog.Warn("OUCH unexpected packet type, disconnecting", "user", u.name, "packet_type", string(packet.Type))
+			return
+		}
+	}
+}
+
+// login checks the credentials and requested position of a login request.
+// On success it returns the user, now bound to conn, and the sequence
+// number of the first message to send; otherwise the reject reason.
+func (g *Gateway) login(conn net.Conn, login *LoginRequest) (*userSession, uint64, byte) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	u := g.users[login.Username]
+	if u == nil || subtle.ConstantTimeCompare([]byte(login.Password), []byte(u.Password)) != 1 {
+		return nil, 0, LoginNotAuthorized
+	}
+	if login.Session != "" && login.Session != g.session {
+		return nil, 0, LoginSessionUnavailable
+	}
+	// Only one connection per user at a time
+	if u.conn != nil {
+		return nil, 0, LoginSessionUnavailable
+	}
+
+	next := u.next()
+	if login.Sequence != 0 {
+		// Messages before u.first have been dropped
+		if login.Sequence > next || login.Sequence < u.first {
+			return nil, 0, LoginSessionUnavailable
+		}
+		next = login.Sequence
+		u.trim(next)
+	}
+	u.conn = conn
+	return u, next, 0
+}
+
+func (g *Gateway) logout(u *userSession) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	u.conn = nil
+}
+
+// writeLoop sends u's sequenced messages from next onwards as they are
+// generated, with heartbeats while there are none, until done is closed or
+// a write fails.
+func (g *Gateway) writeLoop(conn net.Conn, u *userSession, next uint64, done chan struct{}) {
+	w := bufio.NewWriter(conn)
+	heartbeat := time.NewTimer(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		g.mu.Lock()
+		if next < u.first {
+			g.mu.Unlock()
+			slog.Warn("OUCH client fell too far behind, disconnecting", "user", u.name)
+			conn.Close()
+			return
+		}
+		pending := u.out[next-u.first:]
+		g.mu.Unlock()
+
+		if len(pending) > 0 {
+			for _, msg := range pending {
+				w.Write((&Packet{Type: PacketSequenced, Payload: msg}).Encode())
+			}
+			next += uint64(len(pending))
+		} else {
+			select {
+			case <-done:
+				return
+			case <-u.notify:
+				continue
+			case <-heartbeat.C:
+				w.Write((&Packet{Type: PacketServerHeartbeat}).Encode())
+			}
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if err := w.Flush(); err != nil {
+			slog.Warn("OUCH write failed", "user", u.name, "error", err)
+			conn.Close()
+			return
+		}
+		heartbeat.Reset(heartbeatInterval)
+	}
+}
+
+func (g *Gateway) handleRequest(u *userSession, req *Request) {
+	switch req.Type {
+	case MsgEnterOrder:
+		g.enterOrder(u, req)
+	case MsgReplaceOrder:
+		g.replaceOrder(u, req)
+	case MsgCancelOrder:
+		g.cancelOrder(u, req)
+	}
+}
+
+func (g *Gateway) enterOrder(u *userSession, req *Request) {
+	g.mu.Lock()
+	if req.Token == "" || u.tokens[req.Token] {
+		metrics.OrderRejected(metrics.ReasonInvalid)
+		slog.Warn("order rejected", "user", u.name, "token", req.Token, "reason", metrics.ReasonInvalid, "reject_code", string(RejectDuplicateToken))
+		g.reject(u, MsgRejected, req.Token, RejectDuplicateToken)
+		g.mu.Unlock()
+		return
+	}
+	// Tokens are used up even by rejected orders
+	u.tokens[req.Token] = true
+
+	order := &models.Order{
+		ID:                g.orderIDs.NewID(),
+		Symbol:            req.Symbol,
+		Account:           u.Account,
+		Side:              sides[req.Side],
+		Type:              "limit",
+		InitialQuantity:   int(req.Shares),
+		RemainingQuantity: int(req.Shares),
+		Status:            "open",
+	}
+	if req.Price == 0 {
+		order.Type = "market"
+	} else {
+		price := DecodePrice(req.Price)
+		order.Price = &price
+	}
+
+	// Validate with the same rules as POST /orders
+	reason := byte(0)
+	validation := models.PlaceOrderRequest{
+		Symbol:   order.Symbol,
+		Account:  order.Account,
+		Side:     order.Side,
+		Type:     order.Type,
+		Price:    order.Price,
+		Quantity: order.InitialQuantity,
+	}
+	switch {
+	case order.Side == "":
+		reason = RejectInvalidSide
+	case req.Shares == 0 || req.Shares > maxShares:
+		reason = RejectInvalidShares
+	case req.Price < 0:
+		reason = RejectInvalidPrice
+	case order.Symbol == "":
+		reason = RejectInvalidSymbol
+	case validation.Validate() != nil:
+		reason = RejectOther
+	}
+	if reason != 0 {
+		metrics.OrderRejected(metrics.ReasonInvalid)
+		slog.Warn("order rejected", "user", u.name, "token", req.Token, "symbol", order.Symbol,
+			"reason", metrics.ReasonInvalid, "reject_code", string(reason))
+		g.reject(u, MsgRejected, req.Token, reason)
+		g.mu.Unlock()
+		return
+	}
+
+	// Register the order before the engine sees it so the listener can
+	// report on it
+	rec := &orderRecord{
+		orderID:   order.ID,
+		user:      u,
+		token:     req.Token,
+		side:      order.Side,
+		orderType: order.Type,
+		symbol:    order.Symbol,
+		price:     order.Price,
+		open:      order.InitialQuantity,
+	}
+	u.live[rec.token] = rec
+	g.orders[rec.orderID] = rec
+	g.mu.Unlock()
+
+	if _, err := g.engine.ProcessOrder(context.Background(), order); err != nil {
+		slog.Warn("OUCH order failed", "user", u.name, "order_id", order.ID, "error", err)
+		g.mu.Lock()
+		g.finish(rec)
+		reason := byte(RejectEngineError)
+		if tradingClosed(err) {
+			reason = RejectHalted
+		}
+		g.reject(u, MsgRejected, rec.token, reason)
+		g.mu.Unlock()
+	}
+}
+
+func (g *Gateway) replaceOrder(u *userSession, req *Request) {
+	g.mu.Lock()
+	if req.ReplacementToken == "" || u.tokens[req.ReplacementToken] {
+		g.reject(u, MsgRejected, req.ReplacementToken, RejectDuplicateToken)
+		g.mu.Unlock()
+		return
+	}
+	u.tokens[req.ReplacementToken] = true
+
+	rec := u.live[req.Token]
+	reason := byte(0)
+	switch {
+	case rec == nil || rec.orderType != "limit":
+		reason = RejectUnknownOrder
+	case rec.pendingCancel || rec.pendingReplace != nil:
+		reason = RejectPending
+	case req.Shares == 0 || req.Shares > maxShares:
+		reason = RejectInvalidShares
+	case req.Price <= 0:
+		reason = RejectInvalidPrice
+	}
+	if reason != 0 {
+		g.reject(u, MsgRejected, req.ReplacementToken, reason)
+		g.mu.Unlock()
+		return
+	}
+	price := DecodePrice(req.Price)
+	rec.pendingReplace = &replaceRequest{token: req.ReplacementToken, quantity: rec.filled + int(req.Shares), price: price}
+	orderID, quantity := rec.orderID, rec.pendingReplace.quantity
+	g.mu.Unlock()
+
+	// The listener normally reports the replace from the engine's events,
+	// ahead of any fills it causes
+	_, _, err := g.engine.AmendOrder(orderID, quantity, &price)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err != nil {
+		rec.pendingReplace = nil
+		g.reject(u, MsgRejected, req.ReplacementToken, rejectReason(err))
+		return
+	}
+	if rec.pendingReplace != nil && g.orders[rec.orderID] != nil {
+		g.replaced(rec)
+	}
+}
+
+// cancelOrder cancels an order or, if the request leaves some shares open,
+// reduces it to that many open shares. Requests that would not reduce the
+// order are ignored.
+func (g *Gateway) cancelOrder(u *userSession, req *Request) {
+	g.mu.Lock()
+	rec := u.live[req.Token]
+	reason := byte(0)
+	switch {
+	case rec == nil:
+		reason = RejectUnknownOrder
+	case rec.pendingCancel || rec.pendingReplace != nil:
+		reason = RejectPending
+	}
+	if reason != 0 {
+		g.reject(u, MsgCancelReject, req.Token, reason)
+		g.mu.Unlock()
+		return
+	}
+	if int(req.Shares) >= rec.open {
+		g.mu.Unlock()
+		return
+	}
+	rec.pendingCancel = true
+	orderID, quantity := rec.orderID, rec.filled+int(req.Shares)
+	g.mu.Unlock()
+
+	// The listener reports the cancel when the engine commits it
+	var err error
+	if req.Shares == 0 {
+		err = g.engine.CancelOrder(orderID)
+	} else {
+		_, _, err = g.engine.AmendOrder(orderID, quantity, nil)
+	}
+	if err != nil {
+		g.mu.Lock()
+		rec.pendingCancel = false
+		g.reject(u, MsgCancelReject, req.Token, rejectReason(err))
+		g.mu.Unlock()
+	}
+}
+
+// OnEvent implements services.Listener. It turns engine events on orders
+// entered over OUCH into responses.
+func (g *Gateway) OnEvent(event services.Event) {
+	switch event.Type {
+	case services.EventTrade:
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		trade := event.Trade
+		for _, side := range []struct{ orderID, side string }{{trade.BuyOrderID, "buy"}, {trade.SellOrderID, "sell"}} {
+			rec := g.orders[side.orderID]
+			if rec == nil {
+				continue
+			}
+			if !rec.accepted {
+				g.accept(rec)
+			} else if rec.pendingReplace != nil && event.Aggressor == side.side {
+				// A resting order only takes liquidity when a replace
+				// requeues it at a crossing price
+				g.replaced(rec)
+			}
+
+			liquidity := byte(LiquidityAdded)
+			if event.Aggressor == side.side {
+				liquidity = LiquidityRemoved
+			}
+			rec.filled += trade.Quantity
+			rec.open -= trade.Quantity
+			g.send(rec.user, &Response{
+				Type:      MsgExecuted,
+				Token:     rec.token,
+				Shares:    uint32(trade.Quantity),
+				Price:     EncodePrice(trade.Price),
+				Liquidity: liquidity,
+				TradeID:   trade.ID,
+			})
+			if rec.open <= 0 {
+				g.finish(rec)
+			}
+		}
+	case services.EventOrderStatus:
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		order := event.Order
+		rec := g.orders[order.ID]
+		if rec == nil {
+			return
+		}
+		if !rec.accepted {
+			g.accept(rec)
+		}
+		if p := rec.pendingReplace; p != nil && order.Status != "cancelled" && order.InitialQuantity == p.quantity && order.Price != nil && *order.Price == p.price {
+			g.replaced(rec)
+		}
+
+		switch {
+		case order.Status == "cancelled":
+			reason := byte(CancelSupervisory)
+			if rec.pendingCancel {
+				reason = CancelUserRequested
+			} else if rec.orderType == "market" {
+				reason = CancelNoLiquidity
+			}
+			g.cancelled(rec, rec.open, reason)
+			g.finish(rec)
+		case rec.pendingCancel && order.RemainingQuantity < rec.open:
+			// Reduced by a partial cancel
+			g.cancelled(rec, rec.open-order.RemainingQuantity, CancelUserRequested)
+			rec.open = order.RemainingQuantity
+		}
+	}
+}
+
+// The helpers below must be called with g.mu held.
+
+func (g *Gateway) accept(rec *orderRecord) {
+	rec.accepted = true
+	g.send(rec.user, &Response{
+		Type:    MsgAccepted,
+		Token:   rec.token,
+		Side:    encodeSide(rec.side),
+		Shares:  uint32(rec.open),
+		Symbol:  rec.symbol,
+		Price:   priceOf(rec),
+		OrderID: rec.orderID,
+	})
+}
+
+// replaced applies the pending replace to rec and confirms it.
+func (g *Gateway) replaced(rec *orderRecord) {
+	p := rec.pendingReplace
+	previous := rec.token
+	rec.pendingReplace = nil
+	delete(rec.user.live, previous)
+	rec.token = p.token
+	rec.user.live[rec.token] = rec
+	rec.price = &p.price
+	rec.open = p.quantity - rec.filled
+
+	g.send(rec.user, &Response{
+		Type:          MsgReplaced,
+		Token:         rec.token,
+		Side:          encodeSide(rec.side),
+		Shares:        uint32(rec.open),
+		Symbol:        rec.symbol,
+		Price:         priceOf(rec),
+		PreviousToken: previous,
+	})
+}
+
+func (g *Gateway) cancelled(rec *orderRecord, shares int, reason byte) {
+	rec.pendingCancel = false
+	g.send(rec.user, &Response{Type: MsgCanceled, Token: rec.token, Shares: uint32(shares), Reason: reason})
+}
+
+// finish forgets an order that can no longer change. Its tokens stay used.
+func (g *Gateway) finish(rec *orderRecord) {
+	rec.open = 0
+	delete(rec.user.live, rec.token)
+	delete(g.orders, rec.orderID)
+}
+
+func (g *Gateway) reject(u *userSession, msgType byte, token string, reason byte) {
+	g.send(u, &Response{Type: msgType, Token: token, Reason: reason})
+}
+
+// send appends a sequenced message to u's session and wakes its writer, if
+// it is connected.
+func (g *Gateway) send(u *userSession, resp *Response) {
+	resp.Timestamp = uint64(g.clock.Now().UnixNano())
+	u.out = append(u.out, resp.Encode())
+	if len(u.out) > g.retain {
+		u.trim(u.next() - uint64(g.retain))
+	}
+	select {
+	case u.notify <- struct{}{}:
+	default:
+	}
+}
+
+// next is the sequence number of u's next message.
+func (u *userSession) next() uint64 {
+	return u.first + uint64(len(u.out))
+}
+
+// trim drops u's messages before seq. They are freed once append next
+// reallocates; they are not cleared, as a writer may still be sending them.
+func (u *userSession) trim(seq uint64) {
+	if seq <= u.first {
+		return
+	}
+	u.out = u.out[seq-u.first:]
+	u.first = seq
+}
+
+// Shares are sent as 32-bit integers; keep quantities well inside an int
+const maxShares = 1<<31 - 1
+
+var sides = map[byte]string{SideBuy: "buy", SideSell: "sell"}
+
+func encodeSide(side string) byte {
+	if side == "buy" {
+		return SideBuy
+	}
+	return SideSell
+}
+
+func priceOf(rec *orderRecord) int64 {
+	if rec.price == nil {
+		return 0
+	}
+	return EncodePrice(*rec.price)
+}
+
+func rejectReason(err error) byte {
+	switch {
+	case errors.Is(err, utils.ErrOrderNotFound):
+		return RejectUnknownOrder
+	case tradingClosed(err):
+		return RejectHalted
+	}
+	return RejectTooLate
+}
+
+// tradingClosed reports whether err means the symbol is halted or the engine
+// only accepts cancels.
+func tradingClosed(err error) bool {
+	return errors.Is(err, utils.ErrSymbolHalted) || errors.Is(err, utils.ErrMaintenanceMode)
+}
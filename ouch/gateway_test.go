@@ -0,0 +1,186 @@
+package ouch
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"order-matching-engine/clock"
+	"order-matching-engine/idgen"
+	"order-matching-engine/services"
+)
+
+func newTestGateway(t *testing.T) *Gateway {
+	t.Helper()
+	g, err := NewGateway(Config{Users: map[string]User{"alice": {Password: "secret", Account: "acct-1"}}},
+		clock.System{}, idgen.NewSequence("O", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.engine = services.NewMatchingEngine(services.WithStore(services.NewMemoryStore()), services.WithListener(g))
+	return g
+}
+
+// testClient is the client end of a connection served over net.Pipe.
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+}
+
+// dial connects to g and logs in from seq, returning the login reply.
+func dial(t *testing.T, g *Gateway, seq uint64) (*testClient, *Packet) {
+	t.Helper()
+	server, client := net.Pipe()
+	go g.serveConn(server)
+	t.Cleanup(func() { client.Close() })
+
+	c := &testClient{t: t, conn: client}
+	c.write(&Packet{Type: PacketLoginRequest, Payload: (&LoginRequest{Username: "alice", Password: "secret", Sequence: seq}).Encode()})
+	return c, c.read()
+}
+
+func (c *testClient) write(p *Packet) {
+	c.t.Helper()
+	c.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.conn.Write(p.Encode()); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+}
+
+// read returns the next packet other than a server heartbeat.
+func (c *testClient) read() *Packet {
+	c.t.Helper()
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		p, err := ReadPacket(c.conn)
+		if err != nil {
+			c.t.Fatalf("read: %v", err)
+		}
+		if p.Type != PacketServerHeartbeat {
+			return p
+		}
+	}
+}
+
+func (c *testClient) enter(token string, price float64) {
+	c.t.Helper()
+	req := &Request{Type: MsgEnterOrder, Token: token, Side: SideBuy, Shares: 10, Symbol: "AAPL", Price: EncodePrice(price)}
+	c.write(&Packet{Type: PacketUnsequenced, Payload: req.Encode()})
+}
+
+// expectAccepted reads sequenced Accepted messages for tokens, in order.
+func (c *testClient) expectAccepted(tokens ...string) {
+	c.t.Helper()
+	for _, token := range tokens {
+		p := c.read()
+		if p.Type != PacketSequenced {
+			c.t.Fatalf("packet type %c, want sequenced", p.Type)
+		}
+		resp, err := DecodeResponse(p.Payload)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		if resp.Type != MsgAccepted || resp.Token != token {
+			c.t.Fatalf("got %c for %q, want Accepted for %q", resp.Type, resp.Token, token)
+		}
+	}
+}
+
+// logout ends the session and waits for the gateway to release the user.
+func (c *testClient) logout() {
+	c.t.Helper()
+	c.write(&Packet{Type: PacketLogoutRequest})
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err := ReadPacket(c.conn); err != nil {
+			if !errors.Is(err, io.EOF) {
+				c.t.Fatalf("waiting for logout: %v", err)
+			}
+			return
+		}
+	}
+}
+
+func expectLogin(t *testing.T, reply *Packet, wantSeq uint64) {
+	t.Helper()
+	if reply.Type != PacketLoginAccepted {
+		t.Fatalf("login reply %c %q, want accepted", reply.Type, reply.Payload)
+	}
+	if _, seq, err := DecodeLoginAccepted(reply.Payload); err != nil || seq != wantSeq {
+		t.Fatalf("login accepted at sequence %d (%v), want %d", seq, err, wantSeq)
+	}
+}
+
+func expectLoginRejected(t *testing.T, reply *Packet, reason byte) {
+	t.Helper()
+	if reply.Type != PacketLoginRejected || len(reply.Payload) != 1 || reply.Payload[0] != reason {
+		t.Fatalf("login reply %c %q, want rejected with %c", reply.Type, reply.Payload, reason)
+	}
+}
+
+func TestLoginReplaysFromSequence(t *testing.T) {
+	g := newTestGateway(t)
+	c, reply := dial(t, g, 0)
+	expectLogin(t, reply, 1)
+	c.enter("t1", 100)
+	c.enter("t2", 101)
+	c.enter("t3", 102)
+	c.expectAccepted("t1", "t2", "t3")
+	c.logout()
+
+	c, reply = dial(t, g, 2)
+	expectLogin(t, reply, 2)
+	c.expectAccepted("t2", "t3")
+	c.enter("t4", 103)
+	c.expectAccepted("t4")
+	c.logout()
+
+	// Logging in from 2 acknowledged message 1, which is gone
+	_, reply = dial(t, g, 1)
+	expectLoginRejected(t, reply, LoginSessionUnavailable)
+	// Nothing has been sent past 4
+	_, reply = dial(t, g, 6)
+	expectLoginRejected(t, reply, LoginSessionUnavailable)
+
+	c, reply = dial(t, g, 0)
+	expectLogin(t, reply, 5)
+	c.logout()
+}
+
+func TestLoginRejectsWrongPassword(t *testing.T) {
+	g := newTestGateway(t)
+	server, client := net.Pipe()
+	go g.serveConn(server)
+	defer client.Close()
+	c := &testClient{t: t, conn: client}
+	c.write(&Packet{Type: PacketLoginRequest, Payload: (&LoginRequest{Username: "alice", Password: "wrong"}).Encode()})
+	expectLoginRejected(t, c.read(), LoginNotAuthorized)
+}
+
+func TestRetainedMessagesAreCapped(t *testing.T) {
+	g := newTestGateway(t)
+	g.retain = 2
+	c, reply := dial(t, g, 0)
+	expectLogin(t, reply, 1)
+	// One at a time, so the client never falls behind what is kept
+	for i, token := range []string{"t1", "t2", "t3"} {
+		c.enter(token, float64(100+i))
+		c.expectAccepted(token)
+	}
+	c.logout()
+
+	_, reply = dial(t, g, 1)
+	expectLoginRejected(t, reply, LoginSessionUnavailable)
+	c, reply = dial(t, g, 2)
+	expectLogin(t, reply, 2)
+	c.expectAccepted("t2", "t3")
+	c.logout()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if u := g.users["alice"]; len(u.out) != 2 || u.first != 2 {
+		t.Fatalf("%d messages kept from %d, want 2 from 2", len(u.out), u.first)
+	}
+}
@@ -0,0 +1,293 @@
+// Package ouch is a binary, OUCH-style order entry protocol: fixed-width,
+// big-endian messages to enter, replace and cancel orders, answered with
+// accepted, replaced, executed, cancelled and rejected messages. Messages are
+// carried over TCP in SoupBinTCP-style packets, which add login, heartbeats
+// and sequenced replies that a client can replay after reconnecting.
+package ouch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Request types, sent by the client
+const (
+	MsgEnterOrder   = 'O'
+	MsgReplaceOrder = 'U'
+	MsgCancelOrder  = 'X'
+)
+
+// Response types, sent by the gateway
+const (
+	MsgAccepted     = 'A'
+	MsgReplaced     = 'U'
+	MsgExecuted     = 'E'
+	MsgCanceled     = 'C'
+	MsgRejected     = 'J'
+	MsgCancelReject = 'I'
+)
+
+const (
+	SideBuy  = 'B'
+	SideSell = 'S'
+
+	// Prices are sent as integers in units of 1/PriceScale
+	PriceScale = 100_000_000
+
+	// Widths of the space-padded text fields
+	TokenLength  = 14
+	SymbolLength = 8
+	IDLength     = 36
+)
+
+// Liquidity flags on executions
+const (
+	LiquidityAdded   = 'A'
+	LiquidityRemoved = 'R'
+)
+
+// Reasons an order was cancelled
+const (
+	// The client asked for it
+	CancelUserRequested = 'U'
+	// A market order found no more liquidity
+	CancelNoLiquidity = 'I'
+	// The order was cancelled outside this session, e.g. over REST
+	CancelSupervisory = 'S'
+)
+
+// Reasons an order, replace or cancel was rejected
+const (
+	RejectDuplicateToken = 'D'
+	RejectInvalidSide    = 'S'
+	RejectInvalidShares  = 'Z'
+	RejectInvalidPrice   = 'X'
+	RejectInvalidSymbol  = 'Y'
+	RejectUnknownOrder   = 'U'
+	RejectPending        = 'P'
+	RejectTooLate        = 'T'
+	RejectHalted         = 'H'
+	RejectEngineError    = 'E'
+	RejectOther          = 'O'
+)
+
+// Message lengths by type, including the type byte
+var (
+	requestLengths = map[byte]int{
+		MsgEnterOrder:   1 + TokenLength + 1 + 4 + SymbolLength + 8,
+		MsgReplaceOrder: 1 + TokenLength + TokenLength + 4 + 8,
+		MsgCancelOrder:  1 + TokenLength + 4,
+	}
+	// Every response has a timestamp after its type
+	responseLengths = map[byte]int{
+		MsgAccepted:     1 + 8 + TokenLength + 1 + 4 + SymbolLength + 8 + IDLength,
+		MsgReplaced:     1 + 8 + TokenLength + 1 + 4 + SymbolLength + 8 + TokenLength,
+		MsgExecuted:     1 + 8 + TokenLength + 4 + 8 + 1 + IDLength,
+		MsgCanceled:     1 + 8 + TokenLength + 4 + 1,
+		MsgRejected:     1 + 8 + TokenLength + 1,
+		MsgCancelReject: 1 + 8 + TokenLength + 1,
+	}
+)
+
+var ErrInvalidMessage = errors.New("invalid OUCH message")
+
+// Request is a decoded client message. Which fields are set depends on Type:
+//
+//	O enter order:   Token, Side, Shares, Symbol, Price (0 for a market order)
+//	U replace order: Token (existing), ReplacementToken, Shares (open), Price
+//	X cancel order:  Token, Shares (to leave open, 0 cancels the order)
+type Request struct {
+	Type             byte
+	Token            string
+	ReplacementToken string
+	Side             byte
+	Shares           uint32
+	Symbol           string
+	Price            int64
+}
+
+// Response is a decoded gateway message. Which fields are set depends on
+// Type:
+//
+//	A accepted:      Token, Side, Shares, Symbol, Price, OrderID
+//	U replaced:      Token (replacement), Side, Shares (open), Symbol, Price, PreviousToken
+//	E executed:      Token, Shares (executed), Price, Liquidity, TradeID
+//	C canceled:      Token, Shares (cancelled), Reason
+//	J rejected:      Token, Reason
+//	I cancel reject: Token, Reason
+//
+// Timestamp is in Unix nanoseconds.
+type Response struct {
+	Type          byte
+	Timestamp     uint64
+	Token         string
+	PreviousToken string
+	Side          byte
+	Shares        uint32
+	Symbol        string
+	Price         int64
+	OrderID       string
+	TradeID       string
+	Liquidity     byte
+	Reason        byte
+}
+
+// Encode returns the fixed-width binary form of r.
+func (r *Request) Encode() []byte {
+	length, known := requestLengths[r.Type]
+	if !known {
+		panic(fmt.Sprintf("ouch: unknown request type %q", r.Type))
+	}
+	b := make([]byte, 0, length)
+	b = append(b, r.Type)
+	b = appendText(b, r.Token, TokenLength)
+
+	switch r.Type {
+	case MsgEnterOrder:
+		b = append(b, r.Side)
+		b = binary.BigEndian.AppendUint32(b, r.Shares)
+		b = appendText(b, r.Symbol, SymbolLength)
+		b = binary.BigEndian.AppendUint64(b, uint64(r.Price))
+	case MsgReplaceOrder:
+		b = appendText(b, r.ReplacementToken, TokenLength)
+		b = binary.BigEndian.AppendUint32(b, r.Shares)
+		b = binary.BigEndian.AppendUint64(b, uint64(r.Price))
+	case MsgCancelOrder:
+		b = binary.BigEndian.AppendUint32(b, r.Shares)
+	}
+	return b
+}
+
+// DecodeRequest parses one client message.
+func DecodeRequest(b []byte) (*Request, error) {
+	if err := checkLength(b, requestLengths); err != nil {
+		return nil, err
+	}
+
+	r := &Request{Type: b[0], Token: readText(b[1 : 1+TokenLength])}
+	body := b[1+TokenLength:]
+	switch r.Type {
+	case MsgEnterOrder:
+		r.Side = body[0]
+		r.Shares = binary.BigEndian.Uint32(body[1:])
+		r.Symbol = readText(body[5 : 5+SymbolLength])
+		r.Price = int64(binary.BigEndian.Uint64(body[5+SymbolLength:]))
+	case MsgReplaceOrder:
+		r.ReplacementToken = readText(body[:TokenLength])
+		r.Shares = binary.BigEndian.Uint32(body[TokenLength:])
+		r.Price = int64(binary.BigEndian.Uint64(body[TokenLength+4:]))
+	case MsgCancelOrder:
+		r.Shares = binary.BigEndian.Uint32(body)
+	}
+	return r, nil
+}
+
+// Encode returns the fixed-width binary form of r.
+func (r *Response) Encode() []byte {
+	length, known := responseLengths[r.Type]
+	if !known {
+		panic(fmt.Sprintf("ouch: unknown response type %q", r.Type))
+	}
+	b := make([]byte, 0, length)
+	b = append(b, r.Type)
+	b = binary.BigEndian.AppendUint64(b, r.Timestamp)
+	b = appendText(b, r.Token, TokenLength)
+
+	switch r.Type {
+	case MsgAccepted, MsgReplaced:
+		b = append(b, r.Side)
+		b = binary.BigEndian.AppendUint32(b, r.Shares)
+		b = appendText(b, r.Symbol, SymbolLength)
+		b = binary.BigEndian.AppendUint64(b, uint64(r.Price))
+		if r.Type == MsgAccepted {
+			b = appendText(b, r.OrderID, IDLength)
+		} else {
+			b = appendText(b, r.PreviousToken, TokenLength)
+		}
+	case MsgExecuted:
+		b = binary.BigEndian.AppendUint32(b, r.Shares)
+		b = binary.BigEndian.AppendUint64(b, uint64(r.Price))
+		b = append(b, r.Liquidity)
+		b = appendText(b, r.TradeID, IDLength)
+	case MsgCanceled:
+		b = binary.BigEndian.AppendUint32(b, r.Shares)
+		b = append(b, r.Reason)
+	case MsgRejected, MsgCancelReject:
+		b = append(b, r.Reason)
+	}
+	return b
+}
+
+// DecodeResponse parses one gateway message.
+func DecodeResponse(b []byte) (*Response, error) {
+	if err := checkLength(b, responseLengths); err != nil {
+		return nil, err
+	}
+
+	r := &Response{
+		Type:      b[0],
+		Timestamp: binary.BigEndian.Uint64(b[1:]),
+		Token:     readText(b[9 : 9+TokenLength]),
+	}
+	body := b[9+TokenLength:]
+	switch r.Type {
+	case MsgAccepted, MsgReplaced:
+		r.Side = body[0]
+		r.Shares = binary.BigEndian.Uint32(body[1:])
+		r.Symbol = readText(body[5 : 5+SymbolLength])
+		r.Price = int64(binary.BigEndian.Uint64(body[5+SymbolLength:]))
+		rest := body[13+SymbolLength:]
+		if r.Type == MsgAccepted {
+			r.OrderID = readText(rest)
+		} else {
+			r.PreviousToken = readText(rest)
+		}
+	case MsgExecuted:
+		r.Shares = binary.BigEndian.Uint32(body)
+		r.Price = int64(binary.BigEndian.Uint64(body[4:]))
+		r.Liquidity = body[12]
+		r.TradeID = readText(body[13:])
+	case MsgCanceled:
+		r.Shares = binary.BigEndian.Uint32(body)
+		r.Reason = body[4]
+	case MsgRejected, MsgCancelReject:
+		r.Reason = body[0]
+	}
+	return r, nil
+}
+
+// EncodePrice converts a price to fixed point.
+func EncodePrice(price float64) int64 {
+	return int64(math.Round(price * PriceScale))
+}
+
+// DecodePrice converts a fixed-point price back to a float.
+func DecodePrice(price int64) float64 {
+	return float64(price) / PriceScale
+}
+
+func checkLength(b []byte, lengths map[byte]int) error {
+	if len(b) == 0 {
+		return fmt.Errorf("%w: empty", ErrInvalidMessage)
+	}
+	length, known := lengths[b[0]]
+	if !known {
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidMessage, b[0])
+	}
+	if len(b) != length {
+		return fmt.Errorf("%w: type %q is %d bytes, got %d", ErrInvalidMessage, b[0], length, len(b))
+	}
+	return nil
+}
+
+// appendText appends s space padded or truncated to width.
+func appendText(b []byte, s string, width int) []byte {
+	return append(b, fmt.Sprintf("%-*.*s", width, width, s)...)
+}
+
+func readText(b []byte) string {
+	return strings.TrimRight(string(b), " ")
+}
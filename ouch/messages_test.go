@@ -0,0 +1,124 @@
+package ouch
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRequestRoundTrip(t *testing.T) {
+	for _, req := range []*Request{
+		{Type: MsgEnterOrder, Token: "t1", Side: SideBuy, Shares: 100, Symbol: "AAPL", Price: EncodePrice(150.25)},
+		{Type: MsgEnterOrder, Token: "market", Side: SideSell, Shares: 5, Symbol: "MSFT"},
+		{Type: MsgReplaceOrder, Token: "t1", ReplacementToken: "t2", Shares: 40, Price: EncodePrice(150.5)},
+		{Type: MsgCancelOrder, Token: "t2", Shares: 10},
+	} {
+		encoded := req.Encode()
+		if len(encoded) != requestLengths[req.Type] {
+			t.Fatalf("%c encodes to %d bytes, want %d", req.Type, len(encoded), requestLengths[req.Type])
+		}
+		decoded, err := DecodeRequest(encoded)
+		if err != nil {
+			t.Fatalf("DecodeRequest %c: %v", req.Type, err)
+		}
+		if !reflect.DeepEqual(decoded, req) {
+			t.Fatalf("decoded %+v, want %+v", decoded, req)
+		}
+	}
+}
+
+func TestEnterOrderLayout(t *testing.T) {
+	req := &Request{Type: MsgEnterOrder, Token: "tok", Side: SideBuy, Shares: 258, Symbol: "AAPL", Price: 1}
+	want := []byte("Otok           B\x00\x00\x01\x02AAPL    \x00\x00\x00\x00\x00\x00\x00\x01")
+	if got := req.Encode(); !bytes.Equal(got, want) {
+		t.Fatalf("Encode = %q, want %q", got, want)
+	}
+}
+
+func TestResponseRoundTrip(t *testing.T) {
+	for _, resp := range []*Response{
+		{Type: MsgAccepted, Timestamp: 1700000000000000000, Token: "t1", Side: SideBuy, Shares: 100, Symbol: "AAPL", Price: EncodePrice(150.25), OrderID: "O0000000000000001"},
+		{Type: MsgReplaced, Timestamp: 2, Token: "t2", PreviousToken: "t1", Side: SideSell, Shares: 40, Symbol: "AAPL", Price: EncodePrice(151)},
+		{Type: MsgExecuted, Timestamp: 3, Token: "t2", Shares: 10, Price: EncodePrice(151), Liquidity: LiquidityAdded, TradeID: "T0000000000000001"},
+		{Type: MsgCanceled, Timestamp: 4, Token: "t2", Shares: 30, Reason: CancelUserRequested},
+		{Type: MsgRejected, Timestamp: 5, Token: "t3", Reason: RejectDuplicateToken},
+		{Type: MsgCancelReject, Timestamp: 6, Token: "t4", Reason: RejectUnknownOrder},
+	} {
+		encoded := resp.Encode()
+		if len(encoded) != responseLengths[resp.Type] {
+			t.Fatalf("%c encodes to %d bytes, want %d", resp.Type, len(encoded), responseLengths[resp.Type])
+		}
+		decoded, err := DecodeResponse(encoded)
+		if err != nil {
+			t.Fatalf("DecodeResponse %c: %v", resp.Type, err)
+		}
+		if !reflect.DeepEqual(decoded, resp) {
+			t.Fatalf("decoded %+v, want %+v", decoded, resp)
+		}
+	}
+}
+
+func TestDecodeRejectsBadLengthsAndTypes(t *testing.T) {
+	valid := (&Request{Type: MsgCancelOrder, Token: "t1"}).Encode()
+	for name, b := range map[string][]byte{
+		"empty":        nil,
+		"unknown type": append([]byte{'Q'}, valid[1:]...),
+		"short":        valid[:len(valid)-1],
+		"long":         append(valid, 0),
+	} {
+		if _, err := DecodeRequest(b); !errors.Is(err, ErrInvalidMessage) {
+			t.Fatalf("%s: DecodeRequest error = %v, want ErrInvalidMessage", name, err)
+		}
+	}
+}
+
+func TestPriceConversion(t *testing.T) {
+	if got := EncodePrice(150.25); got != 15_025_000_000 {
+		t.Fatalf("EncodePrice(150.25) = %d", got)
+	}
+	// Rounded, not truncated
+	if got := EncodePrice(0.1 + 0.2); got != 30_000_000 {
+		t.Fatalf("EncodePrice(0.1+0.2) = %d", got)
+	}
+	if got := DecodePrice(15_025_000_000); got != 150.25 {
+		t.Fatalf("DecodePrice = %v", got)
+	}
+}
+
+func TestPacketAndLoginRoundTrip(t *testing.T) {
+	login := &LoginRequest{Username: "alice", Password: "secret", Session: "0101120000", Sequence: 42}
+	packet := &Packet{Type: PacketLoginRequest, Payload: login.Encode()}
+	encoded := packet.Encode()
+	if encoded[0] != 0 || int(encoded[1]) != 1+UsernameLength+PasswordLength+SessionLength+SequenceLength {
+		t.Fatalf("packet length prefix = %v", encoded[:2])
+	}
+
+	read, err := ReadPacket(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Type != PacketLoginRequest {
+		t.Fatalf("packet type = %c", read.Type)
+	}
+	decoded, err := DecodeLoginRequest(read.Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *decoded != *login {
+		t.Fatalf("decoded %+v, want %+v", decoded, login)
+	}
+
+	session, seq, err := DecodeLoginAccepted(EncodeLoginAccepted("0101120000", 7))
+	if err != nil || session != "0101120000" || seq != 7 {
+		t.Fatalf("DecodeLoginAccepted = %q, %d, %v", session, seq, err)
+	}
+}
+
+func TestReadPacketRejectsBadLength(t *testing.T) {
+	for _, header := range [][]byte{{0, 0}, {0xff, 0xff}} {
+		if _, err := ReadPacket(bytes.NewReader(header)); !errors.Is(err, ErrInvalidPacket) {
+			t.Fatalf("ReadPacket(%v) error = %v, want ErrInvalidPacket", header, err)
+		}
+	}
+}
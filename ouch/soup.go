@@ -0,0 +1,148 @@
+package ouch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Packet types sent by the client
+const (
+	PacketLoginRequest    = 'L'
+	PacketUnsequenced     = 'U'
+	PacketClientHeartbeat = 'R'
+	PacketLogoutRequest   = 'O'
+	PacketDebug           = '+'
+)
+
+// Packet types sent by the gateway
+const (
+	PacketLoginAccepted   = 'A'
+	PacketLoginRejected   = 'J'
+	PacketSequenced       = 'S'
+	PacketServerHeartbeat = 'H'
+)
+
+// Login reject reasons
+const (
+	LoginNotAuthorized      = 'A'
+	LoginSessionUnavailable = 'S'
+)
+
+const (
+	UsernameLength = 6
+	PasswordLength = 10
+	SessionLength  = 10
+	// Sequence numbers in login packets are right-aligned ASCII digits
+	SequenceLength = 20
+
+	// Longest packet payload accepted from a client
+	maxPayloadLength = 1024
+)
+
+var ErrInvalidPacket = errors.New("invalid SoupBinTCP packet")
+
+// Packet is a SoupBinTCP-style frame: a 2-byte big-endian length covering
+// the type and payload, the packet type and the payload. Order entry
+// messages travel as the payload of unsequenced (client) and sequenced
+// (gateway) packets.
+type Packet struct {
+	Type    byte
+	Payload []byte
+}
+
+func (p *Packet) Encode() []byte {
+	b := make([]byte, 0, 3+len(p.Payload))
+	b = binary.BigEndian.AppendUint16(b, uint16(1+len(p.Payload)))
+	b = append(b, p.Type)
+	return append(b, p.Payload...)
+}
+
+// ReadPacket reads one packet from a stream.
+func ReadPacket(r io.Reader) (*Packet, error) {
+	var header [3]byte
+	if _, err := io.ReadFull(r, header[:2]); err != nil {
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint16(header[:2]))
+	if length == 0 || length-1 > maxPayloadLength {
+		return nil, fmt.Errorf("%w: length %d", ErrInvalidPacket, length)
+	}
+	if _, err := io.ReadFull(r, header[2:]); err != nil {
+		return nil, err
+	}
+	p := &Packet{Type: header[2], Payload: make([]byte, length-1)}
+	if _, err := io.ReadFull(r, p.Payload); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// LoginRequest opens a session. A blank Session logs in to the current one
+// and a zero Sequence starts with the next message generated.
+type LoginRequest struct {
+	Username string
+	Password string
+	Session  string
+	Sequence uint64
+}
+
+func (l *LoginRequest) Encode() []byte {
+	b := make([]byte, 0, UsernameLength+PasswordLength+SessionLength+SequenceLength)
+	b = appendText(b, l.Username, UsernameLength)
+	b = appendText(b, l.Password, PasswordLength)
+	b = appendText(b, l.Session, SessionLength)
+	return appendSequence(b, l.Sequence)
+}
+
+func DecodeLoginRequest(b []byte) (*LoginRequest, error) {
+	if len(b) != UsernameLength+PasswordLength+SessionLength+SequenceLength {
+		return nil, fmt.Errorf("%w: login request is %d bytes", ErrInvalidPacket, len(b))
+	}
+	seq, err := readSequence(b[UsernameLength+PasswordLength+SessionLength:])
+	if err != nil {
+		return nil, err
+	}
+	return &LoginRequest{
+		Username: readText(b[:UsernameLength]),
+		Password: readText(b[UsernameLength : UsernameLength+PasswordLength]),
+		Session:  readText(b[UsernameLength+PasswordLength : UsernameLength+PasswordLength+SessionLength]),
+		Sequence: seq,
+	}, nil
+}
+
+// EncodeLoginAccepted builds the payload confirming a login: the session
+// and the sequence number of the next sequenced packet.
+func EncodeLoginAccepted(session string, sequence uint64) []byte {
+	return appendSequence(appendText(nil, session, SessionLength), sequence)
+}
+
+func DecodeLoginAccepted(b []byte) (string, uint64, error) {
+	if len(b) != SessionLength+SequenceLength {
+		return "", 0, fmt.Errorf("%w: login accepted is %d bytes", ErrInvalidPacket, len(b))
+	}
+	seq, err := readSequence(b[SessionLength:])
+	if err != nil {
+		return "", 0, err
+	}
+	return readText(b[:SessionLength]), seq, nil
+}
+
+func appendSequence(b []byte, seq uint64) []byte {
+	return append(b, fmt.Sprintf("%*d", SequenceLength, seq)...)
+}
+
+func readSequence(b []byte) (uint64, error) {
+	text := strings.TrimSpace(string(b))
+	if text == "" {
+		return 0, nil
+	}
+	seq, err := strconv.ParseUint(text, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: sequence number %q", ErrInvalidPacket, text)
+	}
+	return seq, nil
+}
@@ -0,0 +1,120 @@
+// Package ratelimit throttles REST and gRPC API clients with token buckets,
+// per client IP and per account, with separate limits for order entry and
+// market data requests.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit lets through Rate requests per second on average and bursts of up
+// to Burst requests. The zero Limit is unlimited.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l Limit) unlimited() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+// ParseLimit reads a limit written as "rate/burst", e.g. "10/20".
+func ParseLimit(spec string) (Limit, error) {
+	rate, burst, found := strings.Cut(spec, "/")
+	if !found {
+		return Limit{}, fmt.Errorf("invalid rate limit %q (want rate/burst)", spec)
+	}
+	var l Limit
+	var err error
+	if l.Rate, err = strconv.ParseFloat(rate, 64); err != nil || l.Rate <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate in rate limit %q", spec)
+	}
+	if l.Burst, err = strconv.Atoi(burst); err != nil || l.Burst <= 0 {
+		return Limit{}, fmt.Errorf("invalid burst in rate limit %q", spec)
+	}
+	return l, nil
+}
+
+// Result describes a bucket after a request was counted against it.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Until the bucket is full again
+	Reset time.Duration
+	// Until the next request would be allowed, when this one was not
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+// bucketSet holds the buckets of every client. Buckets that have refilled
+// are dropped, since a new bucket starts full anyway.
+type bucketSet struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// How often full buckets are dropped
+const sweepInterval = time.Minute
+
+func newBucketSet() *bucketSet {
+	return &bucketSet{buckets: make(map[string]*bucket)}
+}
+
+// take counts one request against the bucket for key.
+func (s *bucketSet) take(key string, limit Limit, now time.Time) Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > sweepInterval {
+		for k, b := range s.buckets {
+			if b.refill(now) >= float64(b.limit.Burst) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	b := s.buckets[key]
+	if b == nil || b.limit != limit {
+		b = &bucket{limit: limit, tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+	b.refill(now)
+
+	result := Result{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+	return result
+}
+
+// refill adds the tokens earned since the bucket was last used and returns
+// the new total.
+func (b *bucket) refill(now time.Time) float64 {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed.Seconds()*b.limit.Rate)
+		b.last = now
+	}
+	return b.tokens
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
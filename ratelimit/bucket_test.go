@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	l, err := ParseLimit("2.5/10")
+	if err != nil || l != (Limit{Rate: 2.5, Burst: 10}) {
+		t.Fatalf("ParseLimit = %+v, %v", l, err)
+	}
+	for _, spec := range []string{"", "10", "10/", "/10", "x/10", "10/x", "0/10", "10/0", "-1/10", "10/2.5"} {
+		if _, err := ParseLimit(spec); err == nil {
+			t.Fatalf("ParseLimit(%q) accepted", spec)
+		}
+	}
+}
+
+func TestBucketBurstAndRefill(t *testing.T) {
+	s := newBucketSet()
+	limit := Limit{Rate: 2, Burst: 3}
+	now := time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC)
+
+	// A new bucket starts full
+	for want := 2; want >= 0; want-- {
+		r := s.take("k", limit, now)
+		if !r.Allowed || r.Remaining != want || r.Limit != 3 {
+			t.Fatalf("burst request got %+v, want allowed with %d remaining", r, want)
+		}
+	}
+	r := s.take("k", limit, now)
+	if r.Allowed || r.RetryAfter != 500*time.Millisecond || r.Reset != 1500*time.Millisecond {
+		t.Fatalf("request past the burst got %+v, want refused, retry in 500ms, full in 1.5s", r)
+	}
+
+	// Two tokens a second: one after 500ms
+	now = now.Add(499 * time.Millisecond)
+	if r := s.take("k", limit, now); r.Allowed {
+		t.Fatalf("request after 499ms allowed: %+v", r)
+	}
+	now = now.Add(time.Millisecond)
+	if r := s.take("k", limit, now); !r.Allowed || r.Remaining != 0 {
+		t.Fatalf("request after 500ms got %+v", r)
+	}
+
+	// Refilling stops at the burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if r := s.take("k", limit, now); !r.Allowed {
+			t.Fatalf("request %d after an hour refused", i+1)
+		}
+	}
+	if r := s.take("k", limit, now); r.Allowed {
+		t.Fatal("more than the burst allowed after an hour")
+	}
+
+	// Buckets are per key
+	if r := s.take("other", limit, now); !r.Allowed || r.Remaining != 2 {
+		t.Fatalf("other key got %+v", r)
+	}
+}
+
+func TestBucketStartsAfreshWhenLimitChanges(t *testing.T) {
+	s := newBucketSet()
+	now := time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC)
+	s.take("k", Limit{Rate: 1, Burst: 1}, now)
+	if r := s.take("k", Limit{Rate: 1, Burst: 5}, now); !r.Allowed || r.Remaining != 4 {
+		t.Fatalf("request under the new limit got %+v", r)
+	}
+}
+
+func TestFullBucketsAreSwept(t *testing.T) {
+	s := newBucketSet()
+	limit := Limit{Rate: 1, Burst: 2}
+	now := time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC)
+	s.take("idle", limit, now)
+
+	s.take("busy", limit, now.Add(sweepInterval+time.Second))
+	if _, found := s.buckets["idle"]; found {
+		t.Fatal("refilled bucket was kept")
+	}
+	if _, found := s.buckets["busy"]; !found {
+		t.Fatal("bucket in use was dropped")
+	}
+}
@@ -0,0 +1,235 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"order-matching-engine/auth"
+	"order-matching-engine/clock"
+	"order-matching-engine/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Class groups endpoints that share a limit.
+type Class string
+
+const (
+	// Requests that change orders: anything but GET, HEAD and OPTIONS
+	ClassOrderEntry Class = "order_entry"
+	// Requests that only read: books, trades, orders, streams
+	ClassMarketData Class = "market_data"
+)
+
+// Limits holds a limit per endpoint class. A class without one is not
+// limited.
+type Limits map[Class]Limit
+
+// ParseLimits reads limits written as "class=rate/burst,...", e.g.
+// "order_entry=10/20,market_data=50/100".
+func ParseLimits(spec string) (Limits, error) {
+	limits := make(Limits)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, found := strings.Cut(entry, "=")
+		class := Class(name)
+		if !found || (class != ClassOrderEntry && class != ClassMarketData) {
+			return nil, fmt.Errorf("invalid rate limit %q (want order_entry=rate/burst or market_data=rate/burst)", entry)
+		}
+		limit, err := ParseLimit(value)
+		if err != nil {
+			return nil, err
+		}
+		limits[class] = limit
+	}
+	return limits, nil
+}
+
+// ParseTiers reads account tiers written as "tier:limits;...", e.g.
+// "standard:order_entry=10/20;premium:order_entry=100/200,market_data=500/1000".
+func ParseTiers(spec string) (map[string]Limits, error) {
+	tiers := make(map[string]Limits)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, limits, _ := strings.Cut(entry, ":")
+		if name == "" {
+			return nil, fmt.Errorf("invalid rate limit tier %q", entry)
+		}
+		if _, exists := tiers[name]; exists {
+			return nil, fmt.Errorf("duplicate rate limit tier %q", name)
+		}
+		parsed, err := ParseLimits(limits)
+		if err != nil {
+			return nil, fmt.Errorf("tier %s: %w", name, err)
+		}
+		tiers[name] = parsed
+	}
+	return tiers, nil
+}
+
+// ParseAccountTiers reads "account=tier,..." assignments.
+func ParseAccountTiers(spec string) (map[string]string, error) {
+	accounts := make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		account, tier, found := strings.Cut(entry, "=")
+		if !found || account == "" || tier == "" {
+			return nil, fmt.Errorf("invalid account tier %q (want account=tier)", entry)
+		}
+		accounts[account] = tier
+	}
+	return accounts, nil
+}
+
+type Config struct {
+	// Limits of each account tier
+	Tiers map[string]Limits
+	// Tier of each account; accounts not listed are in DefaultTier
+	AccountTiers map[string]string
+	DefaultTier  string
+	// Limits for each client IP, whoever it authenticates as
+	PerIP Limits
+	// Take the client IP from the last X-Forwarded-For entry, for servers
+	// behind a reverse proxy
+	TrustProxy bool
+}
+
+// Limiter enforces Config with one token bucket per client and endpoint
+// class. Rejected requests get 429 Too Many Requests; every limited
+// response carries X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset headers for the most specific limit applied.
+type Limiter struct {
+	cfg     Config
+	clock   clock.Clock
+	buckets *bucketSet
+}
+
+func New(cfg Config, clk clock.Clock) (*Limiter, error) {
+	if _, found := cfg.Tiers[cfg.DefaultTier]; !found {
+		return nil, fmt.Errorf("default rate limit tier %q is not defined", cfg.DefaultTier)
+	}
+	for account, tier := range cfg.AccountTiers {
+		if _, found := cfg.Tiers[tier]; !found {
+			return nil, fmt.Errorf("account %s is in undefined rate limit tier %q", account, tier)
+		}
+	}
+	return &Limiter{cfg: cfg, clock: clk, buckets: newBucketSet()}, nil
+}
+
+// PerIP limits requests by client IP. It belongs before authentication so
+// unauthenticated floods are throttled too.
+func (l *Limiter) PerIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, err := l.clientIP(r)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if l.allow(w, l.AllowIP(ip, Classify(r))) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// PerAccount limits requests by the account of the API key they were signed
+// with, according to its tier. It belongs after authentication; requests
+// without a key pass through.
+func (l *Limiter) PerAccount(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := auth.KeyFromContext(r.Context())
+		if key == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if l.allow(w, l.AllowAccount(key.Account, Classify(r))) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// Classify returns the endpoint class of a request.
+func Classify(r *http.Request) Class {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return ClassMarketData
+	}
+	return ClassOrderEntry
+}
+
+// AllowIP counts a request of class from ip against the per IP limits.
+// Requests of a class without a limit are allowed and have a zero Limit.
+func (l *Limiter) AllowIP(ip string, class Class) Result {
+	return l.take("ip:"+ip, l.cfg.PerIP, class)
+}
+
+// AllowAccount counts a request of class from account against the limits
+// of its tier.
+func (l *Limiter) AllowAccount(account string, class Class) Result {
+	tier, found := l.cfg.AccountTiers[account]
+	if !found {
+		tier = l.cfg.DefaultTier
+	}
+	return l.take("account:"+account, l.cfg.Tiers[tier], class)
+}
+
+func (l *Limiter) take(client string, limits Limits, class Class) Result {
+	limit, limited := limits[class]
+	if !limited || limit.unlimited() {
+		return Result{Allowed: true}
+	}
+	return l.buckets.take(client+":"+string(class), limit, l.clock.Now())
+}
+
+// allow sets the rate limit headers of a counted request and answers 429 if
+// it is over the limit.
+func (l *Limiter) allow(w http.ResponseWriter, result Result) bool {
+	if result.Limit == 0 {
+		return true
+	}
+	h := w.Header()
+	h.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	if result.Allowed {
+		return true
+	}
+	h.Set("Retry-After", strconv.Itoa(RetryAfterSeconds(result)))
+	utils.WriteError(w, http.StatusTooManyRequests, "Rate limit exceeded")
+	return false
+}
+
+// RetryAfterSeconds returns when a refused request may be retried, in whole
+// seconds.
+func RetryAfterSeconds(result Result) int {
+	return max(1, ceilSeconds(result.RetryAfter))
+}
+
+func (l *Limiter) clientIP(r *http.Request) (string, error) {
+	if l.cfg.TrustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			entries := strings.Split(forwarded, ",")
+			return strings.TrimSpace(entries[len(entries)-1]), nil
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "", errors.New("unknown client address")
+	}
+	return host, nil
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,185 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"order-matching-engine/auth"
+	"order-matching-engine/clock"
+	"order-matching-engine/models"
+)
+
+func TestParseLimitsAndTiers(t *testing.T) {
+	limits, err := ParseLimits(" order_entry=10/20 , market_data=50/100,")
+	want := Limits{ClassOrderEntry: {Rate: 10, Burst: 20}, ClassMarketData: {Rate: 50, Burst: 100}}
+	if err != nil || !reflect.DeepEqual(limits, want) {
+		t.Fatalf("ParseLimits = %+v, %v", limits, err)
+	}
+	for _, spec := range []string{"orders=10/20", "order_entry", "order_entry=10", "market_data=0/1"} {
+		if _, err := ParseLimits(spec); err == nil {
+			t.Fatalf("ParseLimits(%q) accepted", spec)
+		}
+	}
+
+	tiers, err := ParseTiers("standard:order_entry=10/20;premium:order_entry=100/200,market_data=500/1000;free:")
+	wantTiers := map[string]Limits{
+		"standard": {ClassOrderEntry: {Rate: 10, Burst: 20}},
+		"premium":  {ClassOrderEntry: {Rate: 100, Burst: 200}, ClassMarketData: {Rate: 500, Burst: 1000}},
+		"free":     {},
+	}
+	if err != nil || !reflect.DeepEqual(tiers, wantTiers) {
+		t.Fatalf("ParseTiers = %+v, %v", tiers, err)
+	}
+	for _, spec := range []string{":order_entry=1/1", "a:order_entry=1/1;a:market_data=1/1", "a:order_entry=x/1"} {
+		if _, err := ParseTiers(spec); err == nil {
+			t.Fatalf("ParseTiers(%q) accepted", spec)
+		}
+	}
+
+	accounts, err := ParseAccountTiers("acct-1=premium, acct-2=standard")
+	if err != nil || !reflect.DeepEqual(accounts, map[string]string{"acct-1": "premium", "acct-2": "standard"}) {
+		t.Fatalf("ParseAccountTiers = %+v, %v", accounts, err)
+	}
+	for _, spec := range []string{"acct-1", "=premium", "acct-1="} {
+		if _, err := ParseAccountTiers(spec); err == nil {
+			t.Fatalf("ParseAccountTiers(%q) accepted", spec)
+		}
+	}
+}
+
+func TestNewRefusesUndefinedTiers(t *testing.T) {
+	tiers := map[string]Limits{"standard": {}}
+	if _, err := New(Config{Tiers: tiers, DefaultTier: "gold"}, clock.System{}); err == nil {
+		t.Fatal("undefined default tier accepted")
+	}
+	if _, err := New(Config{Tiers: tiers, DefaultTier: "standard", AccountTiers: map[string]string{"acct-1": "gold"}}, clock.System{}); err == nil {
+		t.Fatal("account in undefined tier accepted")
+	}
+}
+
+func newTestLimiter(t *testing.T, cfg Config) *Limiter {
+	t.Helper()
+	l, err := New(cfg, clock.NewFake(time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC), 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestAccountTiers(t *testing.T) {
+	l := newTestLimiter(t, Config{
+		Tiers: map[string]Limits{
+			"standard": {ClassOrderEntry: {Rate: 1, Burst: 1}},
+			"premium":  {ClassOrderEntry: {Rate: 1, Burst: 3}},
+		},
+		AccountTiers: map[string]string{"acct-p": "premium"},
+		DefaultTier:  "standard",
+	})
+
+	allowed := func(account string, class Class) int {
+		n := 0
+		for l.AllowAccount(account, class).Allowed && n < 10 {
+			n++
+		}
+		return n
+	}
+	if n := allowed("acct-s", ClassOrderEntry); n != 1 {
+		t.Fatalf("default tier account allowed %d orders, want 1", n)
+	}
+	if n := allowed("acct-p", ClassOrderEntry); n != 3 {
+		t.Fatalf("premium account allowed %d orders, want 3", n)
+	}
+	// No market data limit in either tier
+	if r := l.AllowAccount("acct-s", ClassMarketData); !r.Allowed || r.Limit != 0 {
+		t.Fatalf("unlimited class got %+v", r)
+	}
+}
+
+func TestPerIP(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		trustProxy bool
+		remoteAddr string
+		forwarded  string
+		wantIP     string
+	}{
+		{"direct", false, "192.0.2.1:5000", "", "192.0.2.1"},
+		{"forwarded header ignored", false, "192.0.2.1:5000", "198.51.100.7", "192.0.2.1"},
+		{"behind proxy", true, "10.0.0.1:5000", "203.0.113.9, 198.51.100.7", "198.51.100.7"},
+		{"proxy without header", true, "10.0.0.1:5000", "", "10.0.0.1"},
+		{"ipv6", false, "[2001:db8::1]:5000", "", "2001:db8::1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := newTestLimiter(t, Config{
+				Tiers:       map[string]Limits{"standard": {}},
+				DefaultTier: "standard",
+				PerIP:       Limits{ClassOrderEntry: {Rate: 1, Burst: 1}},
+				TrustProxy:  tc.trustProxy,
+			})
+			handler := l.PerIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			serve := func() *httptest.ResponseRecorder {
+				r := httptest.NewRequest("POST", "/orders", nil)
+				r.RemoteAddr = tc.remoteAddr
+				if tc.forwarded != "" {
+					r.Header.Set("X-Forwarded-For", tc.forwarded)
+				}
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+				return w
+			}
+
+			if w := serve(); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "1" {
+				t.Fatalf("first request got %d with headers %v", w.Code, w.Header())
+			}
+			w := serve()
+			if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+				t.Fatalf("second request got %d with headers %v, want 429", w.Code, w.Header())
+			}
+			// The request was counted against the client's IP and nothing else
+			if r := l.AllowIP(tc.wantIP, ClassOrderEntry); r.Allowed {
+				t.Fatalf("bucket of %s not used", tc.wantIP)
+			}
+			if r := l.AllowIP("192.0.2.99", ClassOrderEntry); !r.Allowed {
+				t.Fatal("bucket of another IP used")
+			}
+		})
+	}
+}
+
+func TestPerAccountUsesKeyAccount(t *testing.T) {
+	l := newTestLimiter(t, Config{
+		Tiers:       map[string]Limits{"standard": {ClassMarketData: {Rate: 1, Burst: 1}}},
+		DefaultTier: "standard",
+	})
+	handler := l.PerAccount(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(key *models.APIKey) int {
+		r := httptest.NewRequest("GET", "/orderbook/AAPL", nil)
+		if key != nil {
+			r = r.WithContext(auth.WithKey(r.Context(), key))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	key := &models.APIKey{ID: "k1", Account: "acct-1"}
+	if code := serve(key); code != http.StatusOK {
+		t.Fatalf("first request got %d", code)
+	}
+	// Another key of the same account shares its bucket
+	if code := serve(&models.APIKey{ID: "k2", Account: "acct-1"}); code != http.StatusTooManyRequests {
+		t.Fatalf("second request for the account got %d, want 429", code)
+	}
+	if code := serve(&models.APIKey{ID: "k3", Account: "acct-2"}); code != http.StatusOK {
+		t.Fatalf("other account got %d", code)
+	}
+	// Unauthenticated requests are left to the per IP limits
+	for i := 0; i < 3; i++ {
+		if code := serve(nil); code != http.StatusOK {
+			t.Fatalf("request without a key got %d", code)
+		}
+	}
+}
@@ -2,6 +2,7 @@
 CREATE TABLE orders (
     id VARCHAR(36) PRIMARY KEY,
     symbol VARCHAR(50) NOT NULL,
+    account VARCHAR(64) NOT NULL DEFAULT '',
     side ENUM('buy', 'sell') NOT NULL,
     type ENUM('limit', 'market') NOT NULL,
     price DECIMAL(10,2), -- NULL for market orders
@@ -9,7 +10,11 @@ CREATE TABLE orders (
     remaining_quantity INT NOT NULL,
     status ENUM('open', 'filled', 'cancelled', 'partial') NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    INDEX idx_symbol_side_price (symbol, side, price, created_at)
+    INDEX idx_symbol_side_price (symbol, side, price, created_at),
+    INDEX idx_account_status (account, status, created_at),
+    INDEX idx_symbol_created (symbol, created_at, id),
+    INDEX idx_status_created (status, created_at, id),
+    INDEX idx_created (created_at, id)
 );
 
 -- Trades table
@@ -23,5 +28,37 @@ CREATE TABLE trades (
     executed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (buy_order_id) REFERENCES orders(id),
     FOREIGN KEY (sell_order_id) REFERENCES orders(id),
-    INDEX idx_symbol_time (symbol, executed_at)
-);
\ No newline at end of file
+    INDEX idx_symbol_time (symbol, executed_at, id),
+    INDEX idx_executed_at (executed_at, id),
+    INDEX idx_buy_order (buy_order_id, executed_at),
+    INDEX idx_sell_order (sell_order_id, executed_at)
+);
+
+-- Order lifecycle events (no foreign key: rejected orders are never stored)
+CREATE TABLE order_events (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    order_id VARCHAR(36) NOT NULL,
+    type ENUM('accepted', 'partial_fill', 'filled', 'cancelled', 'amended', 'expired', 'rejected') NOT NULL,
+    quantity INT NOT NULL DEFAULT 0, -- executed quantity for fills
+    remaining_quantity INT NOT NULL,
+    price DECIMAL(10,2), -- trade price for fills, limit price otherwise
+    trade_id VARCHAR(36),
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_order_id (order_id, id)
+);
+
+-- API keys; the secret signs requests and is needed to verify them, so it is
+-- stored in plaintext (unlike a password it cannot be hashed). Anyone who can
+-- read this table can sign requests as any key: restrict access to it and to
+-- backups as you would the database password.
+CREATE TABLE api_keys (
+    id VARCHAR(32) PRIMARY KEY,
+    secret VARCHAR(64) NOT NULL,
+    account VARCHAR(64) NOT NULL,
+    scope ENUM('read', 'trade') NOT NULL,
+    label VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP NULL,
+    INDEX idx_account_created (account, created_at)
+);
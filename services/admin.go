@@ -0,0 +1,215 @@
+package services
+
+import (
+	"fmt"
+	"order-matching-engine/journal"
+	"order-matching-engine/models"
+	"order-matching-engine/utils"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// lockStats counts how long operations wait for and hold the engine's write
+// lock. Wait and hold times use the wall clock whatever clock the engine
+// stamps orders with.
+type lockStats struct {
+	acquisitions atomic.Int64
+	contended    atomic.Int64
+	waiting      atomic.Int64
+	totalWait    atomic.Int64
+	maxWait      atomic.Int64
+	totalHold    atomic.Int64
+	maxHold      atomic.Int64
+	// Set by the holder of the lock
+	heldSince time.Time
+}
+
+// lock takes the engine's write lock, counting the wait if another operation
+// holds it.
+func (me *MatchingEngine) lock() {
+	stats := &me.lockStats
+	if !me.mu.TryLock() {
+		stats.waiting.Add(1)
+		start := time.Now()
+		me.mu.Lock()
+		wait := time.Since(start)
+		stats.waiting.Add(-1)
+		stats.contended.Add(1)
+		stats.totalWait.Add(int64(wait))
+		storeMax(&stats.maxWait, int64(wait))
+	}
+	stats.acquisitions.Add(1)
+	stats.heldSince = time.Now()
+}
+
+func (me *MatchingEngine) unlock() {
+	stats := &me.lockStats
+	held := time.Since(stats.heldSince)
+	stats.totalHold.Add(int64(held))
+	storeMax(&stats.maxHold, int64(held))
+	me.mu.Unlock()
+}
+
+func storeMax(v *atomic.Int64, n int64) {
+	for {
+		old := v.Load()
+		if n <= old || v.CompareAndSwap(old, n) {
+			return
+		}
+	}
+}
+
+// checkTrading reports why new orders and amends on symbol are refused, if
+// they are. Cancels are always allowed. Callers hold the lock.
+func (me *MatchingEngine) checkTrading(symbol string) error {
+	if err := me.checkJournal(); err != nil {
+		return err
+	}
+	if me.maintenance {
+		return utils.ErrMaintenanceMode
+	}
+	if me.halted[symbol] {
+		return fmt.Errorf("%w: %s", utils.ErrSymbolHalted, symbol)
+	}
+	return nil
+}
+
+// CheckTrading reports why new orders on any of symbols would be refused,
+// as ProcessOrder would, without placing anything.
+func (me *MatchingEngine) CheckTrading(symbols ...string) error {
+	me.lock()
+	defer me.unlock()
+
+	for _, symbol := range symbols {
+		if err := me.checkTrading(symbol); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Halt stops new orders and amends on symbol until Resume. Resting orders
+// stay on the book and can still be cancelled. Halts are not journaled and
+// are lifted by a restart.
+func (me *MatchingEngine) Halt(symbol string) {
+	me.lock()
+	defer me.unlock()
+
+	me.halted[symbol] = true
+}
+
+func (me *MatchingEngine) Resume(symbol string) {
+	me.lock()
+	defer me.unlock()
+
+	delete(me.halted, symbol)
+}
+
+// SetMaintenance switches maintenance mode, in which the engine accepts
+// only cancels, on or off.
+func (me *MatchingEngine) SetMaintenance(enabled bool) {
+	me.lock()
+	defer me.unlock()
+
+	me.maintenance = enabled
+}
+
+// AdminCancel cancels the resting orders selected by req in one transaction,
+// whatever account they belong to, and returns their IDs.
+func (me *MatchingEngine) AdminCancel(req models.AdminCancelRequest) ([]string, error) {
+	me.lock()
+	defer me.unlock()
+
+	cancelledIDs := make([]string, 0)
+	if req.Empty() {
+		return cancelledIDs, nil
+	}
+	var orders []*models.Order
+	for _, book := range me.orderBooks {
+		orders = append(orders, book.FindOrders(req.Matches)...)
+	}
+	if len(orders) == 0 {
+		return cancelledIDs, nil
+	}
+
+	if err := me.cancelResting(orders, journal.ReasonAdminCancel); err != nil {
+		return nil, fmt.Errorf("failed to execute admin cancel transaction: %w", err)
+	}
+	for _, order := range orders {
+		cancelledIDs = append(cancelledIDs, order.ID)
+	}
+	return cancelledIDs, nil
+}
+
+// SymbolStatuses summarizes every order book, sorted by symbol. Halted
+// symbols without a book are included.
+func (me *MatchingEngine) SymbolStatuses() []models.SymbolStatus {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+
+	statuses := make([]models.SymbolStatus, 0, len(me.orderBooks))
+	for symbol, book := range me.orderBooks {
+		status := models.SymbolStatus{Symbol: symbol, Halted: me.halted[symbol]}
+		book.mu.RLock()
+		status.BidOrders = len(book.BuyOrders)
+		status.AskOrders = len(book.SellOrders)
+		for _, order := range book.BuyOrders {
+			status.BidQuantity += order.RemainingQuantity
+		}
+		for _, order := range book.SellOrders {
+			status.AskQuantity += order.RemainingQuantity
+		}
+		book.mu.RUnlock()
+		statuses = append(statuses, status)
+	}
+	for symbol := range me.halted {
+		if _, exists := me.orderBooks[symbol]; !exists {
+			statuses = append(statuses, models.SymbolStatus{Symbol: symbol, Halted: true})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Symbol < statuses[j].Symbol
+	})
+	return statuses
+}
+
+// Status returns the engine's trading state and lock statistics.
+func (me *MatchingEngine) Status() models.EngineStatus {
+	// Read the lock queue before joining it
+	stats := &me.lockStats
+	lock := models.LockStats{
+		Acquisitions: stats.acquisitions.Load(),
+		Contended:    stats.contended.Load(),
+		Waiting:      stats.waiting.Load(),
+		TotalWaitMs:  millis(stats.totalWait.Load()),
+		MaxWaitMs:    millis(stats.maxWait.Load()),
+		TotalHoldMs:  millis(stats.totalHold.Load()),
+		MaxHoldMs:    millis(stats.maxHold.Load()),
+	}
+
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+
+	status := models.EngineStatus{
+		Maintenance:   me.maintenance,
+		JournalFailed: me.journalErr != nil,
+		HaltedSymbols: make([]string, 0, len(me.halted)),
+		Symbols:       len(me.orderBooks),
+		Lock:          lock,
+	}
+	for symbol := range me.halted {
+		status.HaltedSymbols = append(status.HaltedSymbols, symbol)
+	}
+	sort.Strings(status.HaltedSymbols)
+	for _, book := range me.orderBooks {
+		book.mu.RLock()
+		status.RestingOrders += len(book.BuyOrders) + len(book.SellOrders)
+		book.mu.RUnlock()
+	}
+	return status
+}
+
+func millis(nanos int64) float64 {
+	return float64(nanos) / float64(time.Millisecond)
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+	"order-matching-engine/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CandleIntervals are the bar widths the candle service maintains, keyed by
+// the name clients request them with.
+var CandleIntervals = map[string]time.Duration{
+	"1s":  time.Second,
+	"5s":  5 * time.Second,
+	"15s": 15 * time.Second,
+	"30s": 30 * time.Second,
+	"1m":  time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"4h":  4 * time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+var ErrUnknownInterval = errors.New("unknown candle interval")
+
+// CandleService aggregates trades into OHLCV bars for every symbol and
+// interval. It is updated incrementally as a Listener on the engine and can
+// be backfilled from historical trades at startup. Each series keeps only its
+// most recent candles.
+type CandleService struct {
+	series     map[candleKey][]*models.Candle
+	maxCandles int
+	mu         sync.RWMutex
+}
+
+type candleKey struct {
+	symbol   string
+	interval string
+}
+
+func NewCandleService(maxCandles int) *CandleService {
+	return &CandleService{
+		series:     make(map[candleKey][]*models.Candle),
+		maxCandles: maxCandles,
+	}
+}
+
+// OnEvent implements Listener.
+func (cs *CandleService) OnEvent(event Event) {
+	if event.Type == EventTrade {
+		cs.AddTrade(event.Trade)
+	}
+}
+
+// Backfill aggregates historical trades, e.g. loaded from the trades table.
+func (cs *CandleService) Backfill(trades []*models.Trade) {
+	for _, trade := range trades {
+		cs.AddTrade(trade)
+	}
+}
+
+// AddTrade folds a trade into the candle of every interval it falls in.
+func (cs *CandleService) AddTrade(trade *models.Trade) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for name, width := range CandleIntervals {
+		key := candleKey{symbol: trade.Symbol, interval: name}
+		candle := cs.candleAt(key, width, trade.ExecutedAt.UTC().Truncate(width))
+		if candle == nil {
+			continue
+		}
+
+		if candle.TradeCount == 0 {
+			candle.Open, candle.High, candle.Low = trade.Price, trade.Price, trade.Price
+		}
+		if trade.Price > candle.High {
+			candle.High = trade.Price
+		}
+		if trade.Price < candle.Low {
+			candle.Low = trade.Price
+		}
+		candle.Close = trade.Price
+		candle.Volume += trade.Quantity
+		candle.QuoteVolume += trade.Price * float64(trade.Quantity)
+		candle.TradeCount++
+	}
+}
+
+// Candles returns the bars for symbol and interval whose open time is in
+// [from, to], oldest first. Zero from or to leaves that end unbounded.
+func (cs *CandleService) Candles(symbol, interval string, from, to time.Time) ([]models.Candle, error) {
+	if _, ok := CandleIntervals[interval]; !ok {
+		return nil, ErrUnknownInterval
+	}
+
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	candles := make([]models.Candle, 0)
+	for _, candle := range cs.series[candleKey{symbol: symbol, interval: interval}] {
+		if !from.IsZero() && candle.OpenTime.Before(from) {
+			continue
+		}
+		if !to.IsZero() && candle.OpenTime.After(to) {
+			break
+		}
+		candles = append(candles, *candle)
+	}
+	return candles, nil
+}
+
+// candleAt finds or creates the candle opening at openTime. It returns nil
+// for trades older than the retained window. Must be called with cs.mu held.
+func (cs *CandleService) candleAt(key candleKey, width time.Duration, openTime time.Time) *models.Candle {
+	series := cs.series[key]
+
+	// Live trades land in the newest candle, so check the end first
+	if n := len(series); n > 0 && series[n-1].OpenTime.Equal(openTime) {
+		return series[n-1]
+	}
+
+	i := sort.Search(len(series), func(i int) bool {
+		return !series[i].OpenTime.Before(openTime)
+	})
+	if i < len(series) && series[i].OpenTime.Equal(openTime) {
+		return series[i]
+	}
+	if len(series) >= cs.maxCandles && i == 0 {
+		return nil
+	}
+
+	candle := &models.Candle{
+		Symbol:    key.symbol,
+		Interval:  key.interval,
+		OpenTime:  openTime,
+		CloseTime: openTime.Add(width),
+	}
+	series = append(series, nil)
+	copy(series[i+1:], series[i:])
+	series[i] = candle
+
+	if len(series) > cs.maxCandles {
+		series = series[len(series)-cs.maxCandles:]
+	}
+	cs.series[key] = series
+	return candle
+}
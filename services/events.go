@@ -0,0 +1,130 @@
+package services
+
+import "order-matching-engine/models"
+
+type EventType string
+
+const (
+	// EventTrade is emitted for every trade, in execution order.
+	EventTrade EventType = "trade"
+	// EventBookUpdate carries the new aggregate state of every price level
+	// touched by a match or cancel.
+	EventBookUpdate EventType = "book_update"
+	// EventOrderBook carries the order-level (L3) changes made to a book by
+	// one operation, in the order they happened.
+	EventOrderBook EventType = "order_book"
+	// EventOrderStatus carries an order after it was accepted, filled or
+	// cancelled.
+	EventOrderStatus EventType = "order_status"
+)
+
+// Event describes a committed change to the engine. Events for one symbol
+// are delivered in the order the changes happened.
+type Event struct {
+	Type   EventType
+	Symbol string
+	Trade  *models.Trade
+	Levels []models.PriceLevelUpdate
+	Orders []models.OrderBookEvent
+	Order  *models.Order
+	// Accounts that own the buy and sell orders of a trade
+	BuyAccount  string
+	SellAccount string
+	// Side of the incoming order that took liquidity in a trade
+	Aggressor string
+}
+
+// Listener receives engine events after they have been persisted. OnEvent
+// is called with the engine lock held, so it must not block or call back
+// into the engine.
+type Listener interface {
+	OnEvent(event Event)
+}
+
+// WithListener registers l to receive engine events
+func WithListener(l Listener) Option {
+	return func(me *MatchingEngine) {
+		me.listeners = append(me.listeners, l)
+	}
+}
+
+func (me *MatchingEngine) emit(events ...Event) {
+	for _, event := range events {
+		for _, listener := range me.listeners {
+			listener.OnEvent(event)
+		}
+	}
+}
+
+func tradeEvent(trade *models.Trade, incoming, resting *models.Order) Event {
+	event := Event{Type: EventTrade, Symbol: trade.Symbol, Trade: trade, Aggressor: incoming.Side}
+	event.BuyAccount, event.SellAccount = incoming.Account, resting.Account
+	if incoming.Side == "sell" {
+		event.BuyAccount, event.SellAccount = resting.Account, incoming.Account
+	}
+	return event
+}
+
+// statusEvent copies order, since the engine keeps mutating resting orders
+// after the event has been delivered.
+func statusEvent(order *models.Order) Event {
+	return Event{Type: EventOrderStatus, Symbol: order.Symbol, Order: snapshot(order)}
+}
+
+// bookEvents builds the L2 and L3 events for the changes an operation made to
+// book, consuming the book's pending order-level changes.
+func (me *MatchingEngine) bookEvents(book *OrderBook, levels *levelTracker) []Event {
+	events := []Event{{Type: EventBookUpdate, Symbol: book.Symbol, Levels: levels.updates(book)}}
+
+	if changes := book.TakeChanges(); len(changes) > 0 {
+		now := me.clock.Now()
+		for i := range changes {
+			changes[i].Timestamp = now
+		}
+		events = append(events, Event{Type: EventOrderBook, Symbol: book.Symbol, Orders: changes})
+	}
+	return events
+}
+
+// priceLevel identifies one side of a price level in a book.
+type priceLevel struct {
+	side  string
+	price float64
+}
+
+// levelTracker collects the price levels an operation touches so their new
+// totals can be published once it commits.
+type levelTracker struct {
+	seen   map[priceLevel]bool
+	levels []priceLevel
+}
+
+func (t *levelTracker) touch(order *models.Order) {
+	if order.Price == nil || order.Type != "limit" {
+		return
+	}
+	level := priceLevel{side: order.Side, price: *order.Price}
+	if t.seen == nil {
+		t.seen = make(map[priceLevel]bool)
+	}
+	if !t.seen[level] {
+		t.seen[level] = true
+		t.levels = append(t.levels, level)
+	}
+}
+
+// updates reads the current total of each touched level from book. A level
+// with no resting orders is reported with zero quantity.
+func (t *levelTracker) updates(book *OrderBook) []models.PriceLevelUpdate {
+	updates := make([]models.PriceLevelUpdate, 0, len(t.levels))
+	for _, level := range t.levels {
+		quantity, orders := book.LevelTotals(level.side, level.price)
+		updates = append(updates, models.PriceLevelUpdate{
+			Side:     level.side,
+			Price:    level.price,
+			Quantity: quantity,
+			Orders:   orders,
+		})
+	}
+	return updates
+}
@@ -1,53 +1,354 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"order-matching-engine/database"
+	"log/slog"
+	"order-matching-engine/clock"
+	"order-matching-engine/idgen"
+	"order-matching-engine/journal"
 	"order-matching-engine/models"
+	"order-matching-engine/tracing"
 	"order-matching-engine/utils"
+	"sort"
 	"sync"
 	"time"
 
-	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("order-matching-engine/services")
+
 type MatchingEngine struct {
 	orderBooks map[string]*OrderBook
-	mu         sync.RWMutex
+	store      Store
+	journal    *journal.Journal
+	clock      clock.Clock
+	tradeIDs   idgen.Generator
+	listeners  []Listener
+	metrics    Metrics
+	// Trading state set by operators
+	halted      map[string]bool
+	maintenance bool
+	// Set once a journal append fails; see record
+	journalErr error
+	// Latest time an order was queued by
+	lastPriority time.Time
+	mu           sync.RWMutex
+	lockStats    lockStats
+	// Serializes snapshots taken on schedule and on demand
+	snapshotMu sync.Mutex
+}
+
+// Option configures optional MatchingEngine dependencies
+type Option func(*MatchingEngine)
+
+// WithJournal records every order accepted, trade, cancel and reject to j
+func WithJournal(j *journal.Journal) Option {
+	return func(me *MatchingEngine) {
+		me.journal = j
+	}
+}
+
+// WithStore persists matching results to store instead of MySQL
+func WithStore(store Store) Option {
+	return func(me *MatchingEngine) {
+		me.store = store
+	}
+}
+
+// WithClock stamps trades, journal entries and snapshots with times from c
+// instead of the wall clock
+func WithClock(c clock.Clock) Option {
+	return func(me *MatchingEngine) {
+		me.clock = c
+	}
 }
 
-func NewMatchingEngine() *MatchingEngine {
-	return &MatchingEngine{
+// WithTradeIDs assigns trade IDs from ids instead of random UUIDs
+func WithTradeIDs(ids idgen.Generator) Option {
+	return func(me *MatchingEngine) {
+		me.tradeIDs = ids
+	}
+}
+
+func NewMatchingEngine(opts ...Option) *MatchingEngine {
+	me := &MatchingEngine{
 		orderBooks: make(map[string]*OrderBook),
+		halted:     make(map[string]bool),
+		store:      databaseStore{},
+		clock:      clock.System{},
+		tradeIDs:   idgen.UUID{},
+		metrics:    noMetrics{},
 	}
+	for _, opt := range opts {
+		opt(me)
+	}
+	return me
 }
 
-func (me *MatchingEngine) ProcessOrder(order *models.Order) ([]*models.Trade, error) {
+// ProcessOrder matches an order against its book and persists the result.
+// It sets the order's CreatedAt, which gives its time priority.
+// The work is traced as part of ctx, but persistence is not cancelled with
+// it since the book has already changed by then.
+func (me *MatchingEngine) ProcessOrder(ctx context.Context, order *models.Order) (_ []*models.Trade, err error) {
 	if order == nil {
 		return nil, errors.New("order cannot be nil")
 	}
+	ctx, span := tracer.Start(ctx, "MatchingEngine.ProcessOrder", trace.WithAttributes(
+		tracing.OrderID(order.ID), tracing.Symbol(order.Symbol),
+		attribute.String("order.side", order.Side), attribute.String("order.type", order.Type)))
+	defer func() { tracing.End(span, err) }()
+	ctx = context.WithoutCancel(ctx)
+
+	_, lockSpan := tracer.Start(ctx, "MatchingEngine.lock")
+	me.lock()
+	lockSpan.End()
+	defer me.unlock()
+
+	// Orders queue by CreatedAt, so it is stamped under the lock: an order
+	// always queues behind those that reached the book before it
+	now := me.clock.Now()
+	order.CreatedAt = me.priorityTime(now)
+
+	// Keep the order as it arrived for the journal, matching mutates it
+	accepted := *order
+
+	if err := me.checkTrading(order.Symbol); err != nil {
+		me.reject(ctx, &accepted, err, now)
+		return nil, err
+	}
 
-	me.mu.Lock()
-	defer me.mu.Unlock()
+	_, matchSpan := tracer.Start(ctx, "MatchingEngine.match")
+	start := time.Now()
+	book := me.getOrderBook(order.Symbol)
+	before := book.saveSides()
+	trades, updatedOrders := me.match(order, book)
+	matched := time.Now()
+	matchSpan.SetAttributes(attribute.Int("trades", len(trades)))
+	matchSpan.End()
+
+	entries := []*journal.Entry{{Type: journal.EntryOrderAccepted, Order: &accepted, OrderID: order.ID}}
+	for _, trade := range trades {
+		entries = append(entries, &journal.Entry{Type: journal.EntryTrade, Trade: trade})
+	}
+	if order.Status == "cancelled" {
+		entries = append(entries, &journal.Entry{Type: journal.EntryOrderCancelled, Order: snapshot(order), OrderID: order.ID, Reason: journal.ReasonNoLiquidity})
+	}
+	if err := me.record(entries...); err != nil {
+		// Nothing was committed; undo the match, and the engine now refuses
+		// further changes until a restart rebuilds it from the journal
+		me.undoMatch(book, before, trades, updatedOrders)
+		me.reject(ctx, &accepted, err, now)
+		return nil, err
+	}
 
-	var trades []*models.Trade
-	var updatedOrders []*models.Order
+	// Execute all database operations, including the lifecycle events, in a
+	// single transaction
+	orderEvents := matchingEvents(models.OrderEventAccepted, &accepted, order, trades, updatedOrders, now)
+	err = me.store.ExecuteOrderMatching(ctx, order, trades, updatedOrders, orderEvents)
+	me.metrics.ObserveProcessOrder(matched.Sub(start), time.Since(matched))
+	if err != nil {
+		// Undo the match and withdraw its journal entries, so neither the
+		// book nor a recovery from the journal keeps what the database lacks
+		me.undoMatch(book, before, trades, updatedOrders)
+		me.rollback(entries, err)
+		me.reject(ctx, &accepted, err, now)
+		return nil, fmt.Errorf("failed to execute order matching transaction: %w", err)
+	}
+	me.metrics.OrderAccepted(&accepted)
+	slog.DebugContext(ctx, "order processed", "order_id", order.ID, "symbol", order.Symbol, "status", order.Status, "trades", len(trades))
+	span.SetAttributes(attribute.Int("trades", len(trades)), attribute.String("order.status", order.Status))
 
-	book := me.getOrderBook(order.Symbol)
+	me.emitMatch(order, trades, updatedOrders, book, &levelTracker{})
+
+	return trades, nil
+}
+
+// reject logs, journals, records and reports the rejection of an order as
+// it arrived.
+func (me *MatchingEngine) reject(ctx context.Context, order *models.Order, err error, now time.Time) {
+	level := slog.LevelWarn
+	if rejectReason(err) == RejectReasonPersistence {
+		level = slog.LevelError
+	}
+	slog.Log(ctx, level, "order rejected", "order_id", order.ID, "symbol", order.Symbol, "account", order.Account,
+		"side", order.Side, "type", order.Type, "reason", rejectReason(err), "error", err)
+	me.metrics.OrderRejected(order, rejectReason(err))
+	reason := err.Error()
+	// A journal failure is logged by record and stops the engine
+	me.record(&journal.Entry{Type: journal.EntryOrderRejected, Order: order, OrderID: order.ID, Reason: reason})
+	rejected := newOrderEvent(order, models.OrderEventRejected, reason, now)
+	if err := me.store.SaveOrderEvents(ctx, []*models.OrderEvent{rejected}); err != nil {
+		slog.ErrorContext(ctx, "failed to record order rejection", "order_id", order.ID, "error", err)
+	}
+}
+
+// emitMatch publishes the trades an order made, the new status of every
+// order involved and the resulting book changes. levels may already hold
+// price levels the operation touched before matching.
+func (me *MatchingEngine) emitMatch(order *models.Order, trades []*models.Trade, updatedOrders []*models.Order, book *OrderBook, levels *levelTracker) {
+	if order.RemainingQuantity > 0 {
+		levels.touch(order)
+	}
+	events := make([]Event, 0, 2*len(trades)+3)
+	for i, trade := range trades {
+		resting := updatedOrders[i]
+		levels.touch(resting)
+		events = append(events, tradeEvent(trade, order, resting), statusEvent(resting))
+	}
+	events = append(events, statusEvent(order))
+	events = append(events, me.bookEvents(book, levels)...)
+	me.emit(events...)
+}
+
+// Replay applies a journal entry to the in-memory order books without
+// touching the database. Accepted orders are re-matched, which reproduces the
+// journaled trades, so trade and reject entries are skipped. Callers skip
+// the entries withdrawn by a rollback; see journal.RolledBack.
+func (me *MatchingEngine) Replay(entry *journal.Entry) error {
+	me.lock()
+	defer me.unlock()
+
+	// The re-matched trades are thrown away, so they must not use up trade
+	// IDs or clock readings the engine hands out once recovered
+	tradeIDs, clk := me.tradeIDs, me.clock
+	me.tradeIDs, me.clock = idgen.NewSequence("replay-", 0), fixedClock(entry.Timestamp)
+	defer func() { me.tradeIDs, me.clock = tradeIDs, clk }()
+
+	switch entry.Type {
+	case journal.EntryOrderAccepted:
+		if entry.Order == nil {
+			return fmt.Errorf("journal entry %d has no order", entry.Sequence)
+		}
+		order := *entry.Order
+		me.notePriority(order.CreatedAt)
+		book := me.getOrderBook(order.Symbol)
+		me.match(&order, book)
+		// Recovery rebuilds state that market data consumers are seeded with
+		book.TakeChanges()
+	case journal.EntryOrderAmended:
+		if entry.Order == nil {
+			return fmt.Errorf("journal entry %d has no order", entry.Sequence)
+		}
+		order, book := me.findRestingOrder(entry.OrderID)
+		if order == nil {
+			return nil
+		}
+		amended := *entry.Order
+		me.notePriority(amended.CreatedAt)
+		if keepsPriority(order, amended.Price, amended.RemainingQuantity) {
+			book.ReduceOrder(order, amended.InitialQuantity, amended.RemainingQuantity)
+			order.Status = amended.Status
+		} else {
+			book.RemoveOrder(order.ID)
+			me.match(&amended, book)
+		}
+		book.TakeChanges()
+	case journal.EntryOrderCancelled:
+		me.removeRestingOrder(entry.OrderID)
+	}
+	return nil
+}
+
+// fixedClock always reads the same time.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
 
+func (me *MatchingEngine) match(order *models.Order, book *OrderBook) ([]*models.Trade, []*models.Order) {
 	if order.Side == "buy" {
-		trades, updatedOrders = me.matchBuyOrder(order, book)
-	} else {
-		trades, updatedOrders = me.matchSellOrder(order, book)
+		return me.matchBuyOrder(order, book)
 	}
+	return me.matchSellOrder(order, book)
+}
 
-	// Execute all database operations in a single transaction
-	if err := database.ExecuteOrderMatching(order, trades, updatedOrders); err != nil {
-		return nil, fmt.Errorf("failed to execute order matching transaction: %w", err)
+func (me *MatchingEngine) removeRestingOrder(orderID string) {
+	if order, book := me.findRestingOrder(orderID); order != nil {
+		book.RemoveOrder(orderID)
+		book.TakeChanges()
+		order.Status = "cancelled"
 	}
+}
 
-	return trades, nil
+// findRestingOrder returns the live order resting in a book and that book,
+// or nil if no book holds the order.
+func (me *MatchingEngine) findRestingOrder(orderID string) (*models.Order, *OrderBook) {
+	for _, book := range me.orderBooks {
+		for _, order := range book.FindOrders(func(o *models.Order) bool { return o.ID == orderID }) {
+			return order, book
+		}
+	}
+	return nil, nil
+}
+
+// record appends entries to the journal, if one is configured, before the
+// change they describe is committed to the database, so recovery never
+// misses a committed change. A failed append stops the engine: every later
+// order, amend and cancel is refused until a restart recovers from the
+// journal.
+func (me *MatchingEngine) record(entries ...*journal.Entry) error {
+	if me.journal == nil || len(entries) == 0 {
+		return nil
+	}
+	now := me.clock.Now()
+	for _, entry := range entries {
+		entry.Timestamp = now
+	}
+	if err := me.journal.Append(entries...); err != nil {
+		if me.journalErr == nil {
+			slog.Error("failed to append to journal, refusing all changes until restart", "entries", len(entries), "error", err)
+		}
+		me.journalErr = err
+		return fmt.Errorf("%w: %v", utils.ErrJournalFailed, err)
+	}
+	return nil
+}
+
+// rollback withdraws the journal entries of an operation whose database
+// transaction failed, once the caller has undone it in memory, so recovery
+// does not rebuild it. If even that cannot be journaled the engine stops as
+// for any journal failure, and the operation has to be reconciled by hand
+// after a restart.
+func (me *MatchingEngine) rollback(entries []*journal.Entry, cause error) {
+	if me.journal == nil || len(entries) == 0 {
+		return
+	}
+	me.record(&journal.Entry{
+		Type:   journal.EntryRolledBack,
+		From:   entries[0].Sequence,
+		To:     entries[len(entries)-1].Sequence,
+		Reason: cause.Error(),
+	})
+}
+
+// undoMatch puts a book back as it was saved before matching and returns
+// the traded quantities to the resting orders involved.
+func (me *MatchingEngine) undoMatch(book *OrderBook, before bookSides, trades []*models.Trade, updatedOrders []*models.Order) {
+	for i, trade := range trades {
+		resting := updatedOrders[i]
+		resting.RemainingQuantity += trade.Quantity
+		setAmendedStatus(resting)
+	}
+	book.restoreSides(before)
+}
+
+// checkJournal refuses changes once the journal has failed.
+func (me *MatchingEngine) checkJournal() error {
+	if me.journalErr != nil {
+		return fmt.Errorf("%w: %v", utils.ErrJournalFailed, me.journalErr)
+	}
+	return nil
+}
+
+func snapshot(order *models.Order) *models.Order {
+	copied := *order
+	return &copied
 }
 
 func (me *MatchingEngine) getOrderBook(symbol string) *OrderBook {
@@ -72,6 +373,7 @@ func (me *MatchingEngine) matchBuyOrder(buyOrder *models.Order, book *OrderBook)
 
 		trade := me.executeTrade(buyOrder, sellOrder)
 		trades = append(trades, trade)
+		book.RecordExecution(sellOrder, trade)
 
 		// Update order statuses
 		me.updateOrderStatus(buyOrder)
@@ -111,6 +413,7 @@ func (me *MatchingEngine) matchSellOrder(sellOrder *models.Order, book *OrderBoo
 
 		trade := me.executeTrade(buyOrder, sellOrder)
 		trades = append(trades, trade)
+		book.RecordExecution(buyOrder, trade)
 
 		// Update order statuses
 		me.updateOrderStatus(buyOrder)
@@ -171,13 +474,13 @@ func (me *MatchingEngine) executeTrade(buyOrder, sellOrder *models.Order) *model
 	sellOrder.RemainingQuantity -= quantity
 
 	return &models.Trade{
-		ID:          uuid.New().String(),
+		ID:          me.tradeIDs.NewID(),
 		Symbol:      buyOrder.Symbol,
 		BuyOrderID:  buyOrder.ID,
 		SellOrderID: sellOrder.ID,
 		Price:       price,
 		Quantity:    quantity,
-		ExecutedAt:  time.Now(),
+		ExecutedAt:  me.clock.Now(),
 	}
 }
 
@@ -189,12 +492,19 @@ func (me *MatchingEngine) updateOrderStatus(order *models.Order) {
 	}
 }
 
-func (me *MatchingEngine) GetOrder(orderID string) (*models.Order, error) {
-	return database.GetOrderByID(orderID)
+func (me *MatchingEngine) GetOrder(ctx context.Context, orderID string) (*models.Order, error) {
+	return me.store.GetOrderByID(ctx, orderID)
 }
 
 func (me *MatchingEngine) CancelOrder(orderID string) error {
-	order, err := database.GetOrderByID(orderID)
+	me.lock()
+	defer me.unlock()
+
+	if err := me.checkJournal(); err != nil {
+		return err
+	}
+
+	order, err := me.store.GetOrderByID(context.Background(), orderID)
 	if err != nil {
 		return fmt.Errorf("failed to get order: %w", err)
 	}
@@ -206,14 +516,287 @@ func (me *MatchingEngine) CancelOrder(orderID string) error {
 		return fmt.Errorf("cannot cancel order with status: %s", order.Status)
 	}
 
-	// Remove from order book
-	if book, exists := me.orderBooks[order.Symbol]; exists {
-		book.RemoveOrder(orderID)
+	order.Status = "cancelled"
+	entry := &journal.Entry{Type: journal.EntryOrderCancelled, Order: order, OrderID: order.ID, Reason: journal.ReasonCancelRequested}
+	if err := me.record(entry); err != nil {
+		return err
 	}
 
 	// Update status in database
-	order.Status = "cancelled"
-	return database.UpdateOrder(order)
+	cancelled := newOrderEvent(order, models.OrderEventCancelled, journal.ReasonCancelRequested, me.clock.Now())
+	if err := me.store.CancelOrders(context.Background(), []*models.Order{order}, []*models.OrderEvent{cancelled}); err != nil {
+		me.rollback([]*journal.Entry{entry}, err)
+		return err
+	}
+
+	// Remove from order book once the cancel has committed
+	book, exists := me.orderBooks[order.Symbol]
+	if exists {
+		book.RemoveOrder(orderID)
+	}
+
+	me.emit(statusEvent(order))
+	if exists {
+		var levels levelTracker
+		levels.touch(order)
+		me.emit(me.bookEvents(book, &levels)...)
+	}
+	return nil
+}
+
+// AmendOrder changes a resting limit order's total quantity and, if price is
+// not nil, its price. Reducing the quantity at the same price keeps the
+// order's place in the queue. Any other change requeues it behind the orders
+// already at its price, as of now, and it trades at once if the new price
+// crosses the book.
+func (me *MatchingEngine) AmendOrder(orderID string, quantity int, price *float64) (*models.Order, []*models.Trade, error) {
+	me.lock()
+	defer me.unlock()
+
+	order, book := me.findRestingOrder(orderID)
+	if order == nil {
+		stored, err := me.store.GetOrderByID(context.Background(), orderID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get order: %w", err)
+		}
+		if stored == nil {
+			return nil, nil, utils.ErrOrderNotFound
+		}
+		return nil, nil, fmt.Errorf("cannot amend order with status: %s", stored.Status)
+	}
+
+	filled := order.InitialQuantity - order.RemainingQuantity
+	if quantity <= filled {
+		return nil, nil, fmt.Errorf("quantity must exceed the filled quantity of %d", filled)
+	}
+	if price == nil {
+		price = order.Price
+	} else if *price <= 0 {
+		return nil, nil, errors.New("price must be positive")
+	}
+	newPrice := *price
+	remaining := quantity - filled
+	now := me.clock.Now()
+
+	// Reductions in place are partial cancels and allowed in any state
+	if !keepsPriority(order, &newPrice, remaining) {
+		if err := me.checkTrading(order.Symbol); err != nil {
+			slog.Warn("amend rejected", "order_id", order.ID, "symbol", order.Symbol, "reason", rejectReason(err), "error", err)
+			return nil, nil, err
+		}
+	}
+
+	var levels levelTracker
+	levels.touch(order)
+
+	if keepsPriority(order, &newPrice, remaining) {
+		// Nothing can match, so persist first and only then touch the book
+		amended := *order
+		amended.InitialQuantity = quantity
+		amended.RemainingQuantity = remaining
+		setAmendedStatus(&amended)
+		entry := &journal.Entry{Type: journal.EntryOrderAmended, Order: snapshot(&amended), OrderID: order.ID}
+		if err := me.record(entry); err != nil {
+			return nil, nil, err
+		}
+		event := newOrderEvent(&amended, models.OrderEventAmended, "", now)
+		if err := me.store.AmendOrder(context.Background(), &amended, nil, nil, []*models.OrderEvent{event}); err != nil {
+			me.rollback([]*journal.Entry{entry}, err)
+			return nil, nil, fmt.Errorf("failed to execute order amend transaction: %w", err)
+		}
+		book.ReduceOrder(order, quantity, remaining)
+		order.Status = amended.Status
+		me.emit(statusEvent(order))
+		me.emit(me.bookEvents(book, &levels)...)
+		return snapshot(order), nil, nil
+	}
+
+	before := book.saveSides()
+	original := *order
+	book.RemoveOrder(orderID)
+	order.Price = &newPrice
+	order.InitialQuantity = quantity
+	order.RemainingQuantity = remaining
+	order.CreatedAt = me.priorityTime(now)
+	setAmendedStatus(order)
+	amended := *order
+
+	trades, updatedOrders := me.match(order, book)
+	entries := []*journal.Entry{{Type: journal.EntryOrderAmended, Order: &amended, OrderID: order.ID}}
+	for _, trade := range trades {
+		entries = append(entries, &journal.Entry{Type: journal.EntryTrade, Trade: trade})
+	}
+	if err := me.record(entries...); err != nil {
+		me.undoMatch(book, before, trades, updatedOrders)
+		*order = original
+		return nil, nil, err
+	}
+
+	orderEvents := matchingEvents(models.OrderEventAmended, &amended, order, trades, updatedOrders, now)
+	if err := me.store.AmendOrder(context.Background(), order, trades, updatedOrders, orderEvents); err != nil {
+		// As in ProcessOrder, undo the requeue and withdraw it
+		me.undoMatch(book, before, trades, updatedOrders)
+		*order = original
+		me.rollback(entries, err)
+		return nil, nil, fmt.Errorf("failed to execute order amend transaction: %w", err)
+	}
+
+	me.emitMatch(order, trades, updatedOrders, book, &levels)
+	return snapshot(order), trades, nil
+}
+
+// priorityTime returns the time an order entering a book at now queues by:
+// now, unless the clock has gone back since an earlier order was queued.
+// Called with the lock held.
+func (me *MatchingEngine) priorityTime(now time.Time) time.Time {
+	me.notePriority(now)
+	return me.lastPriority
+}
+
+// notePriority records that an order queued at t.
+func (me *MatchingEngine) notePriority(t time.Time) {
+	if t.After(me.lastPriority) {
+		me.lastPriority = t
+	}
+}
+
+// keepsPriority reports whether amending a resting order to price and
+// remaining can be done in place: same price and no more quantity.
+func keepsPriority(order *models.Order, price *float64, remaining int) bool {
+	return price != nil && order.Price != nil && *price == *order.Price && remaining <= order.RemainingQuantity
+}
+
+func setAmendedStatus(order *models.Order) {
+	if order.RemainingQuantity < order.InitialQuantity {
+		order.Status = "partial"
+	} else {
+		order.Status = "open"
+	}
+}
+
+// MassCancel cancels every resting order accepted by the filter. The matching
+// orders are cancelled in a single database transaction and only removed from
+// their books once it commits, so either all of them are cancelled or none.
+func (me *MatchingEngine) MassCancel(filter models.MassCancelRequest) ([]string, error) {
+	me.lock()
+	defer me.unlock()
+
+	var orders []*models.Order
+	for symbol, book := range me.orderBooks {
+		if filter.Symbol != "" && symbol != filter.Symbol {
+			continue
+		}
+		orders = append(orders, book.FindOrders(filter.Matches)...)
+	}
+
+	cancelledIDs := make([]string, 0, len(orders))
+	if len(orders) == 0 {
+		return cancelledIDs, nil
+	}
+
+	if err := me.cancelResting(orders, journal.ReasonMassCancel); err != nil {
+		return nil, fmt.Errorf("failed to execute mass cancel transaction: %w", err)
+	}
+	for _, order := range orders {
+		cancelledIDs = append(cancelledIDs, order.ID)
+	}
+	return cancelledIDs, nil
+}
+
+// CancelOrders cancels the requested resting orders in a single database
+// transaction, like MassCancel. The returned slice holds, for each ID, why it
+// could not be cancelled, or nil. With AllOrNone, nothing is cancelled
+// unless every order can be.
+func (me *MatchingEngine) CancelOrders(req models.BatchCancelRequest) ([]error, error) {
+	me.lock()
+	defer me.unlock()
+
+	orderIDs := req.OrderIDs
+	wanted := make(map[string]bool, len(orderIDs))
+	for _, id := range orderIDs {
+		wanted[id] = true
+	}
+	resting := make(map[string]*models.Order, len(orderIDs))
+	for _, book := range me.orderBooks {
+		for _, order := range book.FindOrders(func(o *models.Order) bool { return wanted[o.ID] && (req.Account == "" || o.Account == req.Account) }) {
+			resting[order.ID] = order
+		}
+	}
+
+	errs := make([]error, len(orderIDs))
+	failed := false
+	seen := make(map[string]bool, len(orderIDs))
+	orders := make([]*models.Order, 0, len(orderIDs))
+	for i, id := range orderIDs {
+		switch order := resting[id]; {
+		case seen[id]:
+			errs[i] = errors.New("order listed more than once")
+		case order != nil:
+			orders = append(orders, order)
+		default:
+			stored, err := me.store.GetOrderByID(context.Background(), id)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get order: %w", err)
+			}
+			if stored == nil || (req.Account != "" && stored.Account != req.Account) {
+				errs[i] = utils.ErrOrderNotFound
+			} else {
+				errs[i] = fmt.Errorf("cannot cancel order with status: %s", stored.Status)
+			}
+		}
+		seen[id] = true
+		failed = failed || errs[i] != nil
+	}
+
+	if len(orders) == 0 || (req.AllOrNone && failed) {
+		return errs, nil
+	}
+	if err := me.cancelResting(orders, journal.ReasonCancelRequested); err != nil {
+		return nil, fmt.Errorf("failed to execute batch cancel transaction: %w", err)
+	}
+	return errs, nil
+}
+
+// cancelResting cancels resting orders in one transaction and only removes
+// them from their books once it commits.
+func (me *MatchingEngine) cancelResting(orders []*models.Order, reason string) error {
+	now := me.clock.Now()
+	entries := make([]*journal.Entry, 0, len(orders))
+	orderEvents := make([]*models.OrderEvent, 0, len(orders))
+	for _, order := range orders {
+		cancelled := snapshot(order)
+		cancelled.Status = "cancelled"
+		entries = append(entries, &journal.Entry{Type: journal.EntryOrderCancelled, Order: cancelled, OrderID: order.ID, Reason: reason})
+		orderEvents = append(orderEvents, newOrderEvent(order, models.OrderEventCancelled, reason, now))
+	}
+	if err := me.record(entries...); err != nil {
+		return err
+	}
+	if err := me.store.CancelOrders(context.Background(), orders, orderEvents); err != nil {
+		me.rollback(entries, err)
+		return err
+	}
+
+	levelsBySymbol := make(map[string]*levelTracker)
+	var symbols []string
+	for _, order := range orders {
+		me.orderBooks[order.Symbol].RemoveOrder(order.ID)
+		order.Status = "cancelled"
+
+		if levelsBySymbol[order.Symbol] == nil {
+			levelsBySymbol[order.Symbol] = &levelTracker{}
+			symbols = append(symbols, order.Symbol)
+		}
+		levelsBySymbol[order.Symbol].touch(order)
+	}
+
+	for _, order := range orders {
+		me.emit(statusEvent(order))
+	}
+	for _, symbol := range symbols {
+		me.emit(me.bookEvents(me.orderBooks[symbol], levelsBySymbol[symbol])...)
+	}
+	return nil
 }
 
 func (me *MatchingEngine) GetOrderBook(symbol string) *OrderBook {
@@ -223,14 +806,42 @@ func (me *MatchingEngine) GetOrderBook(symbol string) *OrderBook {
 	return me.getOrderBook(symbol)
 }
 
-func (me *MatchingEngine) GetAllOrderBooks() map[string]interface{} {
+// BestPrices returns the top bid and ask levels of symbol's book. Either is
+// nil when that side is empty or the symbol has never traded.
+func (me *MatchingEngine) BestPrices(symbol string) (bid, ask *models.PriceLevelUpdate) {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+
+	book, exists := me.orderBooks[symbol]
+	if !exists {
+		return nil, nil
+	}
+	return book.BestBid(), book.BestAsk()
+}
+
+// Symbols returns every symbol with an order book, sorted.
+func (me *MatchingEngine) Symbols() []string {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+
+	symbols := make([]string, 0, len(me.orderBooks))
+	for symbol := range me.orderBooks {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// GetAllOrderBooks summarizes the top depth orders on each side of every
+// book.
+func (me *MatchingEngine) GetAllOrderBooks(depth int) map[string]interface{} {
 	me.mu.RLock()
 	defer me.mu.RUnlock()
 
 	allBooks := make(map[string]interface{})
 	for symbol, book := range me.orderBooks {
-		bids := book.GetTopBids(10)
-		asks := book.GetTopAsks(10)
+		bids := book.GetTopBids(depth)
+		asks := book.GetTopAsks(depth)
 		
 		// Format bids and asks (simplified version)
 		var formattedBids []map[string]interface{}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"order-matching-engine/clock"
+	"order-matching-engine/idgen"
+	"order-matching-engine/journal"
+	"order-matching-engine/models"
+	"order-matching-engine/utils"
+)
+
+// failingStore is a MemoryStore whose writes fail while fail is set.
+type failingStore struct {
+	*MemoryStore
+	fail bool
+}
+
+var errStoreDown = errors.New("store down")
+
+func (s *failingStore) ExecuteOrderMatching(ctx context.Context, order *models.Order, trades []*models.Trade, updatedOrders []*models.Order, events []*models.OrderEvent) error {
+	if s.fail {
+		return errStoreDown
+	}
+	return s.MemoryStore.ExecuteOrderMatching(ctx, order, trades, updatedOrders, events)
+}
+
+func (s *failingStore) AmendOrder(ctx context.Context, order *models.Order, trades []*models.Trade, updatedOrders []*models.Order, events []*models.OrderEvent) error {
+	if s.fail {
+		return errStoreDown
+	}
+	return s.MemoryStore.AmendOrder(ctx, order, trades, updatedOrders, events)
+}
+
+func (s *failingStore) CancelOrders(ctx context.Context, orders []*models.Order, events []*models.OrderEvent) error {
+	if s.fail {
+		return errStoreDown
+	}
+	return s.MemoryStore.CancelOrders(ctx, orders, events)
+}
+
+type testEngine struct {
+	*MatchingEngine
+	store   *failingStore
+	journal *journal.Journal
+	dir     string
+}
+
+func newTestEngine(t *testing.T) *testEngine {
+	t.Helper()
+	dir := t.TempDir()
+	j, err := journal.Open(filepath.Join(dir, "engine.journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { j.Close() })
+	store := &failingStore{MemoryStore: NewMemoryStore()}
+	engine := NewMatchingEngine(
+		WithStore(store),
+		WithJournal(j),
+		WithClock(clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Millisecond)),
+		WithTradeIDs(idgen.NewSequence("T", 0)),
+	)
+	return &testEngine{MatchingEngine: engine, store: store, journal: j, dir: dir}
+}
+
+// recovered builds a fresh engine from the test engine's journal.
+func (e *testEngine) recovered(t *testing.T) *MatchingEngine {
+	t.Helper()
+	engine := NewMatchingEngine(WithStore(NewMemoryStore()))
+	snapshots := journal.NewSnapshotStore(filepath.Join(e.dir, "snapshots"), 3)
+	if _, err := engine.Recover(snapshots, e.journal.Path()); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	return engine
+}
+
+func limitOrder(id, side string, price float64, quantity int) *models.Order {
+	return &models.Order{
+		ID:                id,
+		Symbol:            "AAPL",
+		Side:              side,
+		Type:              "limit",
+		Price:             &price,
+		InitialQuantity:   quantity,
+		RemainingQuantity: quantity,
+		Status:            "open",
+	}
+}
+
+func place(t *testing.T, engine *MatchingEngine, order *models.Order) []*models.Trade {
+	t.Helper()
+	trades, err := engine.ProcessOrder(context.Background(), order)
+	if err != nil {
+		t.Fatalf("ProcessOrder %s: %v", order.ID, err)
+	}
+	return trades
+}
+
+// bookState describes a book as "side:id:remaining:status" in priority
+// order, bids first.
+func bookState(engine *MatchingEngine) []string {
+	var state []string
+	book := engine.GetOrderBook("AAPL")
+	for _, order := range book.FindOrders(func(*models.Order) bool { return true }) {
+		state = append(state, fmt.Sprintf("%s:%s:%d:%s", order.Side, order.ID, order.RemainingQuantity, order.Status))
+	}
+	return state
+}
+
+func assertBook(t *testing.T, engine *MatchingEngine, want ...string) {
+	t.Helper()
+	got := bookState(engine)
+	if len(got) != len(want) {
+		t.Fatalf("book = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("book = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestProcessOrderStoreFailureRollsBack(t *testing.T) {
+	e := newTestEngine(t)
+	place(t, e.MatchingEngine, limitOrder("s1", "sell", 100, 10))
+	place(t, e.MatchingEngine, limitOrder("s2", "sell", 100, 5))
+
+	e.store.fail = true
+	if _, err := e.ProcessOrder(context.Background(), limitOrder("b1", "buy", 100, 12)); !errors.Is(err, errStoreDown) {
+		t.Fatalf("ProcessOrder error = %v, want store failure", err)
+	}
+	assertBook(t, e.MatchingEngine, "sell:s1:10:open", "sell:s2:5:open")
+
+	e.store.fail = false
+	trades := place(t, e.MatchingEngine, limitOrder("b2", "buy", 100, 3))
+	if len(trades) != 1 || trades[0].SellOrderID != "s1" || trades[0].Quantity != 3 {
+		t.Fatalf("trades after rollback = %+v", trades)
+	}
+	assertBook(t, e.MatchingEngine, "sell:s1:7:partial", "sell:s2:5:open")
+
+	var rolledBack *journal.Entry
+	if err := journal.Read(e.journal.Path(), func(entry *journal.Entry) error {
+		if entry.Type == journal.EntryRolledBack {
+			rolledBack = entry
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// s1, s2, then b1 accepted with two trades
+	if rolledBack == nil || rolledBack.From != 3 || rolledBack.To != 5 {
+		t.Fatalf("rolled_back entry = %+v, want entries 3 to 5", rolledBack)
+	}
+
+	assertBook(t, e.recovered(t), "sell:s1:7:partial", "sell:s2:5:open")
+}
+
+func TestAmendStoreFailureRollsBack(t *testing.T) {
+	e := newTestEngine(t)
+	place(t, e.MatchingEngine, limitOrder("b1", "buy", 99, 5))
+	place(t, e.MatchingEngine, limitOrder("b2", "buy", 99, 5))
+	place(t, e.MatchingEngine, limitOrder("s1", "sell", 101, 4))
+
+	e.store.fail = true
+	price := 101.0
+	if _, _, err := e.AmendOrder("b1", 6, &price); !errors.Is(err, errStoreDown) {
+		t.Fatalf("AmendOrder error = %v, want store failure", err)
+	}
+	assertBook(t, e.MatchingEngine, "buy:b1:5:open", "buy:b2:5:open", "sell:s1:4:open")
+
+	assertBook(t, e.recovered(t), "buy:b1:5:open", "buy:b2:5:open", "sell:s1:4:open")
+}
+
+func TestAmendSavesQueueTime(t *testing.T) {
+	e := newTestEngine(t)
+	place(t, e.MatchingEngine, limitOrder("b1", "buy", 99, 5))
+	place(t, e.MatchingEngine, limitOrder("b2", "buy", 99, 5))
+	stored := func(id string) *models.Order {
+		t.Helper()
+		order, err := e.store.GetOrderByID(context.Background(), id)
+		if err != nil || order == nil {
+			t.Fatalf("GetOrderByID %s = %v, %v", id, order, err)
+		}
+		return order
+	}
+	b2Created := stored("b2").CreatedAt
+
+	// Adding quantity requeues b1 behind b2, and the store must agree
+	amended, _, err := e.AmendOrder("b1", 6, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBook(t, e.MatchingEngine, "buy:b2:5:open", "buy:b1:6:open")
+	if got := stored("b1").CreatedAt; !got.Equal(amended.CreatedAt) || !got.After(b2Created) {
+		t.Fatalf("stored b1 created at %v, want the requeue time %v after b2's %v", got, amended.CreatedAt, b2Created)
+	}
+
+	// Reducing in place keeps it
+	if _, _, err := e.AmendOrder("b2", 4, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := stored("b2").CreatedAt; !got.Equal(b2Created) {
+		t.Fatalf("stored b2 created at %v after a reduction, want %v", got, b2Created)
+	}
+}
+
+func TestCancelStoreFailureKeepsOrder(t *testing.T) {
+	e := newTestEngine(t)
+	place(t, e.MatchingEngine, limitOrder("b1", "buy", 99, 5))
+
+	e.store.fail = true
+	if err := e.CancelOrder("b1"); !errors.Is(err, errStoreDown) {
+		t.Fatalf("CancelOrder error = %v, want store failure", err)
+	}
+	assertBook(t, e.MatchingEngine, "buy:b1:5:open")
+	assertBook(t, e.recovered(t), "buy:b1:5:open")
+}
+
+func TestJournalFailureStopsEngine(t *testing.T) {
+	e := newTestEngine(t)
+	place(t, e.MatchingEngine, limitOrder("b1", "buy", 99, 5))
+
+	// Writes to a closed journal fail
+	e.journal.Close()
+	if _, err := e.ProcessOrder(context.Background(), limitOrder("b2", "buy", 99, 5)); !errors.Is(err, utils.ErrJournalFailed) {
+		t.Fatalf("ProcessOrder error = %v, want journal failure", err)
+	}
+	if stored, _ := e.store.GetOrderByID(context.Background(), "b2"); stored != nil {
+		t.Fatal("order was committed without being journaled")
+	}
+	if err := e.CancelOrder("b1"); !errors.Is(err, utils.ErrJournalFailed) {
+		t.Fatalf("CancelOrder error = %v, want journal failure", err)
+	}
+	if _, err := e.ProcessOrder(context.Background(), limitOrder("b3", "buy", 98, 5)); !errors.Is(err, utils.ErrJournalFailed) {
+		t.Fatalf("ProcessOrder after failure error = %v, want journal failure", err)
+	}
+	if !e.Status().JournalFailed {
+		t.Fatal("Status().JournalFailed = false")
+	}
+}
+
+func TestInjectedClockAndIDsAreDeterministic(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	run := func() []*models.Trade {
+		engine := NewMatchingEngine(
+			WithStore(NewMemoryStore()),
+			WithClock(clock.NewFake(start, time.Millisecond)),
+			WithTradeIDs(idgen.NewSequence("T", 0)),
+		)
+		place(t, engine, limitOrder("s1", "sell", 100, 5))
+		place(t, engine, limitOrder("s2", "sell", 101, 5))
+		return place(t, engine, limitOrder("b1", "buy", 101, 8))
+	}
+
+	first, second := run(), run()
+	if len(first) != 2 || len(second) != 2 {
+		t.Fatalf("got %d and %d trades, want 2", len(first), len(second))
+	}
+	for i := range first {
+		if *first[i] != *second[i] {
+			t.Fatalf("trade %d differs between runs: %+v and %+v", i, first[i], second[i])
+		}
+	}
+	if first[0].ID != "T0000000000000001" || first[1].ID != "T0000000000000002" {
+		t.Fatalf("trade IDs = %s, %s", first[0].ID, first[1].ID)
+	}
+	if !first[0].ExecutedAt.After(start) || !first[1].ExecutedAt.After(first[0].ExecutedAt) {
+		t.Fatalf("trade times = %v, %v", first[0].ExecutedAt, first[1].ExecutedAt)
+	}
+}
+
+func TestReplayDoesNotConsumeTradeIDsOrClock(t *testing.T) {
+	e := newTestEngine(t)
+	place(t, e.MatchingEngine, limitOrder("s1", "sell", 100, 5))
+	place(t, e.MatchingEngine, limitOrder("s2", "sell", 100, 5))
+	place(t, e.MatchingEngine, limitOrder("b1", "buy", 100, 7))
+
+	// As at startup: trade IDs resume after the last one issued
+	restart := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	engine := NewMatchingEngine(
+		WithStore(NewMemoryStore()),
+		WithClock(clock.NewFake(restart, time.Millisecond)),
+		WithTradeIDs(idgen.NewSequence("T", 2)),
+	)
+	snapshots := journal.NewSnapshotStore(filepath.Join(e.dir, "snapshots"), 3)
+	if _, err := engine.Recover(snapshots, e.journal.Path()); err != nil {
+		t.Fatal(err)
+	}
+	assertBook(t, engine, "sell:s2:3:partial")
+
+	trades := place(t, engine, limitOrder("b2", "buy", 100, 1))
+	if len(trades) != 1 || trades[0].ID != "T0000000000000003" {
+		t.Fatalf("trades after recovery = %+v, want ID T0000000000000003", trades)
+	}
+	if !trades[0].ExecutedAt.Equal(restart.Add(time.Millisecond)) {
+		t.Fatalf("trade executed at %v, want %v", trades[0].ExecutedAt, restart.Add(time.Millisecond))
+	}
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"errors"
+	"order-matching-engine/models"
+	"order-matching-engine/utils"
+	"time"
+)
+
+// Reasons the engine rejects an order, as reported to Metrics
+const (
+	RejectReasonHalted      = "halted"
+	RejectReasonMaintenance = "maintenance"
+	RejectReasonPersistence = "persistence_error"
+)
+
+// Metrics receives measurements of order processing. It is called with the
+// engine lock held, so it must not block.
+type Metrics interface {
+	OrderAccepted(order *models.Order)
+	OrderRejected(order *models.Order, reason string)
+	// ProcessOrder time spent matching in memory and persisting the result
+	// with ExecuteOrderMatching
+	ObserveProcessOrder(matching, persistence time.Duration)
+}
+
+type noMetrics struct{}
+
+func (noMetrics) OrderAccepted(*models.Order)                      {}
+func (noMetrics) OrderRejected(*models.Order, string)              {}
+func (noMetrics) ObserveProcessOrder(time.Duration, time.Duration) {}
+
+// WithMetrics reports order processing to m
+func WithMetrics(m Metrics) Option {
+	return func(me *MatchingEngine) {
+		me.metrics = m
+	}
+}
+
+func rejectReason(err error) string {
+	switch {
+	case errors.Is(err, utils.ErrSymbolHalted):
+		return RejectReasonHalted
+	case errors.Is(err, utils.ErrMaintenanceMode):
+		return RejectReasonMaintenance
+	}
+	return RejectReasonPersistence
+}
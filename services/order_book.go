@@ -10,6 +10,7 @@ type OrderBook struct {
 	Symbol     string
 	BuyOrders  []*models.Order
 	SellOrders []*models.Order
+	changes    []models.OrderBookEvent // order-level changes since the last TakeChanges
 	mu         sync.RWMutex
 }
 
@@ -34,6 +35,7 @@ func (ob *OrderBook) AddOrder(order *models.Order) {
 	} else {
 		ob.SellOrders = ob.insertSortedSell(ob.SellOrders, order)
 	}
+	ob.recordChange(models.BookEventAdd, order, 0, "")
 }
 
 func (ob *OrderBook) RemoveOrder(orderID string) {
@@ -44,6 +46,7 @@ func (ob *OrderBook) RemoveOrder(orderID string) {
 	for i, order := range ob.BuyOrders {
 		if order.ID == orderID {
 			ob.BuyOrders = append(ob.BuyOrders[:i], ob.BuyOrders[i+1:]...)
+			ob.recordChange(models.BookEventDelete, order, 0, "")
 			return
 		}
 	}
@@ -52,11 +55,167 @@ func (ob *OrderBook) RemoveOrder(orderID string) {
 	for i, order := range ob.SellOrders {
 		if order.ID == orderID {
 			ob.SellOrders = append(ob.SellOrders[:i], ob.SellOrders[i+1:]...)
+			ob.recordChange(models.BookEventDelete, order, 0, "")
 			return
 		}
 	}
 }
 
+// ReduceOrder lowers a resting order's quantities in place, keeping its
+// place in the queue.
+func (ob *OrderBook) ReduceOrder(order *models.Order, initial, remaining int) {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	order.InitialQuantity = initial
+	order.RemainingQuantity = remaining
+	ob.recordChange(models.BookEventModify, order, 0, "")
+}
+
+// RecordExecution notes that a resting order traded; the caller has already
+// reduced its remaining quantity.
+func (ob *OrderBook) RecordExecution(order *models.Order, trade *models.Trade) {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	ob.recordChange(models.BookEventExecute, order, trade.Quantity, trade.ID)
+}
+
+// TakeChanges returns the order-level changes made since the last call and
+// clears them.
+func (ob *OrderBook) TakeChanges() []models.OrderBookEvent {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	changes := ob.changes
+	ob.changes = nil
+	return changes
+}
+
+// bookSides holds copies of a book's order lists, taken by saveSides.
+type bookSides struct {
+	buys, sells []*models.Order
+}
+
+// saveSides copies both order lists so an operation that fails to commit
+// can put them back with restoreSides.
+func (ob *OrderBook) saveSides() bookSides {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+
+	return bookSides{
+		buys:  append([]*models.Order(nil), ob.BuyOrders...),
+		sells: append([]*models.Order(nil), ob.SellOrders...),
+	}
+}
+
+// restoreSides puts back order lists taken by saveSides and drops the
+// order-level changes made since, which were never published.
+func (ob *OrderBook) restoreSides(sides bookSides) {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	ob.BuyOrders = sides.buys
+	ob.SellOrders = sides.sells
+	ob.changes = nil
+}
+
+// recordChange must be called with ob.mu held.
+func (ob *OrderBook) recordChange(eventType string, order *models.Order, executed int, tradeID string) {
+	if order.Price == nil {
+		return
+	}
+	quantity := order.RemainingQuantity
+	if eventType == models.BookEventDelete {
+		quantity = 0
+	}
+	ob.changes = append(ob.changes, models.OrderBookEvent{
+		Type:             eventType,
+		Symbol:           ob.Symbol,
+		OrderID:          order.ID,
+		Side:             order.Side,
+		Price:            *order.Price,
+		Quantity:         quantity,
+		ExecutedQuantity: executed,
+		TradeID:          tradeID,
+	})
+}
+
+// FindOrders returns the resting orders on both sides accepted by match,
+// bids first, each side in priority order.
+func (ob *OrderBook) FindOrders(match func(*models.Order) bool) []*models.Order {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+
+	var found []*models.Order
+	for _, order := range ob.BuyOrders {
+		if match(order) {
+			found = append(found, order)
+		}
+	}
+	for _, order := range ob.SellOrders {
+		if match(order) {
+			found = append(found, order)
+		}
+	}
+	return found
+}
+
+// LevelTotals returns the total remaining quantity and number of orders
+// resting at price on side.
+func (ob *OrderBook) LevelTotals(side string, price float64) (int, int) {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+
+	orders := ob.SellOrders
+	if side == "buy" {
+		orders = ob.BuyOrders
+	}
+
+	quantity, count := 0, 0
+	for _, order := range orders {
+		if order.Price != nil && *order.Price == price {
+			quantity += order.RemainingQuantity
+			count++
+		}
+	}
+	return quantity, count
+}
+
+// BestBid returns the aggregate of the highest bid price level, or nil if
+// there are no bids.
+func (ob *OrderBook) BestBid() *models.PriceLevelUpdate {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+
+	return topLevel("buy", ob.BuyOrders)
+}
+
+// BestAsk returns the aggregate of the lowest ask price level, or nil if
+// there are no asks.
+func (ob *OrderBook) BestAsk() *models.PriceLevelUpdate {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+
+	return topLevel("sell", ob.SellOrders)
+}
+
+// topLevel sums the orders at the front of a side sorted in priority order.
+func topLevel(side string, orders []*models.Order) *models.PriceLevelUpdate {
+	if len(orders) == 0 || orders[0].Price == nil {
+		return nil
+	}
+	level := &models.PriceLevelUpdate{Side: side, Price: *orders[0].Price}
+	for _, order := range orders {
+		if order.Price == nil || *order.Price != level.Price {
+			break
+		}
+		level.Quantity += order.RemainingQuantity
+		level.Orders++
+	}
+	return level
+}
+
 func (ob *OrderBook) sortBuyOrders() {
 	sort.Slice(ob.BuyOrders, func(i, j int) bool {
 		if ob.BuyOrders[i].Price == nil || ob.BuyOrders[j].Price == nil {
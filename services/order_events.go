@@ -0,0 +1,59 @@
+package services
+
+import (
+	"order-matching-engine/journal"
+	"order-matching-engine/models"
+	"time"
+)
+
+// matchingEvents builds the lifecycle events for an order about to match:
+// eventType (accepted or amended) with the order as it was before matching,
+// then a fill for each side of every trade, then a cancel if a market order's
+// remainder found no liquidity.
+func matchingEvents(eventType string, before, order *models.Order, trades []*models.Trade, updatedOrders []*models.Order, at time.Time) []*models.OrderEvent {
+	events := []*models.OrderEvent{newOrderEvent(before, eventType, "", at)}
+
+	remaining := before.RemainingQuantity
+	for i, trade := range trades {
+		remaining -= trade.Quantity
+		resting := updatedOrders[i]
+		events = append(events, fillEvent(order.ID, trade, remaining), fillEvent(resting.ID, trade, resting.RemainingQuantity))
+	}
+
+	if order.Status == "cancelled" {
+		events = append(events, newOrderEvent(order, models.OrderEventCancelled, journal.ReasonNoLiquidity, at))
+	}
+	return events
+}
+
+func newOrderEvent(order *models.Order, eventType, reason string, at time.Time) *models.OrderEvent {
+	event := &models.OrderEvent{
+		OrderID:           order.ID,
+		Type:              eventType,
+		RemainingQuantity: order.RemainingQuantity,
+		Reason:            reason,
+		CreatedAt:         at,
+	}
+	if order.Price != nil {
+		price := *order.Price
+		event.Price = &price
+	}
+	return event
+}
+
+func fillEvent(orderID string, trade *models.Trade, remaining int) *models.OrderEvent {
+	eventType := models.OrderEventPartialFill
+	if remaining == 0 {
+		eventType = models.OrderEventFilled
+	}
+	price := trade.Price
+	return &models.OrderEvent{
+		OrderID:           orderID,
+		Type:              eventType,
+		Quantity:          trade.Quantity,
+		RemainingQuantity: remaining,
+		Price:             &price,
+		TradeID:           trade.ID,
+		CreatedAt:         trade.ExecutedAt,
+	}
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"fmt"
+	"order-matching-engine/journal"
+	"order-matching-engine/models"
+	"sort"
+)
+
+// Snapshot captures every order book with its resting orders in priority
+// order, tagged with the sequence of the last journal entry it reflects.
+func (me *MatchingEngine) Snapshot() *journal.Snapshot {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+
+	return me.snapshot()
+}
+
+// snapshot must be called with the lock held.
+func (me *MatchingEngine) snapshot() *journal.Snapshot {
+	snap := &journal.Snapshot{
+		CreatedAt: me.clock.Now(),
+		Books:     make([]journal.BookSnapshot, 0, len(me.orderBooks)),
+	}
+	// Journal appends happen under the engine write lock, so the sequence
+	// cannot move while we hold the read lock
+	if me.journal != nil {
+		snap.Sequence = me.journal.LastSequence()
+	}
+
+	for symbol, book := range me.orderBooks {
+		book.mu.RLock()
+		snap.Books = append(snap.Books, journal.BookSnapshot{
+			Symbol: symbol,
+			Bids:   restingOrders(book.BuyOrders),
+			Asks:   restingOrders(book.SellOrders),
+		})
+		book.mu.RUnlock()
+	}
+	sort.Slice(snap.Books, func(i, j int) bool {
+		return snap.Books[i].Symbol < snap.Books[j].Symbol
+	})
+
+	return snap
+}
+
+// Restore replaces the order books with the contents of snap. Orders keep the
+// exact queue positions they had when the snapshot was taken.
+func (me *MatchingEngine) Restore(snap *journal.Snapshot) {
+	me.lock()
+	defer me.unlock()
+
+	me.orderBooks = make(map[string]*OrderBook, len(snap.Books))
+	for _, bookSnap := range snap.Books {
+		book := NewOrderBook(bookSnap.Symbol)
+		for _, resting := range bookSnap.Bids {
+			book.BuyOrders = append(book.BuyOrders, resting.Order)
+			me.notePriority(resting.Order.CreatedAt)
+		}
+		for _, resting := range bookSnap.Asks {
+			book.SellOrders = append(book.SellOrders, resting.Order)
+			me.notePriority(resting.Order.CreatedAt)
+		}
+		me.orderBooks[bookSnap.Symbol] = book
+	}
+}
+
+// Recover rebuilds the order books from the latest snapshot in store, then
+// replays only the journal entries written after it, less those a rollback
+// withdrew. It returns the sequence number of the last entry read.
+func (me *MatchingEngine) Recover(store *journal.SnapshotStore, journalPath string) (uint64, error) {
+	snap, err := store.Latest()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load snapshot: %w", err)
+	}
+
+	var lastSeq uint64
+	if snap != nil {
+		me.Restore(snap)
+		lastSeq = snap.Sequence
+	}
+
+	withdrawn, err := journal.RolledBack(journalPath, lastSeq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to replay journal: %w", err)
+	}
+	err = journal.ReadAfter(journalPath, lastSeq, func(entry *journal.Entry) error {
+		if entry.Sequence != lastSeq+1 {
+			return fmt.Errorf("%w: expected sequence %d after snapshot, got %d",
+				journal.ErrCorrupt, lastSeq+1, entry.Sequence)
+		}
+		lastSeq = entry.Sequence
+		if withdrawn[entry.Sequence] {
+			return nil
+		}
+		return me.Replay(entry)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to replay journal: %w", err)
+	}
+
+	// A journal truncated by the last snapshot may be empty; keep numbering
+	// after the snapshot rather than restarting at 1
+	if me.journal != nil {
+		me.journal.AdvanceTo(lastSeq)
+	}
+
+	return lastSeq, nil
+}
+
+// TakeSnapshot saves the current books to store and truncates the journal
+// entries the snapshot covers. It refuses once the journal has failed: the
+// books may then hold changes the journal and database never got, which a
+// snapshot would make permanent.
+func (me *MatchingEngine) TakeSnapshot(store *journal.SnapshotStore) (*journal.Snapshot, error) {
+	me.snapshotMu.Lock()
+	defer me.snapshotMu.Unlock()
+
+	me.mu.RLock()
+	if err := me.checkJournal(); err != nil {
+		me.mu.RUnlock()
+		return nil, err
+	}
+	snap := me.snapshot()
+	me.mu.RUnlock()
+
+	if _, err := store.Save(snap); err != nil {
+		return nil, err
+	}
+
+	if me.journal != nil {
+		if err := me.journal.TruncateThrough(snap.Sequence); err != nil {
+			return nil, fmt.Errorf("failed to truncate journal: %w", err)
+		}
+	}
+
+	return snap, nil
+}
+
+func restingOrders(orders []*models.Order) []journal.RestingOrder {
+	resting := make([]journal.RestingOrder, len(orders))
+	for i, order := range orders {
+		resting[i] = journal.RestingOrder{Priority: i + 1, Order: snapshot(order)}
+	}
+	return resting
+}
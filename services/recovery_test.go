@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"order-matching-engine/journal"
+	"order-matching-engine/utils"
+)
+
+func TestRecoverFromSnapshotAndJournal(t *testing.T) {
+	e := newTestEngine(t)
+	snapshots := journal.NewSnapshotStore(filepath.Join(e.dir, "snapshots"), 3)
+
+	place(t, e.MatchingEngine, limitOrder("b1", "buy", 99, 5))
+	place(t, e.MatchingEngine, limitOrder("b2", "buy", 99, 5))
+	place(t, e.MatchingEngine, limitOrder("s1", "sell", 101, 5))
+	snap, err := e.TakeSnapshot(snapshots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Sequence != 3 {
+		t.Fatalf("snapshot sequence = %d, want 3", snap.Sequence)
+	}
+
+	// After the snapshot: a fill against b1, a cancel and a new order
+	place(t, e.MatchingEngine, limitOrder("s2", "sell", 99, 3))
+	if err := e.CancelOrder("s1"); err != nil {
+		t.Fatal(err)
+	}
+	place(t, e.MatchingEngine, limitOrder("b3", "buy", 100, 2))
+	want := []string{"buy:b3:2:open", "buy:b1:2:partial", "buy:b2:5:open"}
+	assertBook(t, e.MatchingEngine, want...)
+
+	// The snapshot truncated the journal, so recovery needs both
+	entries := 0
+	if err := journal.Read(e.journal.Path(), func(*journal.Entry) error { entries++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if entries != 4 {
+		t.Fatalf("journal holds %d entries after the snapshot, want 4", entries)
+	}
+
+	recovered := NewMatchingEngine(WithStore(NewMemoryStore()))
+	lastSeq, err := recovered.Recover(snapshots, e.journal.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastSeq != 7 {
+		t.Fatalf("Recover returned sequence %d, want 7", lastSeq)
+	}
+	assertBook(t, recovered, want...)
+}
+
+func TestTakeSnapshotRefusedAfterJournalFailure(t *testing.T) {
+	e := newTestEngine(t)
+	snapshots := journal.NewSnapshotStore(filepath.Join(e.dir, "snapshots"), 3)
+	place(t, e.MatchingEngine, limitOrder("b1", "buy", 99, 5))
+
+	e.journal.Close()
+	if _, err := e.ProcessOrder(context.Background(), limitOrder("s1", "sell", 99, 5)); !errors.Is(err, utils.ErrJournalFailed) {
+		t.Fatalf("ProcessOrder error = %v, want journal failure", err)
+	}
+	// The unjournaled match was undone
+	assertBook(t, e.MatchingEngine, "buy:b1:5:open")
+
+	if _, err := e.TakeSnapshot(snapshots); !errors.Is(err, utils.ErrJournalFailed) {
+		t.Fatalf("TakeSnapshot error = %v, want journal failure", err)
+	}
+	if snap, err := snapshots.Latest(); err != nil || snap != nil {
+		t.Fatalf("a snapshot was saved: %v, %v", snap, err)
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"order-matching-engine/database"
+	"order-matching-engine/models"
+	"sync"
+)
+
+// Store persists the results of matching and cancels. The engine uses the
+// MySQL repositories by default; MemoryStore keeps everything in process for
+// replays that must not touch the database.
+type Store interface {
+	ExecuteOrderMatching(ctx context.Context, order *models.Order, trades []*models.Trade, updatedOrders []*models.Order, events []*models.OrderEvent) error
+	GetOrderByID(ctx context.Context, id string) (*models.Order, error)
+	AmendOrder(ctx context.Context, order *models.Order, trades []*models.Trade, updatedOrders []*models.Order, events []*models.OrderEvent) error
+	CancelOrders(ctx context.Context, orders []*models.Order, events []*models.OrderEvent) error
+	SaveOrderEvents(ctx context.Context, events []*models.OrderEvent) error
+}
+
+type databaseStore struct{}
+
+func (databaseStore) ExecuteOrderMatching(ctx context.Context, order *models.Order, trades []*models.Trade, updatedOrders []*models.Order, events []*models.OrderEvent) error {
+	return database.ExecuteOrderMatching(ctx, order, trades, updatedOrders, events)
+}
+
+func (databaseStore) GetOrderByID(ctx context.Context, id string) (*models.Order, error) {
+	return database.GetOrderByID(ctx, id)
+}
+
+func (databaseStore) AmendOrder(ctx context.Context, order *models.Order, trades []*models.Trade, updatedOrders []*models.Order, events []*models.OrderEvent) error {
+	return database.AmendOrder(ctx, order, trades, updatedOrders, events)
+}
+
+func (databaseStore) CancelOrders(ctx context.Context, orders []*models.Order, events []*models.OrderEvent) error {
+	return database.CancelOrders(ctx, orders, events)
+}
+
+func (databaseStore) SaveOrderEvents(ctx context.Context, events []*models.OrderEvent) error {
+	return database.SaveOrderEvents(ctx, events)
+}
+
+// MemoryStore is an in-process Store. It keeps copies of orders so callers
+// see the same read-after-write behaviour as the database.
+type MemoryStore struct {
+	orders      map[string]*models.Order
+	trades      []*models.Trade
+	orderEvents map[string][]*models.OrderEvent
+	lastEventID int64
+	mu          sync.RWMutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		orders:      make(map[string]*models.Order),
+		orderEvents: make(map[string][]*models.OrderEvent),
+	}
+}
+
+func (s *MemoryStore) ExecuteOrderMatching(_ context.Context, order *models.Order, trades []*models.Trade, updatedOrders []*models.Order, events []*models.OrderEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.orders[order.ID] = snapshot(order)
+	for _, trade := range trades {
+		copied := *trade
+		s.trades = append(s.trades, &copied)
+	}
+	for _, updated := range updatedOrders {
+		s.orders[updated.ID] = snapshot(updated)
+	}
+	s.saveOrderEvents(events)
+	return nil
+}
+
+func (s *MemoryStore) AmendOrder(_ context.Context, order *models.Order, trades []*models.Trade, updatedOrders []*models.Order, events []*models.OrderEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The creation time is saved too: a requeued order's is the amend's
+	s.orders[order.ID] = snapshot(order)
+	for _, trade := range trades {
+		copied := *trade
+		s.trades = append(s.trades, &copied)
+	}
+	for _, updated := range updatedOrders {
+		s.orders[updated.ID] = snapshot(updated)
+	}
+	s.saveOrderEvents(events)
+	return nil
+}
+
+func (s *MemoryStore) GetOrderByID(_ context.Context, id string) (*models.Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	order, exists := s.orders[id]
+	if !exists {
+		return nil, nil
+	}
+	return snapshot(order), nil
+}
+
+func (s *MemoryStore) CancelOrders(_ context.Context, orders []*models.Order, events []*models.OrderEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, order := range orders {
+		if stored, exists := s.orders[order.ID]; exists {
+			stored.Status = "cancelled"
+		}
+	}
+	s.saveOrderEvents(events)
+	return nil
+}
+
+func (s *MemoryStore) SaveOrderEvents(_ context.Context, events []*models.OrderEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.saveOrderEvents(events)
+	return nil
+}
+
+// OrderEvents returns an order's lifecycle events in the order they happened.
+func (s *MemoryStore) OrderEvents(orderID string) []*models.OrderEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]*models.OrderEvent, len(s.orderEvents[orderID]))
+	copy(events, s.orderEvents[orderID])
+	return events
+}
+
+// saveOrderEvents numbers events like an auto-increment column; must be
+// called with s.mu held.
+func (s *MemoryStore) saveOrderEvents(events []*models.OrderEvent) {
+	for _, event := range events {
+		s.lastEventID++
+		event.ID = s.lastEventID
+		copied := *event
+		s.orderEvents[event.OrderID] = append(s.orderEvents[event.OrderID], &copied)
+	}
+}
+
+// Trades returns every trade stored, in execution order.
+func (s *MemoryStore) Trades() []*models.Trade {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	trades := make([]*models.Trade, len(s.trades))
+	copy(trades, s.trades)
+	return trades
+}
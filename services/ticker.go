@@ -0,0 +1,203 @@
+package services
+
+import (
+	"order-matching-engine/clock"
+	"order-matching-engine/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	tickerWindow      = 24 * time.Hour
+	tickerBucketWidth = time.Minute
+)
+
+// TickerService keeps each symbol's last trade and rolling 24 hour
+// statistics, updated incrementally as a Listener on the engine. Trades are
+// aggregated into one-minute buckets, so a bucket leaves the window once all
+// of its minute is more than 24 hours old.
+type TickerService struct {
+	clock   clock.Clock
+	symbols map[string]*tickerState
+	mu      sync.Mutex
+}
+
+type tickerState struct {
+	last    *models.Trade
+	buckets []tickerBucket // oldest first
+
+	// Totals over buckets, adjusted as trades arrive and rebuilt on eviction
+	open, high, low float64
+	volume          int
+	quoteVolume     float64
+	tradeCount      int
+}
+
+type tickerBucket struct {
+	start           time.Time
+	open, high, low float64
+	volume          int
+	quoteVolume     float64
+	tradeCount      int
+}
+
+func NewTickerService(clk clock.Clock) *TickerService {
+	return &TickerService{
+		clock:   clk,
+		symbols: make(map[string]*tickerState),
+	}
+}
+
+// OnEvent implements Listener.
+func (ts *TickerService) OnEvent(event Event) {
+	if event.Type == EventTrade {
+		ts.AddTrade(event.Trade)
+	}
+}
+
+// Backfill loads historical trades in execution order. Trades older than the
+// window only update the last price.
+func (ts *TickerService) Backfill(trades []*models.Trade) {
+	for _, trade := range trades {
+		ts.AddTrade(trade)
+	}
+}
+
+func (ts *TickerService) AddTrade(trade *models.Trade) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	state, exists := ts.symbols[trade.Symbol]
+	if !exists {
+		state = &tickerState{}
+		ts.symbols[trade.Symbol] = state
+	}
+	if state.last == nil || !trade.ExecutedAt.Before(state.last.ExecutedAt) {
+		state.last = trade
+	}
+
+	cutoff := ts.clock.Now().Add(-tickerWindow)
+	start := trade.ExecutedAt.Truncate(tickerBucketWidth)
+	if !start.Add(tickerBucketWidth).After(cutoff) {
+		return
+	}
+	state.add(start, trade)
+	state.evict(cutoff)
+}
+
+// Ticker returns the statistics for symbol. Bid and ask are left for the
+// caller to fill from the live book.
+func (ts *TickerService) Ticker(symbol string) models.Ticker {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	now := ts.clock.Now()
+	ticker := models.Ticker{Symbol: symbol, Timestamp: now}
+
+	state, exists := ts.symbols[symbol]
+	if !exists {
+		return ticker
+	}
+	state.evict(now.Add(-tickerWindow))
+
+	if state.last != nil {
+		lastPrice, lastAt := state.last.Price, state.last.ExecutedAt
+		ticker.LastPrice = &lastPrice
+		ticker.LastQuantity = state.last.Quantity
+		ticker.LastTradeAt = &lastAt
+	}
+	if state.tradeCount == 0 {
+		return ticker
+	}
+
+	open, high, low := state.open, state.high, state.low
+	vwap := state.quoteVolume / float64(state.volume)
+	ticker.Open, ticker.High, ticker.Low, ticker.VWAP = &open, &high, &low, &vwap
+	ticker.Volume = state.volume
+	ticker.QuoteVolume = state.quoteVolume
+	ticker.TradeCount = state.tradeCount
+
+	change := state.last.Price - open
+	changePercent := change / open * 100
+	ticker.PriceChange, ticker.PriceChangePercent = &change, &changePercent
+	return ticker
+}
+
+// Symbols returns every symbol that has traded, sorted.
+func (ts *TickerService) Symbols() []string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	symbols := make([]string, 0, len(ts.symbols))
+	for symbol := range ts.symbols {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+func (s *tickerState) add(start time.Time, trade *models.Trade) {
+	// Trades arrive in execution order, so this is almost always the newest bucket
+	i := len(s.buckets)
+	for i > 0 && s.buckets[i-1].start.After(start) {
+		i--
+	}
+	if i == 0 || !s.buckets[i-1].start.Equal(start) {
+		s.buckets = append(s.buckets, tickerBucket{})
+		copy(s.buckets[i+1:], s.buckets[i:])
+		s.buckets[i] = tickerBucket{start: start, open: trade.Price, high: trade.Price, low: trade.Price}
+	} else {
+		i--
+	}
+
+	bucket := &s.buckets[i]
+	if trade.Price > bucket.high {
+		bucket.high = trade.Price
+	}
+	if trade.Price < bucket.low {
+		bucket.low = trade.Price
+	}
+	bucket.volume += trade.Quantity
+	bucket.quoteVolume += trade.Price * float64(trade.Quantity)
+	bucket.tradeCount++
+
+	if s.tradeCount == 0 || trade.Price > s.high {
+		s.high = trade.Price
+	}
+	if s.tradeCount == 0 || trade.Price < s.low {
+		s.low = trade.Price
+	}
+	s.open = s.buckets[0].open
+	s.volume += trade.Quantity
+	s.quoteVolume += trade.Price * float64(trade.Quantity)
+	s.tradeCount++
+}
+
+// evict drops buckets that ended before cutoff and rebuilds the totals.
+func (s *tickerState) evict(cutoff time.Time) {
+	n := 0
+	for n < len(s.buckets) && !s.buckets[n].start.Add(tickerBucketWidth).After(cutoff) {
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	s.buckets = append(s.buckets[:0], s.buckets[n:]...)
+
+	s.volume, s.quoteVolume, s.tradeCount = 0, 0, 0
+	for i, bucket := range s.buckets {
+		if i == 0 {
+			s.open, s.high, s.low = bucket.open, bucket.high, bucket.low
+		}
+		if bucket.high > s.high {
+			s.high = bucket.high
+		}
+		if bucket.low < s.low {
+			s.low = bucket.low
+		}
+		s.volume += bucket.volume
+		s.quoteVolume += bucket.quoteVolume
+		s.tradeCount += bucket.tradeCount
+	}
+}
@@ -222,7 +222,7 @@ api_call "DELETE" "/orders/nonexistent-id-456" "" "" "404" "Cancel Non-existent
 print_section "9. HTTP METHOD VALIDATION TESTS"
 # =============================================================================
 
-api_call "GET" "/orders" "" "" "405" "GET on POST Endpoint"
+api_call "PUT" "/orders" "" "" "405" "PUT on Orders Collection"
 
 api_call "POST" "/orderbook?symbol=TEST" "" "" "405" "POST on GET Endpoint"
 
@@ -234,6 +234,10 @@ api_call "GET" "/orderbook" "" "" "200" "Order Book Missing Symbol (Now allowed)
 
 api_call "GET" "/trades" "" "" "200" "Trades Missing Symbol (Now allowed)"
 
+api_call "GET" "/orders?symbol=TRADE&status=open,partial" "" "" "200" "List Open Orders"
+
+api_call "GET" "/orders?status=invalid" "" "" "400" "List Orders Invalid Status"
+
 # =============================================================================
 print_section "11. EDGE CASE & STRESS TESTS"
 # =============================================================================
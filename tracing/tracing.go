@@ -0,0 +1,95 @@
+// Package tracing sets up OpenTelemetry tracing. Spans for HTTP requests,
+// order processing and repository calls are exported over OTLP/HTTP to a
+// collector, so a slow order can be traced through the handler, the engine
+// lock and MySQL.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const ServiceName = "order-matching-engine"
+
+type Config struct {
+	// Collector address as host:port, or a URL such as
+	// http://localhost:4318/v1/traces. Tracing is off when empty.
+	Endpoint string
+	// Send spans over plain HTTP instead of HTTPS, for a host:port endpoint
+	Insecure bool
+	// Fraction of new traces recorded, from 0 to 1. Requests that arrive
+	// with a sampled trace context are always recorded.
+	SampleRatio float64
+}
+
+// Init installs the global tracer provider described by cfg and returns a
+// function that flushes and stops it. With no endpoint, spans are not
+// recorded and the returned function does nothing.
+func Init(cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, fmt.Errorf("sample ratio %v is not between 0 and 1", cfg.SampleRatio)
+	}
+
+	var opts []otlptracehttp.Option
+	if strings.Contains(cfg.Endpoint, "://") {
+		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	} else {
+		opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Middleware starts a server span for every request, named after the path
+// template of the route it matched and continuing any incoming trace.
+func Middleware() func(http.Handler) http.Handler {
+	return otelmux.Middleware(ServiceName)
+}
+
+// Attributes recorded on order spans
+func OrderID(id string) attribute.KeyValue {
+	return attribute.String("order.id", id)
+}
+
+func Symbol(symbol string) attribute.KeyValue {
+	return attribute.String("order.symbol", symbol)
+}
+
+// End records err, if any, on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -8,4 +8,7 @@ var (
 	ErrOrderAlreadyFilled = errors.New("order already filled")
 	ErrOrderCancelled    = errors.New("order already cancelled")
 	ErrInvalidOrderStatus = errors.New("invalid order status for operation")
+	ErrSymbolHalted      = errors.New("trading is halted")
+	ErrMaintenanceMode   = errors.New("engine is in maintenance mode, only cancels are accepted")
+	ErrJournalFailed     = errors.New("engine journal write failed, changes are refused until restart")
 )
\ No newline at end of file
@@ -2,7 +2,7 @@ package utils
 
 import (
 	"encoding/json"
-	"fmt"
+	"log/slog"
 	"net/http"
 )
 
@@ -17,7 +17,7 @@ func WriteJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(data); err != nil {
 		// Log encoding error but don't expose to client
-		fmt.Printf("JSON encoding error: %v\n", err)
+		slog.Error("failed to encode JSON response", "status", status, "error", err)
 	}
 }
 